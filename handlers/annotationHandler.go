@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+)
+
+// AnnotationRequest remplace les annotations de l'utilisateur connecté sur une partition
+type AnnotationRequest struct {
+	Marks          []models.AnnotationMark `json:"marks"`
+	OrganizationID uint                    `json:"organization_id"` // Organisation avec laquelle les partager (0 : annotations privées)
+}
+
+// GetAnnotationsHandler renvoie les annotations de l'utilisateur connecté sur une partition,
+// ainsi que celles que les autres membres de ses organisations y ont partagées
+func GetAnnotationsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	annotation := models.Annotation{PartitionID: partition.ID, UserID: user.ID, Marks: []models.AnnotationMark{}}
+	err := lib.DB.Where("partition_id = ? AND user_id = ?", partition.ID, user.ID).First(&annotation).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des annotations"})
+		return
+	}
+
+	organizationIDs, err := lib.UserOrganizationIDs(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des organisations"})
+		return
+	}
+	shared := []models.Annotation{}
+	if len(organizationIDs) > 0 {
+		if err := lib.DB.Preload("User").
+			Where("partition_id = ? AND user_id <> ? AND organization_id IN ?", partition.ID, user.ID, organizationIDs).
+			Order("updated_at DESC").Find(&shared).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des annotations"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"annotations": annotation, "shared": shared})
+}
+
+// UpdateAnnotationsHandler enregistre les annotations de l'utilisateur connecté sur une partition ;
+// elles peuvent être partagées avec une de ses organisations dont la bibliothèque contient la partition
+func UpdateAnnotationsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	var request AnnotationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+	if err := lib.ValidateAnnotationMarks(request.Marks); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var organizationID *uint
+	if request.OrganizationID != 0 {
+		role, err := lib.OrganizationRole(request.OrganizationID, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des membres"})
+			return
+		}
+		if role == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organisation non trouvée"})
+			return
+		}
+		if partition.OrganizationID != nil && *partition.OrganizationID != request.OrganizationID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cette partition n'est pas visible par les membres de l'organisation"})
+			return
+		}
+		organizationID = &request.OrganizationID
+	}
+
+	annotation := models.Annotation{
+		PartitionID:    partition.ID,
+		UserID:         user.ID,
+		OrganizationID: organizationID,
+		Marks:          request.Marks,
+	}
+	if annotation.Marks == nil {
+		annotation.Marks = []models.AnnotationMark{}
+	}
+	if err := lib.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "partition_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"organization_id", "marks", "updated_at"}),
+	}).Create(&annotation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement des annotations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"annotations": annotation})
+}
+
+// DeleteAnnotationsHandler supprime les annotations de l'utilisateur connecté sur une partition
+func DeleteAnnotationsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := lib.DB.Where("partition_id = ? AND user_id = ?", c.Param("id"), user.ID).Delete(&models.Annotation{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression des annotations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Annotations supprimées"})
+}
+
+// ExportAnnotationsHandler renvoie le PDF d'une partition avec les annotations de l'utilisateur
+// connecté imprimées sur ses pages, ou avec celles partagées par un membre de ses organisations (?user=)
+func ExportAnnotationsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+	if partition.Format != lib.FormatPDF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "L'export des annotations n'est disponible que pour les partitions PDF"})
+		return
+	}
+	if !authorizePartitionDownload(c, &partition) {
+		return
+	}
+
+	var annotation models.Annotation
+	query := lib.DB.Where("partition_id = ? AND user_id = ?", partition.ID, user.ID)
+	if author := c.Query("user"); author != "" && author != fmt.Sprint(user.ID) {
+		organizationIDs, err := lib.UserOrganizationIDs(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des organisations"})
+			return
+		}
+		query = lib.DB.Where("partition_id = ? AND user_id = ? AND organization_id IN ?", partition.ID, author, organizationIDs)
+	}
+	if err := query.First(&annotation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Aucune annotation sur cette partition"})
+		return
+	}
+
+	content, err := lib.GetObjectContent(c, partition.Path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture sur Minio " + err.Error()})
+		return
+	}
+
+	watermark := lib.NewWatermarkInfo(user.Email, lib.WatermarkOrganization(partition.OrganizationID, user.ID))
+	if label := lib.PartitionLicenceLabel(partition); label != "" {
+		watermark.Licence = label
+	}
+	content, err = lib.AnnotatePDF(content, annotation.Marks, watermark.Text())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'impression des annotations: " + err.Error()})
+		return
+	}
+
+	lib.LogActionWithDetails("export_annotations", user.Email, map[string]interface{}{"partition_id": partition.ID, "annotation_id": annotation.ID})
+	recordPartitionHistory(user.ID, partition.ID, models.HistoryDownloaded)
+
+	setLicenceHeaders(c, partition)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"partition_%d_annotations.pdf\"", partition.ID))
+	c.Data(http.StatusOK, "application/pdf", content)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+	"strings"
+	"time"
+)
+
+// Nombre maximal de partitions dans un livret
+const maxBookletPartitions = 100
+
+// BookletRequest représente les données attendues pour générer un livret, à partir
+// d'une liste ordonnée de partitions ou d'une collection
+type BookletRequest struct {
+	Title        string `json:"title" binding:"required"`
+	Subtitle     string `json:"subtitle"`
+	PartitionIDs []uint `json:"partition_ids" binding:"max=100"` // Voir maxBookletPartitions
+	CollectionID uint   `json:"collection_id"`
+	ShareToken   string `json:"share_token"` // Pour une collection partagée par lien
+}
+
+// CreateBookletHandler assemble des partitions PDF, dans l'ordre demandé, en un seul
+// livret (page de garde, table des matières, numérotation) et le renvoie en téléchargement
+func CreateBookletHandler(c *gin.Context) {
+	claims, _ := lib.ExtractUserClaims(c)
+
+	var request BookletRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	// Le livret d'une collection d'organisation porte le nom de l'organisation en filigrane
+	var organizationID *uint
+	if request.CollectionID != 0 {
+		var collection models.Collection
+		if err := preloadCollectionItems(c, lib.DB).First(&collection, request.CollectionID).Error; err != nil ||
+			!canViewCollection(collection, optionalUserID(c), request.ShareToken) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Collection non trouvée"})
+			return
+		}
+		visibleCollectionItems(&collection)
+		request.PartitionIDs = collectionPartitionIDs(collection)
+		organizationID = collection.OrganizationID
+	}
+	if len(request.PartitionIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le champ 'partition_ids' ou 'collection_id' est requis"})
+		return
+	}
+	if len(request.PartitionIDs) > maxBookletPartitions {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Un livret contient au plus %d partitions", maxBookletPartitions)})
+		return
+	}
+
+	var partitions []models.Partition
+	if err := lib.DB.Scopes(visiblePartitionsScope(c)).Where("id IN ?", request.PartitionIDs).Find(&partitions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des partitions " + err.Error()})
+		return
+	}
+	byID := make(map[uint]models.Partition, len(partitions))
+	for _, partition := range partitions {
+		byID[partition.ID] = partition
+	}
+
+	// Les partitions sont insérées dans l'ordre de la requête (une même partition peut apparaître
+	// plusieurs fois, son fichier n'étant lu qu'une fois)
+	var entries []lib.BookletEntry
+	contents := make(map[uint][]byte, len(byID))
+	for _, id := range request.PartitionIDs {
+		partition, found := byID[id]
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Partition %d non trouvée", id)})
+			return
+		}
+		if content, read := contents[id]; read {
+			entries = append(entries, lib.BookletEntry{Title: partition.Title, Composer: partition.Composer, Content: content})
+			continue
+		}
+		if partition.Format != lib.FormatPDF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("La partition %d n'est pas au format PDF", id)})
+			return
+		}
+		// Le livret ne doit pas permettre de contourner la règle de téléchargement
+		if !authorizePartitionDownload(c, &partition) {
+			return
+		}
+
+		content, err := lib.GetObjectContent(c, partition.Path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture sur Minio " + err.Error()})
+			return
+		}
+		contents[id] = content
+		entries = append(entries, lib.BookletEntry{Title: partition.Title, Composer: partition.Composer, Content: content})
+	}
+
+	booklet, err := lib.BuildBooklet(request.Title, request.Subtitle, entries, lib.NewWatermarkInfo(claims.Email, lib.WatermarkOrganization(organizationID, optionalUserID(c))).Text())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la génération du livret: " + err.Error()})
+		return
+	}
+
+	// Le livret est conservé temporairement dans Minio (voir lib.BookletRetention)
+	filename := fmt.Sprintf("%d_%s.pdf", time.Now().Unix(), bookletFilename(request.Title))
+	if err := lib.PutObjectContent(c, lib.BookletPath(filename), booklet, "application/pdf"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'upload sur Minio " + err.Error()})
+		return
+	}
+
+	lib.LogActionWithDetails("create_booklet", claims.Email, map[string]interface{}{"partition_ids": request.PartitionIDs, "collection_id": request.CollectionID, "path": lib.BookletPath(filename)})
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	c.Data(http.StatusOK, "application/pdf", booklet)
+}
+
+// bookletFilename réduit un titre de livret à des caractères sûrs pour un nom de fichier
+func bookletFilename(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(title))
+	if name == "" {
+		return "livret"
+	}
+	return name
+}
@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+)
+
+// CollectionItemRequest représente une partition d'une collection dans une requête
+type CollectionItemRequest struct {
+	PartitionID uint   `json:"partition_id" binding:"required"`
+	Notes       string `json:"notes"`
+}
+
+// CollectionRequest représente les données attendues pour créer ou modifier une collection ;
+// les éléments sont fournis dans l'ordre voulu et remplacent la liste existante
+type CollectionRequest struct {
+	Title          string                  `json:"title" binding:"required"`
+	Description    string                  `json:"description"`
+	Visibility     string                  `json:"visibility"`
+	OrganizationID uint                    `json:"organization_id"` // Organisation partageant la collection (visibilité "organization")
+	Items          []CollectionItemRequest `json:"items" binding:"dive"`
+}
+
+// generateShareToken génère le jeton d'un lien de partage
+func generateShareToken() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// preloadCollectionItems charge les éléments d'une collection dans l'ordre, avec leur partition
+// si elle est visible par l'utilisateur connecté
+func preloadCollectionItems(c *gin.Context, db *gorm.DB) *gorm.DB {
+	return db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Preload("Items.Partition", visiblePartitionsScope(c))
+}
+
+// visibleCollectionItems retire d'une collection chargée par preloadCollectionItems les partitions
+// invisibles, placées depuis dans la bibliothèque privée d'une organisation
+func visibleCollectionItems(collection *models.Collection) {
+	items := collection.Items[:0]
+	for _, item := range collection.Items {
+		if item.Partition != nil {
+			items = append(items, item)
+		}
+	}
+	collection.Items = items
+}
+
+// loadCollection recherche la collection désignée par le paramètre :id, avec ses partitions visibles
+func loadCollection(c *gin.Context) (models.Collection, bool) {
+	var collection models.Collection
+	if err := preloadCollectionItems(c, lib.DB).First(&collection, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection non trouvée"})
+		return collection, false
+	}
+	visibleCollectionItems(&collection)
+	return collection, true
+}
+
+// loadOwnedCollection recherche la collection désignée par :id et vérifie qu'elle appartient à l'utilisateur connecté
+func loadOwnedCollection(c *gin.Context) (models.Collection, models.User, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		return models.Collection{}, user, false
+	}
+	collection, ok := loadCollection(c)
+	if !ok {
+		return collection, user, false
+	}
+	if collection.OwnerID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cette collection ne vous appartient pas"})
+		return collection, user, false
+	}
+	return collection, user, true
+}
+
+// canViewCollection indique si une collection est visible par l'utilisateur donné (0 si anonyme)
+// ou par le détenteur du jeton de partage
+func canViewCollection(collection models.Collection, userID uint, shareToken string) bool {
+	switch {
+	case userID != 0 && collection.OwnerID == userID:
+		return true
+	case collection.Visibility == models.CollectionPublic:
+		return true
+	case collection.Visibility == models.CollectionLink:
+		return shareToken != "" && shareToken == collection.ShareToken
+	case collection.Visibility == models.CollectionOrganization && collection.OrganizationID != nil:
+		role, err := lib.OrganizationRole(*collection.OrganizationID, userID)
+		return err == nil && role != ""
+	}
+	return false
+}
+
+// collectionResponse masque le jeton de partage aux utilisateurs autres que le propriétaire
+func collectionResponse(collection models.Collection, userID uint) models.Collection {
+	if collection.OwnerID != userID {
+		collection.ShareToken = ""
+	}
+	return collection
+}
+
+// collectionPartitionIDs retourne les partitions d'une collection, dans l'ordre
+func collectionPartitionIDs(collection models.Collection) []uint {
+	ids := make([]uint, 0, len(collection.Items))
+	for _, item := range collection.Items {
+		ids = append(ids, item.PartitionID)
+	}
+	return ids
+}
+
+// applyCollectionRequest valide la requête et reporte ses valeurs sur la collection
+func applyCollectionRequest(c *gin.Context, request CollectionRequest, collection *models.Collection, userID uint) bool {
+	switch request.Visibility {
+	case "":
+		if collection.Visibility == "" {
+			collection.Visibility = models.CollectionPrivate
+		}
+	case models.CollectionPrivate, models.CollectionLink, models.CollectionPublic, models.CollectionOrganization:
+		collection.Visibility = request.Visibility
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Visibilité invalide (private, link, public ou organization)"})
+		return false
+	}
+
+	// Seuls les chefs et propriétaires partagent une collection avec leur organisation
+	if collection.Visibility != models.CollectionOrganization {
+		collection.OrganizationID = nil
+	} else {
+		if request.OrganizationID != 0 {
+			collection.OrganizationID = &request.OrganizationID
+		}
+		if collection.OrganizationID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "L'organisation de la collection est requise"})
+			return false
+		}
+		role, err := lib.OrganizationRole(*collection.OrganizationID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des membres"})
+			return false
+		}
+		if !lib.HasOrganizationRole(role, models.OrganizationRoleDirector) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Action réservée aux chefs de l'organisation"})
+			return false
+		}
+	}
+
+	// Un nouveau lien est créé à chaque réactivation du partage : les anciens liens sont révoqués
+	if collection.Visibility != models.CollectionLink {
+		collection.ShareToken = ""
+	} else if collection.ShareToken == "" {
+		token, err := generateShareToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la génération du lien de partage"})
+			return false
+		}
+		collection.ShareToken = token
+	}
+
+	ids := make([]uint, 0, len(request.Items))
+	for _, item := range request.Items {
+		ids = append(ids, item.PartitionID)
+	}
+	// Les partitions privées ne peuvent figurer que dans les collections privées de leurs membres
+	// ou dans les collections partagées avec leur organisation
+	var organizationIDs []uint
+	switch collection.Visibility {
+	case models.CollectionPrivate:
+		organizationIDs, _ = lib.UserOrganizationIDs(userID)
+	case models.CollectionOrganization:
+		organizationIDs = []uint{*collection.OrganizationID}
+	}
+	var count int64
+	if len(ids) > 0 {
+		lib.DB.Model(&models.Partition{}).Scopes(lib.VisiblePartitions(organizationIDs)).Where("id IN ?", ids).Distinct("id").Count(&count)
+	}
+	if int(count) != len(uniqueIDs(ids)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La collection contient des partitions inexistantes ou privées"})
+		return false
+	}
+
+	collection.Title = request.Title
+	collection.Description = request.Description
+	collection.Items = make([]models.CollectionItem, 0, len(request.Items))
+	for i, item := range request.Items {
+		collection.Items = append(collection.Items, models.CollectionItem{
+			PartitionID: item.PartitionID,
+			Position:    i + 1,
+			Notes:       item.Notes,
+		})
+	}
+	return true
+}
+
+// uniqueIDs retourne les identifiants sans doublon
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	var unique []uint
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// CreateCollectionHandler crée une collection pour l'utilisateur connecté
+func CreateCollectionHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var request CollectionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	collection := models.Collection{OwnerID: user.ID}
+	if !applyCollectionRequest(c, request, &collection, user.ID) {
+		return
+	}
+
+	if err := lib.DB.Create(&collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement de la collection"})
+		return
+	}
+
+	lib.EnqueuePopularityRefresh(collectionPartitionIDs(collection)...)
+	lib.LogActionWithDetails("create_collection", user.Email, map[string]interface{}{"collection_id": collection.ID})
+
+	c.JSON(http.StatusCreated, gin.H{"collection": collection})
+}
+
+// GetMyCollectionsHandler liste les collections de l'utilisateur connecté
+func GetMyCollectionsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var collections []models.Collection
+	if err := lib.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where("owner_id = ?", user.ID).Order("updated_at DESC").Find(&collections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des collections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collections": collections})
+}
+
+// GetPublicCollectionsHandler liste les collections publiques
+func GetPublicCollectionsHandler(c *gin.Context) {
+	var collections []models.Collection
+	if err := lib.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where("visibility = ?", models.CollectionPublic).Order("updated_at DESC").Find(&collections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des collections"})
+		return
+	}
+
+	for i := range collections {
+		collections[i].ShareToken = ""
+	}
+	c.JSON(http.StatusOK, gin.H{"collections": collections})
+}
+
+// GetCollectionHandler renvoie une collection et ses partitions ; une collection
+// partagée par lien est accessible avec ?token=
+func GetCollectionHandler(c *gin.Context) {
+	collection, ok := loadCollection(c)
+	if !ok {
+		return
+	}
+
+	userID := optionalUserID(c)
+	if !canViewCollection(collection, userID, c.Query("token")) {
+		// Même réponse qu'une collection inexistante, pour ne pas révéler les collections privées
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection non trouvée"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collection": collectionResponse(collection, userID)})
+}
+
+// UpdateCollectionHandler modifie une collection de l'utilisateur connecté
+func UpdateCollectionHandler(c *gin.Context) {
+	collection, user, ok := loadOwnedCollection(c)
+	if !ok {
+		return
+	}
+	previousIDs := collectionPartitionIDs(collection)
+
+	var request CollectionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+	if !applyCollectionRequest(c, request, &collection, user.ID) {
+		return
+	}
+
+	err := lib.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&models.CollectionItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&collection).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour de la collection"})
+		return
+	}
+
+	lib.EnqueuePopularityRefresh(append(previousIDs, collectionPartitionIDs(collection)...)...)
+	lib.LogActionWithDetails("update_collection", user.Email, map[string]interface{}{"collection_id": collection.ID})
+
+	c.JSON(http.StatusOK, gin.H{"collection": collection})
+}
+
+// DeleteCollectionHandler supprime une collection de l'utilisateur connecté
+func DeleteCollectionHandler(c *gin.Context) {
+	collection, user, ok := loadOwnedCollection(c)
+	if !ok {
+		return
+	}
+
+	err := lib.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&models.CollectionItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Collection{}, collection.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression de la collection"})
+		return
+	}
+
+	lib.EnqueuePopularityRefresh(collectionPartitionIDs(collection)...)
+	lib.LogActionWithDetails("delete_collection", user.Email, map[string]interface{}{"collection_id": collection.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection supprimée avec succès"})
+}
+
+// DuplicateCollectionHandler copie une collection publique (ou une collection de
+// l'utilisateur) dans les collections privées de l'utilisateur connecté
+func DuplicateCollectionHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	source, ok := loadCollection(c)
+	if !ok {
+		return
+	}
+	if source.OwnerID != user.ID && source.Visibility != models.CollectionPublic {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection non trouvée"})
+		return
+	}
+
+	duplicate := models.Collection{
+		OwnerID:        user.ID,
+		Title:          fmt.Sprintf("%s (copie)", source.Title),
+		Description:    source.Description,
+		Visibility:     models.CollectionPrivate,
+		DuplicatedFrom: &source.ID,
+	}
+	for _, item := range source.Items {
+		duplicate.Items = append(duplicate.Items, models.CollectionItem{
+			PartitionID: item.PartitionID,
+			Position:    item.Position,
+			Notes:       item.Notes,
+		})
+	}
+
+	if err := lib.DB.Create(&duplicate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la copie de la collection"})
+		return
+	}
+
+	lib.EnqueuePopularityRefresh(collectionPartitionIDs(duplicate)...)
+	lib.LogActionWithDetails("duplicate_collection", user.Email, map[string]interface{}{"collection_id": duplicate.ID, "source_id": source.ID})
+
+	c.JSON(http.StatusCreated, gin.H{"collection": duplicate})
+}
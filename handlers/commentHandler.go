@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+)
+
+// CommentRequest représente un commentaire ou une réponse (ParentID renseigné)
+type CommentRequest struct {
+	Body     string `json:"body" binding:"required,max=5000"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// canModerateComments indique si l'utilisateur peut masquer ou supprimer les
+// commentaires d'une partition : son uploader et les modérateurs
+func canModerateComments(user models.User, partition models.Partition) bool {
+	return user.IsModerator() || (partition.UploadedBy != 0 && partition.UploadedBy == user.ID)
+}
+
+// GetPartitionCommentsHandler renvoie les commentaires d'une partition sous forme de fils de discussion ;
+// les commentaires masqués ne sont visibles que par leur auteur, l'uploader et les modérateurs
+func GetPartitionCommentsHandler(c *gin.Context) {
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	var viewer models.User
+	if userID := optionalUserID(c); userID != 0 {
+		lib.DB.First(&viewer, userID)
+	}
+	moderator := viewer.ID != 0 && canModerateComments(viewer, partition)
+
+	var comments []models.Comment
+	if err := lib.DB.Preload("User").Where("partition_id = ?", partition.ID).Order("created_at").Find(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des commentaires"})
+		return
+	}
+
+	var visible []models.Comment
+	for _, comment := range comments {
+		if comment.Hidden && !moderator && (viewer.ID == 0 || comment.UserID != viewer.ID) {
+			continue
+		}
+		if comment.User != nil {
+			comment.Author = comment.User.Username
+		}
+		visible = append(visible, comment)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"partition_id": partition.ID, "comments": commentThreads(visible)})
+}
+
+// commentThreads organise les commentaires en arbres de réponses ; une réponse dont le
+// commentaire parent n'est pas visible est rattachée à la racine
+func commentThreads(comments []models.Comment) []models.Comment {
+	visible := make(map[uint]bool, len(comments))
+	for _, comment := range comments {
+		visible[comment.ID] = true
+	}
+
+	children := make(map[uint][]models.Comment)
+	var roots []models.Comment
+	for _, comment := range comments {
+		if comment.ParentID != nil && visible[*comment.ParentID] {
+			children[*comment.ParentID] = append(children[*comment.ParentID], comment)
+		} else {
+			roots = append(roots, comment)
+		}
+	}
+
+	var attach func(comment models.Comment) models.Comment
+	attach = func(comment models.Comment) models.Comment {
+		for _, reply := range children[comment.ID] {
+			comment.Replies = append(comment.Replies, attach(reply))
+		}
+		return comment
+	}
+
+	threads := make([]models.Comment, 0, len(roots))
+	for _, root := range roots {
+		threads = append(threads, attach(root))
+	}
+	return threads
+}
+
+// CreateCommentHandler ajoute un commentaire, ou une réponse, sur une partition
+func CreateCommentHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	var request CommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	if request.ParentID != nil {
+		var parent models.Comment
+		if err := lib.DB.First(&parent, "id = ? AND partition_id = ?", *request.ParentID, partition.ID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Commentaire parent non trouvé"})
+			return
+		}
+	}
+
+	comment := models.Comment{
+		PartitionID: partition.ID,
+		UserID:      user.ID,
+		Author:      user.Username,
+		ParentID:    request.ParentID,
+		Body:        request.Body,
+	}
+	if err := lib.DB.Create(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement du commentaire"})
+		return
+	}
+
+	lib.LogActionWithDetails("comment_partition", user.Email, map[string]interface{}{"partition_id": partition.ID, "comment_id": comment.ID})
+
+	c.JSON(http.StatusCreated, gin.H{"comment": comment})
+}
+
+// loadModeratedComment charge le commentaire désigné par :id et la partition associée
+func loadModeratedComment(c *gin.Context) (models.Comment, models.Partition, models.User, bool) {
+	var comment models.Comment
+	var partition models.Partition
+
+	user, ok := currentUser(c)
+	if !ok {
+		return comment, partition, user, false
+	}
+	if err := lib.DB.First(&comment, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Commentaire non trouvé"})
+		return comment, partition, user, false
+	}
+	if err := lib.DB.First(&partition, comment.PartitionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partition non trouvée"})
+		return comment, partition, user, false
+	}
+	return comment, partition, user, true
+}
+
+// HideCommentHandler masque un commentaire (uploader de la partition ou modérateur)
+func HideCommentHandler(c *gin.Context) {
+	setCommentHidden(c, true)
+}
+
+// UnhideCommentHandler rend de nouveau visible un commentaire masqué
+func UnhideCommentHandler(c *gin.Context) {
+	setCommentHidden(c, false)
+}
+
+// setCommentHidden masque ou rétablit un commentaire
+func setCommentHidden(c *gin.Context, hidden bool) {
+	comment, partition, user, ok := loadModeratedComment(c)
+	if !ok {
+		return
+	}
+	if !canModerateComments(user, partition) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Seuls l'uploader de la partition et les modérateurs peuvent masquer un commentaire"})
+		return
+	}
+
+	var hiddenBy *uint
+	action := "unhide_comment"
+	if hidden {
+		hiddenBy = &user.ID
+		action = "hide_comment"
+	}
+	if err := lib.DB.Model(&comment).Updates(map[string]interface{}{"hidden": hidden, "hidden_by_id": hiddenBy}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour du commentaire"})
+		return
+	}
+	comment.Hidden, comment.HiddenByID = hidden, hiddenBy
+
+	lib.LogActionWithDetails(action, user.Email, map[string]interface{}{"partition_id": partition.ID, "comment_id": comment.ID})
+
+	c.JSON(http.StatusOK, gin.H{"comment": comment})
+}
+
+// DeleteCommentHandler supprime un commentaire et ses réponses (auteur, uploader de la partition ou modérateur)
+func DeleteCommentHandler(c *gin.Context) {
+	comment, partition, user, ok := loadModeratedComment(c)
+	if !ok {
+		return
+	}
+	if comment.UserID != user.ID && !canModerateComments(user, partition) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Vous ne pouvez pas supprimer ce commentaire"})
+		return
+	}
+
+	err := lib.DB.Transaction(func(tx *gorm.DB) error {
+		// Supprimer le fil de réponses, niveau par niveau
+		ids := []uint{comment.ID}
+		for level := ids; len(level) > 0; {
+			var replies []uint
+			if err := tx.Model(&models.Comment{}).Where("parent_id IN ?", level).Pluck("id", &replies).Error; err != nil {
+				return err
+			}
+			ids = append(ids, replies...)
+			level = replies
+		}
+		return tx.Where("id IN ?", ids).Delete(&models.Comment{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression du commentaire"})
+		return
+	}
+
+	lib.LogActionWithDetails("delete_comment", user.Email, map[string]interface{}{"partition_id": partition.ID, "comment_id": comment.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Commentaire supprimé avec succès"})
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"slices"
+	"solfa-back/lib"
+	"solfa-back/models"
+	"strings"
+)
+
+// ComposerRequest représente les informations d'un compositeur saisies par un modérateur
+type ComposerRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Aliases     []string `json:"aliases"`
+	BirthYear   int      `json:"birth_year"`
+	DeathYear   int      `json:"death_year"`
+	Nationality string   `json:"nationality"`
+}
+
+// MergeComposerRequest désigne le compositeur dans lequel fusionner un doublon
+type MergeComposerRequest struct {
+	IntoID uint `json:"into_id" binding:"required"`
+}
+
+// resolvePartitionComposer rattache la partition au compositeur correspondant à son champ
+// Composer et remplace ce champ par le nom canonique ; un nom inconnu est conservé tel quel,
+// sans compositeur, jusqu'à ce qu'un modérateur l'ajoute (voir lib.LinkPartitionComposers)
+func resolvePartitionComposer(partition *models.Partition) error {
+	composer, err := lib.ResolveComposer(partition.Composer)
+	if err != nil {
+		return err
+	}
+	if composer == nil {
+		partition.ComposerID = nil
+		return nil
+	}
+	partition.ComposerID = &composer.ID
+	partition.Composer = composer.Name
+	return nil
+}
+
+// loadComposer recherche le compositeur désigné par le paramètre :id
+func loadComposer(c *gin.Context) (models.Composer, bool) {
+	var composer models.Composer
+	if err := lib.DB.First(&composer, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Compositeur non trouvé"})
+		return composer, false
+	}
+	return composer, true
+}
+
+// GetComposersHandler liste les compositeurs avec leur nombre de partitions ; ?q= filtre
+// sur le nom canonique et les alias
+func GetComposersHandler(c *gin.Context) {
+	var composers []models.Composer
+	if err := lib.DB.Order("name").Find(&composers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des compositeurs"})
+		return
+	}
+
+	var counts []struct {
+		ComposerID uint
+		Count      int
+	}
+	lib.DB.Model(&models.Partition{}).
+		Scopes(visiblePartitionsScope(c)).
+		Select("composer_id, COUNT(*) AS count").
+		Where("composer_id IS NOT NULL").
+		Group("composer_id").
+		Scan(&counts)
+	partitionCounts := make(map[uint]int, len(counts))
+	for _, count := range counts {
+		partitionCounts[count.ComposerID] = count.Count
+	}
+
+	query := lib.NormalizeComposerName(c.Query("q"))
+	results := []gin.H{}
+	for _, composer := range composers {
+		if query != "" && !composerMatchesQuery(composer, query) {
+			continue
+		}
+		results = append(results, gin.H{
+			"composer":        composer,
+			"partition_count": partitionCounts[composer.ID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"composers": results})
+}
+
+// composerMatchesQuery indique si le nom canonique ou un alias contient la recherche (normalisée)
+func composerMatchesQuery(composer models.Composer, query string) bool {
+	for _, name := range append([]string{composer.Name}, composer.Aliases...) {
+		if strings.Contains(lib.NormalizeComposerName(name), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetComposerPartitionsHandler renvoie un compositeur et ses partitions
+func GetComposerPartitionsHandler(c *gin.Context) {
+	composer, ok := loadComposer(c)
+	if !ok {
+		return
+	}
+
+	var partitions []models.Partition
+	if err := lib.DB.Scopes(visiblePartitionsScope(c)).Where("composer_id = ?", composer.ID).Order("title").Find(&partitions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des partitions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"composer": composer, "partitions": partitions})
+}
+
+// CreateComposerHandler ajoute un compositeur (modérateurs)
+func CreateComposerHandler(c *gin.Context) {
+	moderator, ok := currentModerator(c)
+	if !ok {
+		return
+	}
+
+	var request ComposerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	composer := models.Composer{}
+	if !applyComposerRequest(c, request, &composer) {
+		return
+	}
+	if err := lib.DB.Create(&composer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement du compositeur"})
+		return
+	}
+	// Les partitions portant son nom ou un de ses alias lui sont rattachées
+	lib.EnqueueJob("link_partition_composers", lib.LinkPartitionComposers)
+
+	lib.LogActionWithDetails("create_composer", moderator.Email, map[string]interface{}{"composer_id": composer.ID})
+
+	c.JSON(http.StatusCreated, gin.H{"composer": composer})
+}
+
+// UpdateComposerHandler modifie un compositeur (modérateurs) ; un changement de nom
+// canonique est reporté sur ses partitions
+func UpdateComposerHandler(c *gin.Context) {
+	moderator, ok := currentModerator(c)
+	if !ok {
+		return
+	}
+	composer, ok := loadComposer(c)
+	if !ok {
+		return
+	}
+
+	var request ComposerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	previousName, previousDeathYear := composer.Name, composer.DeathYear
+	if !applyComposerRequest(c, request, &composer) {
+		return
+	}
+	if err := lib.DB.Save(&composer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour du compositeur"})
+		return
+	}
+	if composer.Name != previousName {
+		if err := lib.RenameComposerPartitions(composer); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour des partitions du compositeur"})
+			return
+		}
+	}
+	lib.EnqueueJob("link_partition_composers", lib.LinkPartitionComposers)
+
+	// La date de décès détermine le domaine public des partitions du compositeur
+	if composer.DeathYear != previousDeathYear {
+		enqueueComposerLicenceRefresh(composer.ID)
+	}
+
+	lib.LogActionWithDetails("update_composer", moderator.Email, map[string]interface{}{"composer_id": composer.ID})
+
+	c.JSON(http.StatusOK, gin.H{"composer": composer})
+}
+
+// applyComposerRequest valide la requête et reporte ses valeurs sur le compositeur
+func applyComposerRequest(c *gin.Context, request ComposerRequest, composer *models.Composer) bool {
+	name := lib.CanonicalComposerName(request.Name)
+	if err := lib.CheckComposerName(name, composer.ID); err != nil {
+		if errors.Is(err, lib.ErrComposerExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Un compositeur porte déjà ce nom"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la vérification du nom"})
+		}
+		return false
+	}
+	if request.BirthYear != 0 && request.DeathYear != 0 && request.DeathYear < request.BirthYear {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "L'année de décès précède l'année de naissance"})
+		return false
+	}
+
+	composer.Name = name
+	composer.Aliases = nil
+	for _, alias := range request.Aliases {
+		alias = strings.TrimSpace(alias)
+		if alias != "" && alias != name {
+			composer.Aliases = append(composer.Aliases, alias)
+		}
+	}
+	composer.BirthYear = request.BirthYear
+	composer.DeathYear = request.DeathYear
+	composer.Nationality = request.Nationality
+	lib.SetComposerSearchNames(composer)
+	return true
+}
+
+// MergeComposerHandler fusionne un compositeur en double dans un autre (modérateurs) :
+// ses partitions sont rattachées au compositeur conservé et ses noms deviennent des alias
+func MergeComposerHandler(c *gin.Context) {
+	moderator, ok := currentModerator(c)
+	if !ok {
+		return
+	}
+	duplicate, ok := loadComposer(c)
+	if !ok {
+		return
+	}
+
+	var request MergeComposerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+	var target models.Composer
+	if err := lib.DB.First(&target, request.IntoID).Error; err != nil || target.ID == duplicate.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Compositeur cible non trouvé"})
+		return
+	}
+
+	for _, alias := range append([]string{duplicate.Name}, duplicate.Aliases...) {
+		if alias != target.Name && !slices.Contains(target.Aliases, alias) {
+			target.Aliases = append(target.Aliases, alias)
+		}
+	}
+	lib.SetComposerSearchNames(&target)
+
+	if err := lib.MoveComposerPartitions(duplicate.ID, target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour des partitions du compositeur"})
+		return
+	}
+	err := lib.DB.Transaction(func(tx *gorm.DB) error {
+		// Le doublon est supprimé d'abord : ses noms deviennent des alias de la cible
+		if err := tx.Delete(&models.Composer{}, duplicate.ID).Error; err != nil {
+			return err
+		}
+		return tx.Save(&target).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la fusion des compositeurs"})
+		return
+	}
+
+	if target.DeathYear != duplicate.DeathYear {
+		enqueueComposerLicenceRefresh(target.ID)
+	}
+
+	lib.LogActionWithDetails("merge_composer", moderator.Email, map[string]interface{}{"composer_id": target.ID, "merged_id": duplicate.ID})
+
+	c.JSON(http.StatusOK, gin.H{"composer": target})
+}
+
+// enqueueComposerLicenceRefresh planifie le recalcul du statut juridique des partitions d'un compositeur
+func enqueueComposerLicenceRefresh(composerID uint) {
+	lib.EnqueueJob(fmt.Sprintf("licence_composer_%d", composerID), func() error {
+		return lib.RefreshPartitionsLicence(composerID)
+	})
+}
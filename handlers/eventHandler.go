@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+	"strings"
+	"time"
+)
+
+// Longueur des jetons des flux iCalendar (16 octets en hexadécimal, voir generateShareToken)
+const calendarTokenLength = 32
+
+// EventItemRequest représente une partition du programme d'un événement dans une requête
+type EventItemRequest struct {
+	PartitionID uint   `json:"partition_id" binding:"required"`
+	Notes       string `json:"notes"`
+}
+
+// EventRequest représente les données attendues pour créer ou modifier un événement ;
+// le programme est fourni dans l'ordre voulu et remplace le programme existant
+type EventRequest struct {
+	Kind          string             `json:"kind" binding:"required,oneof=rehearsal concert service"`
+	Title         string             `json:"title" binding:"required,max=200"`
+	StartsAt      time.Time          `json:"starts_at" binding:"required"`
+	EndsAt        *time.Time         `json:"ends_at"`
+	Location      string             `json:"location"`
+	Notes         string             `json:"notes"`
+	ReminderHours *int               `json:"reminder_hours" binding:"omitempty,min=0,max=336"` // 24 par défaut, 0 : aucun rappel
+	Items         []EventItemRequest `json:"items" binding:"dive"`
+}
+
+// loadEventAs charge l'événement désigné par :id, avec son programme, et vérifie que l'utilisateur
+// connecté a au moins le rôle demandé dans son organisation
+func loadEventAs(c *gin.Context, minimum string) (models.Event, models.User, bool) {
+	var event models.Event
+	user, ok := currentUser(c)
+	if !ok {
+		return event, user, false
+	}
+
+	role := ""
+	err := lib.DB.First(&event, "id = ?", c.Param("id")).Error
+	if err == nil {
+		role, err = lib.OrganizationRole(event.OrganizationID, user.ID)
+	}
+	if err == nil && role != "" {
+		err = lib.PreloadEventItems(lib.DB, []uint{event.OrganizationID}).First(&event, event.ID).Error
+	}
+	if err != nil || role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Événement non trouvé"})
+		return event, user, false
+	}
+	if !lib.HasOrganizationRole(role, minimum) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Action réservée " + organizationRoleLabels[minimum] + " de l'organisation"})
+		return event, user, false
+	}
+	return event, user, true
+}
+
+// applyEventRequest valide la requête et reporte ses valeurs sur l'événement
+func applyEventRequest(c *gin.Context, request EventRequest, event *models.Event) bool {
+	if request.EndsAt != nil && !request.EndsAt.After(request.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La fin de l'événement doit suivre son début"})
+		return false
+	}
+	title := strings.TrimSpace(request.Title)
+	if title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le titre de l'événement est requis"})
+		return false
+	}
+
+	// Le programme ne contient que des partitions du catalogue public ou de l'organisation
+	ids := make([]uint, 0, len(request.Items))
+	for _, item := range request.Items {
+		ids = append(ids, item.PartitionID)
+	}
+	var count int64
+	if len(ids) > 0 {
+		lib.DB.Model(&models.Partition{}).Scopes(lib.VisiblePartitions([]uint{event.OrganizationID})).Where("id IN ?", ids).Distinct("id").Count(&count)
+	}
+	if int(count) != len(uniqueIDs(ids)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le programme contient des partitions inexistantes ou privées"})
+		return false
+	}
+
+	reminderHours := event.ReminderHours
+	if request.ReminderHours != nil {
+		reminderHours = *request.ReminderHours
+	} else if event.ID == 0 {
+		reminderHours = lib.DefaultEventReminderHours
+	}
+	// Un événement déplacé ou dont le délai de rappel change fait l'objet d'un nouveau rappel
+	if !event.StartsAt.Equal(request.StartsAt) || event.ReminderHours != reminderHours {
+		event.RemindedAt = nil
+	}
+
+	event.Kind = request.Kind
+	event.Title = title
+	event.StartsAt = request.StartsAt
+	event.EndsAt = request.EndsAt
+	event.Location = strings.TrimSpace(request.Location)
+	event.Notes = request.Notes
+	event.ReminderHours = reminderHours
+	event.Items = make([]models.EventItem, 0, len(request.Items))
+	for i, item := range request.Items {
+		event.Items = append(event.Items, models.EventItem{
+			PartitionID: item.PartitionID,
+			Position:    i + 1,
+			Notes:       item.Notes,
+		})
+	}
+	return true
+}
+
+// parseEventDate lit une date AAAA-MM-JJ (début de journée) ou RFC 3339
+func parseEventDate(value string) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// eventsInRange restreint une requête aux événements de la période ?from= (aujourd'hui par défaut)
+// à ?to= ; répond 400 si une date est invalide
+func eventsInRange(c *gin.Context, db *gorm.DB) (*gorm.DB, bool) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if value := c.Query("from"); value != "" {
+		date, err := parseEventDate(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Date de début invalide (AAAA-MM-JJ)"})
+			return db, false
+		}
+		from = date
+	}
+	db = db.Where("starts_at >= ?", from)
+
+	if value := c.Query("to"); value != "" {
+		date, err := parseEventDate(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Date de fin invalide (AAAA-MM-JJ)"})
+			return db, false
+		}
+		db = db.Where("starts_at < ?", date)
+	}
+	return db.Order("starts_at"), true
+}
+
+// CreateEventHandler planifie un événement d'une organisation (chefs et propriétaires)
+func CreateEventHandler(c *gin.Context) {
+	organization, user, _, ok := loadOrganizationAs(c, models.OrganizationRoleDirector)
+	if !ok {
+		return
+	}
+
+	var request EventRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	event := models.Event{OrganizationID: organization.ID, CreatedBy: user.ID}
+	if !applyEventRequest(c, request, &event) {
+		return
+	}
+
+	if err := lib.DB.Create(&event).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de l'événement"})
+		return
+	}
+
+	lib.LogActionWithDetails("create_event", user.Email, map[string]interface{}{"organization_id": organization.ID, "event_id": event.ID})
+
+	c.JSON(http.StatusCreated, gin.H{"event": event})
+}
+
+// GetOrganizationEventsHandler liste les événements d'une organisation (membres), à venir par défaut
+func GetOrganizationEventsHandler(c *gin.Context) {
+	organization, _, _, ok := loadOrganizationAs(c, models.OrganizationRoleMember)
+	if !ok {
+		return
+	}
+
+	db, ok := eventsInRange(c, lib.PreloadEventItems(lib.DB, []uint{organization.ID}))
+	if !ok {
+		return
+	}
+	var events []models.Event
+	if err := db.Where("organization_id = ?", organization.ID).Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des événements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// GetMyEventsHandler liste les événements des organisations de l'utilisateur connecté, à venir par défaut
+func GetMyEventsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	organizationIDs, err := lib.UserOrganizationIDs(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des organisations"})
+		return
+	}
+
+	db, ok := eventsInRange(c, lib.PreloadEventItems(lib.DB, organizationIDs))
+	if !ok {
+		return
+	}
+	events := []models.Event{}
+	if len(organizationIDs) > 0 {
+		if err := db.Where("organization_id IN ?", organizationIDs).Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des événements"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// GetEventHandler renvoie un événement et son programme (membres de l'organisation)
+func GetEventHandler(c *gin.Context) {
+	event, _, ok := loadEventAs(c, models.OrganizationRoleMember)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"event": event})
+}
+
+// UpdateEventHandler modifie un événement (chefs et propriétaires de l'organisation)
+func UpdateEventHandler(c *gin.Context) {
+	event, user, ok := loadEventAs(c, models.OrganizationRoleDirector)
+	if !ok {
+		return
+	}
+
+	var request EventRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+	if !applyEventRequest(c, request, &event) {
+		return
+	}
+
+	err := lib.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("event_id = ?", event.ID).Delete(&models.EventItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&event).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour de l'événement"})
+		return
+	}
+
+	lib.LogActionWithDetails("update_event", user.Email, map[string]interface{}{"organization_id": event.OrganizationID, "event_id": event.ID})
+
+	c.JSON(http.StatusOK, gin.H{"event": event})
+}
+
+// DeleteEventHandler supprime un événement (chefs et propriétaires de l'organisation)
+func DeleteEventHandler(c *gin.Context) {
+	event, user, ok := loadEventAs(c, models.OrganizationRoleDirector)
+	if !ok {
+		return
+	}
+
+	err := lib.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("event_id = ?", event.ID).Delete(&models.EventItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Event{}, event.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression de l'événement"})
+		return
+	}
+
+	lib.LogActionWithDetails("delete_event", user.Email, map[string]interface{}{"organization_id": event.OrganizationID, "event_id": event.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Événement supprimé"})
+}
+
+// calendarFeeds renvoie les adresses des flux iCalendar de l'utilisateur et de chacune de ses organisations
+func calendarFeeds(c *gin.Context, user models.User) {
+	var organizations []models.Organization
+	if err := lib.DB.Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ?", user.ID).Order("organizations.name").Find(&organizations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des organisations"})
+		return
+	}
+
+	base := fmt.Sprintf("%s/calendars/%s", lib.AppURL(), *user.CalendarToken)
+	feeds := make([]gin.H, 0, len(organizations))
+	for _, organization := range organizations {
+		feeds = append(feeds, gin.H{
+			"organization_id": organization.ID,
+			"name":            organization.Name,
+			"url":             fmt.Sprintf("%s/organizations/%d/events.ics", base, organization.ID),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": base + "/events.ics", "organizations": feeds})
+}
+
+// GetCalendarFeedsHandler renvoie les adresses des flux iCalendar de l'utilisateur connecté,
+// à ajouter à son agenda ; le jeton des flux est créé à la première demande
+func GetCalendarFeedsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.CalendarToken == nil {
+		token, err := generateShareToken()
+		if err == nil {
+			err = lib.DB.Model(&user).UpdateColumn("calendar_token", token).Error
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création des flux iCalendar"})
+			return
+		}
+		user.CalendarToken = &token
+	}
+
+	calendarFeeds(c, user)
+}
+
+// ResetCalendarTokenHandler remplace le jeton des flux iCalendar de l'utilisateur connecté :
+// les adresses communiquées auparavant sont révoquées
+func ResetCalendarTokenHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	token, err := generateShareToken()
+	if err == nil {
+		err = lib.DB.Model(&user).UpdateColumn("calendar_token", token).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du renouvellement des flux iCalendar"})
+		return
+	}
+	user.CalendarToken = &token
+
+	lib.LogAction("reset_calendar_token", user.Email)
+
+	calendarFeeds(c, user)
+}
+
+// calendarUser recherche l'utilisateur propriétaire du jeton de flux :token ; un jeton vide ou
+// plus court que ceux générés par generateShareToken est refusé sans interroger la base
+func calendarUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	token := c.Param("token")
+	if len(token) < calendarTokenLength {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendrier non trouvé"})
+		return user, false
+	}
+	if err := lib.DB.Where("calendar_token = ?", token).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendrier non trouvé"})
+		return user, false
+	}
+	return user, true
+}
+
+// sendCalendar renvoie les événements récents et à venir des organisations données au format iCalendar
+func sendCalendar(c *gin.Context, name string, organizationIDs []uint, organizationNames map[uint]string) {
+	events := []models.Event{}
+	if len(organizationIDs) > 0 {
+		if err := lib.PreloadEventItems(lib.DB, organizationIDs).
+			Where("organization_id IN ? AND starts_at >= ?", organizationIDs, time.Now().Add(-lib.CalendarHistory)).
+			Order("starts_at").Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des événements"})
+			return
+		}
+	}
+
+	c.Header("Content-Disposition", "inline; filename=\"solfa.ics\"")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", lib.BuildCalendar(name, events, organizationNames))
+}
+
+// CalendarFeedHandler renvoie le flux iCalendar des événements de toutes les organisations
+// de l'utilisateur ; l'accès se fait par le jeton :token, les agendas n'envoyant pas de JWT
+func CalendarFeedHandler(c *gin.Context) {
+	user, ok := calendarUser(c)
+	if !ok {
+		return
+	}
+
+	var organizations []models.Organization
+	if err := lib.DB.Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ?", user.ID).Find(&organizations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des organisations"})
+		return
+	}
+	ids := make([]uint, 0, len(organizations))
+	names := make(map[uint]string, len(organizations))
+	for _, organization := range organizations {
+		ids = append(ids, organization.ID)
+		names[organization.ID] = organization.Name
+	}
+
+	sendCalendar(c, "Solfa", ids, names)
+}
+
+// OrganizationCalendarFeedHandler renvoie le flux iCalendar des événements d'une organisation
+// dont le détenteur du jeton :token est membre
+func OrganizationCalendarFeedHandler(c *gin.Context) {
+	user, ok := calendarUser(c)
+	if !ok {
+		return
+	}
+
+	var organization models.Organization
+	role := ""
+	err := lib.DB.First(&organization, "id = ?", c.Param("id")).Error
+	if err == nil {
+		role, err = lib.OrganizationRole(organization.ID, user.ID)
+	}
+	if err != nil || role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendrier non trouvé"})
+		return
+	}
+
+	sendCalendar(c, organization.Name, []uint{organization.ID}, nil)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+	"time"
+)
+
+// Nombre maximal d'entrées renvoyées par les historiques
+const historyLimit = 50
+
+// AddFavoriteHandler ajoute une partition aux favoris de l'utilisateur connecté
+func AddFavoriteHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	favorite := models.Favorite{UserID: user.ID, PartitionID: partition.ID}
+	result := lib.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&favorite)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'ajout aux favoris"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Partition déjà dans les favoris"})
+		return
+	}
+
+	lib.EnqueuePopularityRefresh(partition.ID)
+	lib.LogActionWithDetails("add_favorite", user.Email, map[string]interface{}{"partition_id": partition.ID})
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Partition ajoutée aux favoris"})
+}
+
+// RemoveFavoriteHandler retire une partition des favoris de l'utilisateur connecté
+func RemoveFavoriteHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	result := lib.DB.Where("user_id = ? AND partition_id = ?", user.ID, partition.ID).Delete(&models.Favorite{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression du favori"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cette partition n'est pas dans vos favoris"})
+		return
+	}
+
+	lib.EnqueuePopularityRefresh(partition.ID)
+	lib.LogActionWithDetails("remove_favorite", user.Email, map[string]interface{}{"partition_id": partition.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Partition retirée des favoris"})
+}
+
+// GetMyFavoritesHandler liste les favoris de l'utilisateur connecté, du plus récent au plus ancien
+func GetMyFavoritesHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var favorites []models.Favorite
+	// Les partitions privées d'une organisation quittée ne sont plus renvoyées
+	if err := lib.DB.Preload("Partition", visiblePartitionsScope(c)).Where("user_id = ?", user.ID).Order("created_at DESC").Find(&favorites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des favoris"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"favorites": favorites})
+}
+
+// GetRecentlyViewedHandler liste les dernières partitions consultées par l'utilisateur connecté
+func GetRecentlyViewedHandler(c *gin.Context) {
+	getPartitionHistory(c, models.HistoryViewed)
+}
+
+// GetRecentlyDownloadedHandler liste les dernières partitions téléchargées par l'utilisateur connecté
+func GetRecentlyDownloadedHandler(c *gin.Context) {
+	getPartitionHistory(c, models.HistoryDownloaded)
+}
+
+// getPartitionHistory renvoie l'historique de l'utilisateur connecté pour le type donné
+func getPartitionHistory(c *gin.Context, kind string) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var history []models.PartitionHistory
+	if err := lib.DB.Preload("Partition", visiblePartitionsScope(c)).
+		Where("user_id = ? AND kind = ?", user.ID, kind).
+		Order("last_at DESC").Limit(historyLimit).
+		Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture de l'historique"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
+// recordPartitionHistory met à jour l'historique d'un utilisateur ; une erreur
+// est journalisée sans interrompre la requête en cours
+func recordPartitionHistory(userID, partitionID uint, kind string) {
+	if userID == 0 {
+		return
+	}
+
+	entry := models.PartitionHistory{UserID: userID, PartitionID: partitionID, Kind: kind, Count: 1, LastAt: time.Now()}
+	err := lib.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "partition_id"}, {Name: "kind"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"last_at": entry.LastAt,
+			"count":   clause.Expr{SQL: "partition_histories.count + 1"},
+		}),
+	}).Create(&entry).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"partition_id": partitionID,
+			"kind":         kind,
+			"error":        err.Error(),
+		}).Error("Erreur lors de l'enregistrement de l'historique")
+	}
+}
@@ -0,0 +1,700 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"slices"
+	"solfa-back/lib"
+	"solfa-back/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OrganizationRequest représente les données attendues pour créer ou modifier une organisation
+type OrganizationRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Kind        string `json:"kind" binding:"omitempty,oneof=choir parish school"`
+	Description string `json:"description"`
+}
+
+// InvitationRequest représente une invitation à rejoindre une organisation
+type InvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"omitempty,oneof=member director owner"` // "member" par défaut
+}
+
+// MemberRoleRequest représente le nouveau rôle d'un membre
+type MemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=member director owner"`
+}
+
+// PartitionOrganizationRequest désigne la bibliothèque d'une partition (0 : catalogue public)
+type PartitionOrganizationRequest struct {
+	OrganizationID uint `json:"organization_id"`
+}
+
+// organizationRoleLabels nomme les rôles dans les messages d'erreur
+var organizationRoleLabels = map[string]string{
+	models.OrganizationRoleMember:   "aux membres",
+	models.OrganizationRoleDirector: "aux chefs",
+	models.OrganizationRoleOwner:    "aux propriétaires",
+}
+
+// loadOrganizationAs charge l'organisation désignée par :id et vérifie que l'utilisateur connecté
+// y a au moins le rôle demandé ; une organisation dont il n'est pas membre est introuvable
+func loadOrganizationAs(c *gin.Context, minimum string) (models.Organization, models.User, string, bool) {
+	var organization models.Organization
+	user, ok := currentUser(c)
+	if !ok {
+		return organization, user, "", false
+	}
+
+	role := ""
+	err := lib.DB.First(&organization, "id = ?", c.Param("id")).Error
+	if err == nil {
+		role, err = lib.OrganizationRole(organization.ID, user.ID)
+	}
+	if err != nil || role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organisation non trouvée"})
+		return organization, user, role, false
+	}
+	if !lib.HasOrganizationRole(role, minimum) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Action réservée " + organizationRoleLabels[minimum] + " de l'organisation"})
+		return organization, user, role, false
+	}
+	return organization, user, role, true
+}
+
+// visiblePartitionsScope restreint une requête aux partitions visibles par l'utilisateur connecté
+func visiblePartitionsScope(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	organizationIDs, err := lib.UserOrganizationIDs(optionalUserID(c))
+	if err != nil {
+		organizationIDs = nil // Seul le catalogue public reste visible
+	}
+	return lib.VisiblePartitions(organizationIDs)
+}
+
+// partitionVisible indique si une partition est visible par l'utilisateur connecté
+func partitionVisible(c *gin.Context, partition models.Partition) bool {
+	visible, err := lib.CanViewPartition(partition, optionalUserID(c))
+	return err == nil && visible
+}
+
+// applyOrganizationRequest valide la requête et reporte ses valeurs sur l'organisation
+func applyOrganizationRequest(c *gin.Context, request OrganizationRequest, organization *models.Organization) bool {
+	slug := lib.Slugify(request.Name)
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le nom de l'organisation est requis"})
+		return false
+	}
+	var count int64
+	if err := lib.DB.Model(&models.Organization{}).Where("slug = ? AND id <> ?", slug, organization.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des organisations"})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Une organisation porte déjà ce nom"})
+		return false
+	}
+
+	organization.Name = strings.TrimSpace(request.Name)
+	organization.Slug = slug
+	organization.Kind = request.Kind
+	organization.Description = request.Description
+	return true
+}
+
+// CreateOrganizationHandler crée une organisation dont l'utilisateur connecté devient propriétaire
+func CreateOrganizationHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var request OrganizationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	organization := models.Organization{CreatedBy: user.ID}
+	if !applyOrganizationRequest(c, request, &organization) {
+		return
+	}
+
+	err := lib.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&organization).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrganizationMember{
+			OrganizationID: organization.ID,
+			UserID:         user.ID,
+			Role:           models.OrganizationRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de l'organisation"})
+		return
+	}
+
+	lib.LogActionWithDetails("create_organization", user.Email, map[string]interface{}{"organization_id": organization.ID})
+
+	c.JSON(http.StatusCreated, gin.H{"organization": organization, "role": models.OrganizationRoleOwner})
+}
+
+// GetMyOrganizationsHandler liste les organisations de l'utilisateur connecté, avec son rôle
+func GetMyOrganizationsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var memberships []models.OrganizationMember
+	if err := lib.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&memberships).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des organisations"})
+		return
+	}
+	roles := make(map[uint]string, len(memberships))
+	ids := make([]uint, 0, len(memberships))
+	for _, membership := range memberships {
+		roles[membership.OrganizationID] = membership.Role
+		ids = append(ids, membership.OrganizationID)
+	}
+
+	var organizations []models.Organization
+	if err := lib.DB.Where("id IN ?", ids).Order("name").Find(&organizations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des organisations"})
+		return
+	}
+
+	results := make([]gin.H, 0, len(organizations))
+	for _, organization := range organizations {
+		results = append(results, gin.H{"organization": organization, "role": roles[organization.ID]})
+	}
+	c.JSON(http.StatusOK, gin.H{"organizations": results})
+}
+
+// GetOrganizationHandler renvoie une organisation et ses membres (membres de l'organisation)
+func GetOrganizationHandler(c *gin.Context) {
+	organization, _, role, ok := loadOrganizationAs(c, models.OrganizationRoleMember)
+	if !ok {
+		return
+	}
+
+	var members []models.OrganizationMember
+	if err := lib.DB.Preload("User").Where("organization_id = ?", organization.ID).Order("created_at").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des membres"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization": organization, "role": role, "members": members})
+}
+
+// UpdateOrganizationHandler modifie une organisation (propriétaires)
+func UpdateOrganizationHandler(c *gin.Context) {
+	organization, user, _, ok := loadOrganizationAs(c, models.OrganizationRoleOwner)
+	if !ok {
+		return
+	}
+
+	var request OrganizationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+	if !applyOrganizationRequest(c, request, &organization) {
+		return
+	}
+
+	if err := lib.DB.Save(&organization).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour de l'organisation"})
+		return
+	}
+
+	lib.LogActionWithDetails("update_organization", user.Email, map[string]interface{}{"organization_id": organization.ID})
+
+	c.JSON(http.StatusOK, gin.H{"organization": organization})
+}
+
+// DeleteOrganizationHandler supprime une organisation (propriétaires) et ses événements ; ses partitions
+// privées doivent d'abord être supprimées ou publiées, ses collections redeviennent privées
+func DeleteOrganizationHandler(c *gin.Context) {
+	organization, user, _, ok := loadOrganizationAs(c, models.OrganizationRoleOwner)
+	if !ok {
+		return
+	}
+
+	var count int64
+	if err := lib.DB.Model(&models.Partition{}).Where("organization_id = ?", organization.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des partitions"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("L'organisation possède encore %d partition(s) privée(s)", count)})
+		return
+	}
+
+	err := lib.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Collection{}).Where("organization_id = ?", organization.ID).
+			Updates(map[string]interface{}{"organization_id": nil, "visibility": models.CollectionPrivate}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id IN (?)", tx.Model(&models.Event{}).Select("id").Where("organization_id = ?", organization.ID)).
+			Delete(&models.EventItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", organization.ID).Delete(&models.Event{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Annotation{}).Where("organization_id = ?", organization.ID).Update("organization_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", organization.ID).Delete(&models.OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", organization.ID).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Organization{}, organization.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression de l'organisation"})
+		return
+	}
+
+	lib.LogActionWithDetails("delete_organization", user.Email, map[string]interface{}{"organization_id": organization.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organisation supprimée"})
+}
+
+// GetOrganizationCollectionsHandler liste les collections partagées avec les membres d'une organisation
+func GetOrganizationCollectionsHandler(c *gin.Context) {
+	organization, _, _, ok := loadOrganizationAs(c, models.OrganizationRoleMember)
+	if !ok {
+		return
+	}
+
+	var collections []models.Collection
+	if err := lib.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where("organization_id = ? AND visibility = ?", organization.ID, models.CollectionOrganization).
+		Order("updated_at DESC").Find(&collections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des collections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collections": collections})
+}
+
+// CreateInvitationHandler invite une personne par email à rejoindre une organisation ;
+// les chefs invitent des membres, les propriétaires peuvent inviter à tous les rôles
+func CreateInvitationHandler(c *gin.Context) {
+	organization, user, role, ok := loadOrganizationAs(c, models.OrganizationRoleDirector)
+	if !ok {
+		return
+	}
+
+	var request InvitationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+	if request.Role == "" {
+		request.Role = models.OrganizationRoleMember
+	}
+	if request.Role != models.OrganizationRoleMember && role != models.OrganizationRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Seuls les propriétaires peuvent inviter des chefs ou des propriétaires"})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(request.Email))
+	var count int64
+	if err := lib.DB.Model(&models.OrganizationMember{}).
+		Joins("JOIN users ON users.id = organization_members.user_id").
+		Where("organization_members.organization_id = ? AND LOWER(users.email) = ?", organization.ID, email).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des membres"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cette personne est déjà membre de l'organisation"})
+		return
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la génération de l'invitation"})
+		return
+	}
+	invitation := models.OrganizationInvitation{
+		OrganizationID: organization.ID,
+		Email:          email,
+		Role:           request.Role,
+		Token:          token,
+		InvitedBy:      user.ID,
+		ExpiresAt:      time.Now().Add(lib.OrganizationInvitationValidity),
+	}
+	if err := lib.DB.Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement de l'invitation"})
+		return
+	}
+
+	inviter := user.Username
+	if inviter == "" {
+		inviter = user.Email
+	}
+	lib.EnqueueJob(fmt.Sprintf("invitation_%d", invitation.ID), func() error {
+		return lib.SendOrganizationInvitation(invitation, organization, inviter)
+	})
+	lib.LogActionWithDetails("invite_organization_member", user.Email, map[string]interface{}{
+		"organization_id": organization.ID,
+		"email":           invitation.Email,
+		"role":            invitation.Role,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"invitation": invitation})
+}
+
+// GetInvitationsHandler liste les invitations en attente d'une organisation (chefs et propriétaires)
+func GetInvitationsHandler(c *gin.Context) {
+	organization, _, _, ok := loadOrganizationAs(c, models.OrganizationRoleDirector)
+	if !ok {
+		return
+	}
+
+	var invitations []models.OrganizationInvitation
+	if err := lib.DB.Where("organization_id = ? AND accepted_at IS NULL AND expires_at > ?", organization.ID, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// DeleteInvitationHandler annule une invitation en attente (chefs et propriétaires)
+func DeleteInvitationHandler(c *gin.Context) {
+	organization, user, _, ok := loadOrganizationAs(c, models.OrganizationRoleDirector)
+	if !ok {
+		return
+	}
+
+	result := lib.DB.Where("id = ? AND organization_id = ? AND accepted_at IS NULL", c.Param("invitation"), organization.ID).
+		Delete(&models.OrganizationInvitation{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'annulation de l'invitation"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation non trouvée"})
+		return
+	}
+
+	lib.LogActionWithDetails("cancel_organization_invitation", user.Email, map[string]interface{}{"organization_id": organization.ID, "invitation_id": c.Param("invitation")})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation annulée"})
+}
+
+// loadPendingInvitation recherche l'invitation en attente désignée par le jeton :token, avec son organisation
+func loadPendingInvitation(c *gin.Context) (models.OrganizationInvitation, models.Organization, bool) {
+	var invitation models.OrganizationInvitation
+	var organization models.Organization
+	err := lib.DB.Where("token = ? AND accepted_at IS NULL AND expires_at > ?", c.Param("token"), time.Now()).First(&invitation).Error
+	if err == nil {
+		err = lib.DB.First(&organization, invitation.OrganizationID).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation non trouvée ou expirée"})
+		return invitation, organization, false
+	}
+	return invitation, organization, true
+}
+
+// GetInvitationHandler renvoie l'organisation et le rôle proposés par une invitation
+func GetInvitationHandler(c *gin.Context) {
+	invitation, organization, ok := loadPendingInvitation(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization": gin.H{"id": organization.ID, "name": organization.Name, "kind": organization.Kind},
+		"role":         invitation.Role,
+		"expires_at":   invitation.ExpiresAt,
+	})
+}
+
+// AcceptInvitationHandler fait rejoindre l'organisation à l'utilisateur connecté, dont l'adresse
+// email doit être celle de l'invitation ; un membre existant conserve son rôle s'il est plus étendu
+func AcceptInvitationHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	invitation, organization, ok := loadPendingInvitation(c)
+	if !ok {
+		return
+	}
+	if !strings.EqualFold(strings.TrimSpace(user.Email), invitation.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cette invitation a été envoyée à une autre adresse email"})
+		return
+	}
+
+	role, err := lib.OrganizationRole(organization.ID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des membres"})
+		return
+	}
+	if !lib.HasOrganizationRole(role, invitation.Role) {
+		role = invitation.Role
+	}
+
+	now := time.Now()
+	err = lib.DB.Transaction(func(tx *gorm.DB) error {
+		member := models.OrganizationMember{OrganizationID: organization.ID, UserID: user.ID, Role: role}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "organization_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role"}),
+		}).Create(&member).Error; err != nil {
+			return err
+		}
+		return tx.Model(&invitation).Update("accepted_at", now).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'acceptation de l'invitation"})
+		return
+	}
+
+	lib.LogActionWithDetails("join_organization", user.Email, map[string]interface{}{"organization_id": organization.ID, "role": role})
+
+	c.JSON(http.StatusOK, gin.H{"organization": organization, "role": role})
+}
+
+// loadOrganizationMember recherche le membre :user de l'organisation donnée
+func loadOrganizationMember(c *gin.Context, organization models.Organization) (models.OrganizationMember, bool) {
+	var member models.OrganizationMember
+	if err := lib.DB.Where("organization_id = ? AND user_id = ?", organization.ID, c.Param("user")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Membre non trouvé"})
+		return member, false
+	}
+	return member, true
+}
+
+// lastOwner indique si le membre est le dernier propriétaire de l'organisation ; répond 409 dans ce cas
+func lastOwner(c *gin.Context, member models.OrganizationMember) bool {
+	if member.Role != models.OrganizationRoleOwner {
+		return false
+	}
+	owners, err := lib.CountOrganizationOwners(member.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des membres"})
+		return true
+	}
+	if owners <= 1 {
+		c.JSON(http.StatusConflict, gin.H{"error": "L'organisation doit conserver au moins un propriétaire"})
+		return true
+	}
+	return false
+}
+
+// UpdateMemberHandler modifie le rôle d'un membre (propriétaires)
+func UpdateMemberHandler(c *gin.Context) {
+	organization, user, _, ok := loadOrganizationAs(c, models.OrganizationRoleOwner)
+	if !ok {
+		return
+	}
+	member, ok := loadOrganizationMember(c, organization)
+	if !ok {
+		return
+	}
+
+	var request MemberRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+	if request.Role != models.OrganizationRoleOwner && lastOwner(c, member) {
+		return
+	}
+
+	if err := lib.DB.Model(&member).Update("role", request.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour du rôle"})
+		return
+	}
+
+	lib.LogActionWithDetails("update_organization_member", user.Email, map[string]interface{}{
+		"organization_id": organization.ID,
+		"user_id":         member.UserID,
+		"role":            request.Role,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"member": member})
+}
+
+// RemoveMemberHandler retire un membre d'une organisation ; réservé aux propriétaires,
+// chaque membre pouvant aussi quitter l'organisation
+func RemoveMemberHandler(c *gin.Context) {
+	organization, user, role, ok := loadOrganizationAs(c, models.OrganizationRoleMember)
+	if !ok {
+		return
+	}
+	member, ok := loadOrganizationMember(c, organization)
+	if !ok {
+		return
+	}
+	if member.UserID != user.ID && role != models.OrganizationRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Action réservée aux propriétaires de l'organisation"})
+		return
+	}
+	if lastOwner(c, member) {
+		return
+	}
+
+	// Les annotations partagées par le membre redeviennent privées
+	err := lib.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Annotation{}).Where("organization_id = ? AND user_id = ?", member.OrganizationID, member.UserID).
+			Update("organization_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Where("organization_id = ? AND user_id = ?", member.OrganizationID, member.UserID).
+			Delete(&models.OrganizationMember{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du retrait du membre"})
+		return
+	}
+
+	lib.LogActionWithDetails("remove_organization_member", user.Email, map[string]interface{}{"organization_id": organization.ID, "user_id": member.UserID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Membre retiré de l'organisation"})
+}
+
+// SetPartitionOrganizationHandler place une partition dans la bibliothèque privée d'une
+// organisation, ou la publie dans le catalogue public (organization_id à 0) ; réservé aux
+// chefs et propriétaires des organisations concernées (voir partitionMovable)
+func SetPartitionOrganizationHandler(c *gin.Context) {
+	partition, user, ok := loadEditablePartition(c)
+	if !ok {
+		return
+	}
+
+	var request PartitionOrganizationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	var organizationIDs []uint
+	if partition.OrganizationID != nil {
+		organizationIDs = append(organizationIDs, *partition.OrganizationID)
+	}
+	if request.OrganizationID != 0 {
+		organizationIDs = append(organizationIDs, request.OrganizationID)
+	}
+	for _, organizationID := range organizationIDs {
+		role, err := lib.OrganizationRole(organizationID, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des membres"})
+			return
+		}
+		if !lib.HasOrganizationRole(role, models.OrganizationRoleDirector) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Action réservée aux chefs des organisations concernées"})
+			return
+		}
+	}
+
+	var organizationID *uint
+	if request.OrganizationID != 0 {
+		organizationID = &request.OrganizationID
+	}
+	if !partitionMovable(c, partition, organizationID) {
+		return
+	}
+	if err := lib.DB.Model(&models.Partition{ID: partition.ID}).UpdateColumn("organization_id", organizationID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour de la partition"})
+		return
+	}
+	if err := lib.UpdatePartitionFieldsInES(partition.ID, map[string]interface{}{"organization_id": organizationID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour dans Elasticsearch"})
+		return
+	}
+
+	lib.LogActionWithDetails("set_partition_organization", user.Email, map[string]interface{}{"partition_id": partition.ID, "organization_id": request.OrganizationID})
+
+	partition.OrganizationID = organizationID
+	c.JSON(http.StatusOK, gin.H{"partition": partition})
+}
+
+// partitionMovable vérifie qu'une partition peut rejoindre une bibliothèque (nil : catalogue public)
+// sans être exposée : elle ne doit figurer ni dans une collection publique, partagée par lien ou
+// d'une autre organisation, ni être reliée à une partition d'une autre bibliothèque ; répond 409 sinon
+func partitionMovable(c *gin.Context, partition models.Partition, organizationID *uint) bool {
+	var collections int64
+	if organizationID != nil {
+		if err := lib.DB.Model(&models.CollectionItem{}).
+			Joins("JOIN collections ON collections.id = collection_items.collection_id").
+			Where("collection_items.partition_id = ?", partition.ID).
+			Where("collections.visibility IN ? OR (collections.visibility = ? AND collections.organization_id <> ?)",
+				[]string{models.CollectionPublic, models.CollectionLink}, models.CollectionOrganization, *organizationID).
+			Count(&collections).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des collections"})
+			return false
+		}
+	}
+	if collections > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("La partition figure dans %d collection(s) partagée(s) hors de l'organisation", collections)})
+		return false
+	}
+
+	var relations int64
+	if err := lib.DB.Model(&models.PartitionRelation{}).
+		Joins("JOIN partitions ON partitions.id = CASE WHEN partition_relations.partition_id = ? THEN partition_relations.related_id ELSE partition_relations.partition_id END", partition.ID).
+		Where("(partition_relations.partition_id = ? OR partition_relations.related_id = ?) AND partitions.organization_id IS DISTINCT FROM ?",
+			partition.ID, partition.ID, organizationID).
+		Count(&relations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des relations"})
+		return false
+	}
+	if relations > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("La partition est reliée à %d partition(s) d'une autre bibliothèque", relations)})
+		return false
+	}
+	return true
+}
+
+// organizationSearchFilters restreint une recherche aux partitions visibles par l'utilisateur
+// connecté et, avec ?organization=, à la bibliothèque d'une de ses organisations
+func organizationSearchFilters(c *gin.Context) ([]interface{}, error) {
+	organizationIDs, err := lib.UserOrganizationIDs(optionalUserID(c))
+	if err != nil {
+		return nil, err
+	}
+
+	visibility := []interface{}{
+		map[string]interface{}{"bool": map[string]interface{}{
+			"must_not": map[string]interface{}{"exists": map[string]interface{}{"field": "organization_id"}},
+		}},
+	}
+	if len(organizationIDs) > 0 {
+		visibility = append(visibility, map[string]interface{}{"terms": map[string]interface{}{"organization_id": organizationIDs}})
+	}
+	filters := []interface{}{
+		map[string]interface{}{"bool": map[string]interface{}{"should": visibility, "minimum_should_match": 1}},
+	}
+
+	if value := c.Query("organization"); value != "" {
+		organizationID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || !slices.Contains(organizationIDs, uint(organizationID)) {
+			return nil, errOrganizationNotFound
+		}
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"organization_id": organizationID}})
+	}
+	return filters, nil
+}
+
+// errOrganizationNotFound est renvoyée pour une organisation inconnue ou dont l'utilisateur n'est pas membre
+var errOrganizationNotFound = errors.New("organisation non trouvée")
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+)
+
+// loadPartitionScore lit la version MusicXML d'une partition
+func loadPartitionScore(c *gin.Context, partition models.Partition) ([]byte, lib.MusicXMLScore, bool) {
+	if partition.MusicXMLPath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Aucune version MusicXML disponible pour cette partition"})
+		return nil, lib.MusicXMLScore{}, false
+	}
+
+	content, err := lib.GetObjectContent(c, partition.MusicXMLPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture sur Minio " + err.Error()})
+		return nil, lib.MusicXMLScore{}, false
+	}
+
+	score, err := lib.ParseMusicXML(content)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Version MusicXML illisible: " + err.Error()})
+		return nil, lib.MusicXMLScore{}, false
+	}
+
+	return content, score, true
+}
+
+// GetPartitionPartsHandler liste les parties (voix, instruments) d'une partition MusicXML, avec les mêmes
+// restrictions que le téléchargement
+func GetPartitionPartsHandler(c *gin.Context) {
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+	if !authorizePartitionDownload(c, &partition) {
+		return
+	}
+
+	_, score, ok := loadPartitionScore(c, partition)
+	if !ok {
+		return
+	}
+
+	parts := []gin.H{}
+	for _, scorePart := range score.PartList.ScoreParts {
+		instrument := ""
+		if len(scorePart.Instruments) > 0 {
+			instrument = scorePart.Instruments[0].Name
+		}
+		parts = append(parts, gin.H{
+			"id":         scorePart.ID,
+			"name":       scorePart.Name,
+			"instrument": instrument,
+			"url":        fmt.Sprintf("/partitions/%d/parts/%s", partition.ID, scorePart.ID),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"partition_id": partition.ID,
+		"parts":        parts,
+	})
+}
+
+// GetPartitionPartHandler renvoie une partie isolée (ex: Tenor) sous forme de document MusicXML.
+// La partie extraite est conservée sur Minio pour les demandes suivantes.
+func GetPartitionPartHandler(c *gin.Context) {
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+	if !authorizePartitionDownload(c, &partition) {
+		return
+	}
+
+	content, score, ok := loadPartitionScore(c, partition)
+	if !ok {
+		return
+	}
+
+	scorePart, found := score.FindPart(c.Param("part"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partie non trouvée"})
+		return
+	}
+
+	// La version fait partie de la clé : un fichier remplacé ou restauré n'utilise pas les anciennes parties
+	partPath := fmt.Sprintf("partitions/derived/parts/%d_v%d_%s.musicxml", partition.ID, partition.Version, scorePart.ID)
+	var partContent []byte
+	var err error
+	if lib.ObjectExists(c, partPath) {
+		partContent, err = lib.GetObjectContent(c, partPath)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture sur Minio " + err.Error()})
+			return
+		}
+	} else {
+		partContent, err = lib.ExtractMusicXMLPart(content, scorePart.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'extraction de la partie: " + err.Error()})
+			return
+		}
+		if err := lib.PutObjectContent(c, partPath, partContent, lib.FormatContentType(lib.FormatMusicXML)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de téléchargement sur Minio " + err.Error()})
+			return
+		}
+	}
+
+	setLicenceHeaders(c, partition)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"partition_%d_%s.musicxml\"", partition.ID, scorePart.ID))
+	c.Data(http.StatusOK, lib.FormatContentType(lib.FormatMusicXML), partContent)
+}
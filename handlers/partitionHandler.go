@@ -9,18 +9,50 @@ import (
 	"time"
 	"github.com/minio/minio-go/v7"
 	"encoding/json"
+	"bytes"
+	"strconv"
+	"path/filepath"
 	"strings"
-	
+	"errors"
 )
 
+// uploadedPartition représente une partition à enregistrer à partir du fichier uploadé
+// (un fichier ABC pouvant contenir plusieurs morceaux, un upload peut en produire plusieurs)
+type uploadedPartition struct {
+	partition models.Partition
+	filename  string
+	content   []byte
+	musicXML  []byte // Version MusicXML dérivée, le cas échéant
+}
+
 func UploadPartitionHandler(c *gin.Context) {
 	// Récupérer les informations JSON et le fichier
 	var request struct {
-		Title       string `json:"title" binding:"required"`
+		Title       string `json:"title"` // Facultatif pour les formats qui portent leur titre (ABC, MusicXML, PDF)
 		Composer    string `json:"composer"`
 		Genre       string `json:"genre"`
 		Category    string `json:"category"`
+		LiturgicalSeasons []string `json:"liturgical_seasons"`
+		Occasions   []string `json:"occasions"`
+		Voicing     string `json:"voicing"` // Ex: "SATB", "SSA", "unison"
+		Divisi      bool   `json:"divisi"`
+		Accompaniment string `json:"accompaniment"` // "a_cappella", "piano", "organ" ou "instruments"
+		Instrumentation []string `json:"instrumentation"`
+		Soloists    []string `json:"soloists"`
+		Language    string `json:"language"` // Langue du titre, code ISO 639-1 ou nom ("fr", "latin")
+		Description string `json:"description"`
+		Translations []models.TitleTranslation `json:"translations"`
+		LyricsLanguages []string `json:"lyrics_languages"` // Déduites du fichier si absentes
+		Difficulty  int    `json:"difficulty"` // De 1 à 5 ; estimé à partir du fichier s'il est absent
+		Licence     string `json:"licence"` // "public_domain", "cc_by", "cc_by_sa", "all_rights_reserved" ou "publisher"
+		Publisher   string `json:"publisher"`
+		Permission  string `json:"permission"`
+		Source      string `json:"source"`
+		Edition     string `json:"edition"`
+		Arranger    string `json:"arranger"`
+		ArrangerDeathYear int `json:"arranger_death_year"`
 		ReleaseDate string `json:"release_date"`
+		OrganizationID uint `json:"organization_id"` // Bibliothèque privée d'une organisation (chefs et propriétaires)
 	}
 
 	// Lier la requête JSON
@@ -29,23 +61,41 @@ func UploadPartitionHandler(c *gin.Context) {
 		return
 	}
 
-	// Vérifier si la partition existe déjà dans Elasticsearch
-	partitionExists, existingPartition := lib.SearchPartitionByFields(models.Partition{
-		Title:    request.Title,
-		Composer: request.Composer,
-		Genre:    request.Genre,
-		Category: request.Category,
-	})
-	
-	if partitionExists {
-		// Si la partition existe déjà, retourner ses informations
-		c.JSON(http.StatusConflict, gin.H{
-			"message":           "Partition déjà existante.",
-			"existing_partition": existingPartition,
-		})
+	var parsedDate time.Time
+	if request.ReleaseDate != "" {
+		var err error
+		parsedDate, err = time.Parse("2006-01-02", request.ReleaseDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format de date invalide, utilisez YYYY-MM-DD"})
+			return
+		}
+	}
+
+	licence, err := lib.NormalizeLicence(request.Licence)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !lib.ValidDifficulty(request.Difficulty) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La difficulté doit être comprise entre 1 et 5"})
 		return
 	}
 
+	var organizationID *uint
+	if request.OrganizationID != 0 {
+		role, err := lib.OrganizationRole(request.OrganizationID, optionalUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des membres"})
+			return
+		}
+		if !lib.HasOrganizationRole(role, models.OrganizationRoleDirector) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Seuls les chefs de l'organisation peuvent y ajouter des partitions"})
+			return
+		}
+		organizationID = &request.OrganizationID
+	}
+
 	// Récupérer le fichier envoyé
 	file, err := c.FormFile("partition_file")
 	if err != nil {
@@ -61,69 +111,373 @@ func UploadPartitionHandler(c *gin.Context) {
 	}
 	defer srcFile.Close()
 
-	// Créer un nom unique pour le fichier dans Minio
-	filePath := fmt.Sprintf("partitions/%s_%s", time.Now().Format("20060102150405"), file.Filename)
-
-	// Télécharger le fichier sur Minio
-	_, err = lib.MinioClient.PutObject(c, "solfa", filePath, srcFile, file.Size, minio.PutObjectOptions{})
+	// Lire le contenu pour pouvoir l'analyser avant l'envoi sur Minio
+	content, err := lib.ReadPartitionFile(srcFile)
+	if errors.Is(err, lib.ErrPartitionFileTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de téléchargement sur Minio " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Impossible de lire le fichier"})
 		return
 	}
 
-	var parsedDate time.Time
-	if request.ReleaseDate != "" {
-		parsedDate, err = time.Parse("2006-01-02", request.ReleaseDate)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Format de date invalide, utilisez YYYY-MM-DD"})
-			return
-		}
+	difficultySource := ""
+	if request.Difficulty != 0 {
+		difficultySource = models.DifficultyByUploader
 	}
 
-	// Enregistrer les informations dans PostgreSQL avec l'état 'staging'
-	partition := models.Partition{
+	format := lib.DetectPartitionFormat(file.Filename)
+	base := models.Partition{
 		Title:       request.Title,
 		Composer:    request.Composer,
 		Genre:       request.Genre,
 		Category:    request.Category,
+		LiturgicalSeasons: request.LiturgicalSeasons,
+		Occasions:   request.Occasions,
 		ReleaseDate: parsedDate,
-		Path:        filePath, // Le chemin du fichier dans Minio
+		Format:      format,
 		Status:      "staging", // Par défaut, la partition est en état de staging
 		ValidatedBy: "", // L'email de l'utilisateur qui valide la partition
+		UploadedBy:  optionalUserID(c),
+		OrganizationID: organizationID,
+		Difficulty:  request.Difficulty,
+		DifficultySource: difficultySource,
+		LicenceMetadata: models.LicenceMetadata{
+			Licence:           licence,
+			Publisher:         request.Publisher,
+			Permission:        request.Permission,
+			Source:            request.Source,
+			Edition:           request.Edition,
+			Arranger:          request.Arranger,
+			ArrangerDeathYear: request.ArrangerDeathYear,
+		},
+		EnsembleMetadata: models.EnsembleMetadata{
+			Voicing:         request.Voicing,
+			Divisi:          request.Divisi,
+			Accompaniment:   request.Accompaniment,
+			Instrumentation: request.Instrumentation,
+			Soloists:        request.Soloists,
+		},
+		LanguageMetadata: models.LanguageMetadata{
+			Language:        request.Language,
+			Description:     request.Description,
+			Translations:    request.Translations,
+			LyricsLanguages: request.LyricsLanguages,
+		},
+	}
+
+	// Le genre, la catégorie, les temps liturgiques et les occasions doivent appartenir aux taxonomies
+	if !classifyPartition(c, &base) {
+		return
+	}
+	if err := lib.NormalizeEnsemble(&base.EnsembleMetadata); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := lib.NormalizeLanguageMetadata(&base.LanguageMetadata); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Analyser le fichier pour compléter les métadonnées
+	uploads, err := analyzeUploadedFile(base, file.Filename, content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Vérifier les titres et les doublons avant tout enregistrement
+	var toStore []uploadedPartition
+	var skipped []gin.H
+	for _, upload := range uploads {
+		if upload.partition.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Le titre de la partition est requis"})
+			return
+		}
+
+		// Les doublons sont recherchés sur le nom canonique du compositeur
+		if composer, err := lib.ResolveComposer(upload.partition.Composer); err == nil && composer != nil {
+			upload.partition.Composer = composer.Name
+		}
+
+		// Vérifier si la partition existe déjà dans Elasticsearch
+		// (une bibliothèque privée peut contenir sa propre version d'une partition du catalogue public)
+		partitionExists, existingPartition := lib.SearchPartitionByFields(models.Partition{
+			Title:    upload.partition.Title,
+			Composer: upload.partition.Composer,
+			Genre:    upload.partition.Genre,
+			Category: upload.partition.Category,
+			OrganizationID: upload.partition.OrganizationID,
+		})
+
+		if partitionExists {
+			if len(uploads) == 1 {
+				// Si la partition existe déjà, retourner ses informations
+				c.JSON(http.StatusConflict, gin.H{
+					"message":           "Partition déjà existante.",
+					"existing_partition": existingPartition,
+				})
+				return
+			}
+			// Dans un fichier à plusieurs morceaux, on ignore seulement les doublons
+			skipped = append(skipped, gin.H{"title": upload.partition.Title, "existing_partition": existingPartition})
+			continue
+		}
+		toStore = append(toStore, upload)
+	}
+
+	var partitions []models.Partition
+	for _, upload := range toStore {
+		partition, err := storeUploadedPartition(c, upload)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		partitions = append(partitions, partition)
+	}
+
+	if len(uploads) == 1 {
+		// Réponse de succès
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Partition uploadée avec succès, en attente de validation.",
+			"file":      partitions[0].Path,
+			"partition": partitions[0],
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    fmt.Sprintf("%d partition(s) uploadée(s) avec succès, en attente de validation.", len(partitions)),
+		"partitions": partitions,
+		"skipped":    skipped,
+	})
+}
+
+// analyzeUploadedFile analyse le fichier uploadé selon son format (métadonnées musicales,
+// informations du PDF, paroles) ; un fichier ABC peut produire plusieurs partitions
+func analyzeUploadedFile(base models.Partition, filename string, content []byte) ([]uploadedPartition, error) {
+	var err error
+	switch base.Format {
+	case lib.FormatMIDI:
+		base.MusicMetadata, err = lib.ParseMIDI(content)
+		if err != nil {
+			return nil, fmt.Errorf("Fichier MIDI invalide: %v", err)
+		}
+
+	case lib.FormatPDF:
+		// Les PDF chiffrés ou corrompus sont refusés avant tout envoi sur Minio
+		base.DocumentMetadata, err = lib.AnalyzePDF(content)
+		if err != nil {
+			return nil, fmt.Errorf("Fichier PDF refusé: %v", err)
+		}
+		// Les métadonnées du PDF complètent les champs laissés vides
+		if base.Title == "" {
+			base.Title = base.DocumentTitle
+		}
+		if base.Composer == "" {
+			base.Composer = base.DocumentAuthor
+		}
+
+	case lib.FormatImage:
+		if err := lib.ValidateImage(content); err != nil {
+			return nil, fmt.Errorf("Image illisible: %v", err)
+		}
+
+	case lib.FormatMusicXML:
+		score, err := lib.ParseMusicXML(content)
+		if err != nil {
+			return nil, fmt.Errorf("Fichier MusicXML invalide: %v", err)
+		}
+		if base.Title == "" {
+			base.Title = score.Title()
+		}
+		if base.Composer == "" {
+			base.Composer = score.Composer()
+		}
+		base.MusicMetadata = score.Metadata()
+		base.Lyrics = score.Lyrics()
+		lib.FillLyricsLanguages(&base.LanguageMetadata, score.LyricsLanguages(), base.Lyrics)
+		// Les pupitres et instruments des parties complètent la formation saisie
+		lib.FillEnsemble(&base.EnsembleMetadata, score.Ensemble())
+
+	case lib.FormatABC:
+		uploads, err := splitABCUpload(base, filename, content)
+		if err != nil {
+			return nil, fmt.Errorf("Fichier ABC invalide: %v", err)
+		}
+		return uploads, nil
+	}
+
+	lib.FillLyricsLanguages(&base.LanguageMetadata, nil, base.Lyrics)
+	lib.ApplyEstimatedDifficulty(&base)
+	return []uploadedPartition{{partition: base, filename: filename, content: content}}, nil
+}
+
+// splitABCUpload découpe un fichier ABC en une partition par morceau
+func splitABCUpload(base models.Partition, filename string, content []byte) ([]uploadedPartition, error) {
+	tunes, err := lib.ParseABC(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	var uploads []uploadedPartition
+	for _, tune := range tunes {
+		partition := base
+		// Le titre saisi n'est prioritaire que pour un fichier à un seul morceau
+		if len(tunes) > 1 || partition.Title == "" {
+			partition.Title = tune.Title
+		}
+		if partition.Title == "" {
+			partition.Title = base.Title
+		}
+		if partition.Composer == "" {
+			partition.Composer = tune.Composer
+		}
+
+		partition.MusicMetadata, err = tune.Metadata()
+		if err != nil {
+			return nil, fmt.Errorf("morceau X:%d: %v", tune.Number, err)
+		}
+		partition.Lyrics = tune.Lyrics()
+		lib.FillLyricsLanguages(&partition.LanguageMetadata, nil, partition.Lyrics)
+		lib.ApplyEstimatedDifficulty(&partition)
+		musicXML, err := lib.ConvertABCToMusicXML(tune)
+		if err != nil {
+			return nil, fmt.Errorf("morceau X:%d: %v", tune.Number, err)
+		}
+
+		tuneFilename := filename
+		if len(tunes) > 1 {
+			extension := filepath.Ext(filename)
+			tuneFilename = fmt.Sprintf("%s_X%d%s", strings.TrimSuffix(filename, extension), tune.Number, extension)
+		}
+
+		uploads = append(uploads, uploadedPartition{
+			partition: partition,
+			filename:  tuneFilename,
+			content:   []byte(tune.Source()),
+			musicXML:  musicXML,
+		})
+	}
+
+	return uploads, nil
+}
+
+// storeUploadedPartition envoie le fichier (et ses dérivés) sur Minio,
+// enregistre la partition dans PostgreSQL puis l'indexe dans Elasticsearch
+func storeUploadedPartition(c *gin.Context, upload uploadedPartition) (models.Partition, error) {
+	partition, err := putUploadedFiles(c, upload)
+	if err != nil {
+		return partition, err
+	}
+
+	// Rattacher la partition à son compositeur (créé s'il est inconnu)
+	if err := resolvePartitionComposer(&partition); err != nil {
+		return partition, fmt.Errorf("Erreur lors de l'identification du compositeur")
+	}
+	if err := lib.RefreshPartitionLicence(&partition); err != nil {
+		return partition, fmt.Errorf("Erreur lors du calcul du statut juridique")
 	}
 
 	// Insérer la partition dans PostgreSQL
 	if err := lib.DB.Create(&partition).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement dans la base de données"})
-		return
+		return partition, fmt.Errorf("Erreur lors de l'enregistrement dans la base de données")
+	}
+	// Une nouvelle partition forme sa propre œuvre jusqu'à ce qu'elle soit reliée à une autre
+	partition.WorkID = partition.ID
+	if err := lib.DB.Model(&partition).UpdateColumn("work_id", partition.WorkID).Error; err != nil {
+		return partition, fmt.Errorf("Erreur lors de l'enregistrement dans la base de données")
 	}
 
 	// Indexer la partition dans Elasticsearch
 	lib.IndexPartitionInES(partition)
 
-	// Réponse de succès
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Partition uploadée avec succès, en attente de validation.",
-		"file":    filePath,
+	// Générer l'aperçu et la vignette en tâche de fond
+	lib.EnqueuePreviewGeneration(partition)
+
+	return partition, nil
+}
+
+// putUploadedFiles envoie le fichier uploadé et sa version MusicXML dérivée sur Minio,
+// sous un nom unique (les fichiers existants ne sont jamais écrasés), et renseigne leurs chemins
+func putUploadedFiles(c *gin.Context, upload uploadedPartition) (models.Partition, error) {
+	partition := upload.partition
+	timestamp := time.Now().Format("20060102150405")
+
+	// Créer un nom unique pour le fichier dans Minio
+	filePath := fmt.Sprintf("partitions/%s_%s", timestamp, upload.filename)
+
+	contentType := lib.FormatContentType(partition.Format)
+	if partition.Format == lib.FormatImage {
+		contentType = http.DetectContentType(upload.content)
+	}
+
+	// Télécharger le fichier sur Minio
+	_, err := lib.MinioClient.PutObject(c, "solfa", filePath, bytes.NewReader(upload.content), int64(len(upload.content)), minio.PutObjectOptions{
+		ContentType: contentType,
 	})
+	if err != nil {
+		return partition, fmt.Errorf("Erreur de téléchargement sur Minio %v", err)
+	}
+	partition.Path = filePath // Le chemin du fichier dans Minio
+
+	// Les fichiers MusicXML servent directement de version MusicXML
+	if partition.Format == lib.FormatMusicXML {
+		partition.MusicXMLPath = filePath
+	}
+
+	// Enregistrer la version MusicXML dérivée à côté de l'original
+	if upload.musicXML != nil {
+		musicXMLPath := fmt.Sprintf("partitions/derived/%s_%s.musicxml", timestamp, strings.TrimSuffix(upload.filename, filepath.Ext(upload.filename)))
+		_, err := lib.MinioClient.PutObject(c, "solfa", musicXMLPath, bytes.NewReader(upload.musicXML), int64(len(upload.musicXML)), minio.PutObjectOptions{
+			ContentType: lib.FormatContentType(lib.FormatMusicXML),
+		})
+		if err != nil {
+			return partition, fmt.Errorf("Erreur de téléchargement sur Minio %v", err)
+		}
+		partition.MusicXMLPath = musicXMLPath
+	}
+
+	return partition, nil
 }
 
+// GetPartitionMusicXMLHandler renvoie la version MusicXML d'une partition
+// (le fichier original ou la conversion réalisée à l'upload)
+func GetPartitionMusicXMLHandler(c *gin.Context) {
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
 
-func ValidatePartitionHandler(c *gin.Context) {
-    // Extraire le token de l'utilisateur
-    userToken := c.GetHeader("Authorization")
-    if userToken == "" {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Token manquant"})
-        return
-    }
+	if partition.MusicXMLPath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Aucune version MusicXML disponible pour cette partition"})
+		return
+	}
+	if !authorizePartitionDownload(c, &partition) {
+		return
+	}
 
-    // Extraire l'email à partir du token
-	claims, err := lib.ExtractUserClaims(c)
-    userEmail := claims.Email
-    if err != nil {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Token invalide " + err.Error()})
+	content, err := lib.GetObjectContent(c, partition.MusicXMLPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture sur Minio " + err.Error()})
+		return
+	}
+
+	setLicenceHeaders(c, partition)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"partition_%d.musicxml\"", partition.ID))
+	c.Data(http.StatusOK, lib.FormatContentType(lib.FormatMusicXML), content)
+}
+
+
+// ValidatePartitionHandler valide une partition ; réservé aux modérateurs, pour qu'une partition
+// rétrogradée à la suite de signalements ne puisse pas être revalidée par son uploader
+func ValidatePartitionHandler(c *gin.Context) {
+    moderator, ok := currentModerator(c)
+    if !ok {
         return
     }
+    userEmail := moderator.Email
 
     // Récupérer l'ID de la partition depuis le corps de la requête
     var request struct {
@@ -136,7 +490,8 @@ func ValidatePartitionHandler(c *gin.Context) {
 
     // Rechercher la partition dans la base de données
     var partition models.Partition
-    if err := lib.DB.First(&partition, "id = ?", request.PartitionID).Error; err != nil {
+    // Une partition privée est introuvable pour les non-membres de son organisation
+    if err := lib.DB.First(&partition, "id = ?", request.PartitionID).Error; err != nil || !partitionVisible(c, partition) {
         c.JSON(http.StatusNotFound, gin.H{"error": "Partition non trouvée"})
         return
     }
@@ -166,30 +521,337 @@ func ValidatePartitionHandler(c *gin.Context) {
 
 
 
+// buildSearchFilters construit les filtres Elasticsearch à partir des paramètres de recherche
+func buildSearchFilters(c *gin.Context) ([]map[string]interface{}, error) {
+    filters := []map[string]interface{}{}
+
+    // Filtres textuels exacts (ex: time_signature=3/4, key_signature=D major)
+    for _, field := range []string{"time_signature", "key_signature", "format"} {
+        if value := c.Query(field); value != "" {
+            filters = append(filters, map[string]interface{}{
+                "match_phrase": map[string]interface{}{field: value},
+            })
+        }
+    }
+
+    // Filtres de taxonomie, par slug ; un genre inclut ses sous-genres
+    for param, field := range map[string]string{
+        "genre":             "genre_path",
+        "category":          "category.keyword",
+        "liturgical_season": "liturgical_seasons",
+        "occasion":          "occasions",
+    } {
+        if value := c.Query(param); value != "" {
+            filters = append(filters, map[string]interface{}{
+                "term": map[string]interface{}{field: value},
+            })
+        }
+    }
+
+    // Filtre sur les tags libres (?tag=noel&tag=a-cappella) : la partition doit les porter tous
+    for _, tag := range c.QueryArray("tag") {
+        if slug := lib.Slugify(tag); slug != "" {
+            filters = append(filters, map[string]interface{}{
+                "term": map[string]interface{}{"tags": slug},
+            })
+        }
+    }
+
+    // Filtres de formation (ex: voicing=SSA&accompaniment=a_cappella)
+    if value := c.Query("voicing"); value != "" {
+        voicing, err := lib.NormalizeVoicing(value)
+        if err != nil {
+            return nil, err
+        }
+        filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"voicing": voicing}})
+    }
+    if value := c.Query("accompaniment"); value != "" {
+        accompaniment, err := lib.NormalizeAccompaniment(value)
+        if err != nil {
+            return nil, err
+        }
+        filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"accompaniment": accompaniment}})
+    }
+    if value := c.Query("divisi"); value != "" {
+        divisi, err := strconv.ParseBool(value)
+        if err != nil {
+            return nil, fmt.Errorf("le paramètre 'divisi' doit valoir true ou false")
+        }
+        filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"divisi": divisi}})
+    }
+    if value := c.Query("instrument"); value != "" {
+        filters = append(filters, map[string]interface{}{"match": map[string]interface{}{"instrumentation": value}})
+    }
+    if value := c.Query("soloist"); value != "" {
+        // Même mise en forme qu'à l'enregistrement ("Baryton" devient "baritone")
+        ensemble := models.EnsembleMetadata{Soloists: []string{value}}
+        if err := lib.NormalizeEnsemble(&ensemble); err != nil {
+            return nil, err
+        }
+        filters = append(filters, map[string]interface{}{"terms": map[string]interface{}{"soloists": ensemble.Soloists}})
+    }
+
+    // Filtre sur la difficulté (ex: max_difficulty=2 pour des groupes débutants) ;
+    // les partitions sans niveau sont exclues
+    difficultyRange := map[string]interface{}{}
+    for param, operator := range map[string]string{"min_difficulty": "gte", "max_difficulty": "lte"} {
+        if value := c.Query(param); value != "" {
+            level, err := strconv.Atoi(value)
+            if err != nil || level < lib.DifficultyMin || level > lib.DifficultyMax {
+                return nil, fmt.Errorf("le paramètre '%s' doit être un niveau de %d à %d", param, lib.DifficultyMin, lib.DifficultyMax)
+            }
+            difficultyRange[operator] = level
+        }
+    }
+    if len(difficultyRange) > 0 {
+        if _, ok := difficultyRange["gte"]; !ok {
+            difficultyRange["gte"] = lib.DifficultyMin
+        }
+        filters = append(filters, map[string]interface{}{
+            "range": map[string]interface{}{"difficulty": difficultyRange},
+        })
+    }
+
+    // Filtres juridiques (ex: free=true pour les partitions téléchargeables par tous)
+    if value := c.Query("licence"); value != "" {
+        licence, err := lib.NormalizeLicence(value)
+        if err != nil {
+            return nil, err
+        }
+        filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"licence": licence}})
+    }
+    if value := c.Query("free"); value != "" {
+        free, err := strconv.ParseBool(value)
+        if err != nil {
+            return nil, fmt.Errorf("le paramètre 'free' doit valoir true ou false")
+        }
+        filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"free": free}})
+    }
+
+    // Filtre de langue, répétable (ex: language=fr&language=latin) : langue des paroles ou du titre original
+    if values := c.QueryArray("language"); len(values) > 0 {
+        codes, err := lib.NormalizeLanguages(values)
+        if err != nil {
+            return nil, err
+        }
+        if len(codes) > 0 {
+            filters = append(filters, map[string]interface{}{
+                "bool": map[string]interface{}{
+                    "should": []interface{}{
+                        map[string]interface{}{"terms": map[string]interface{}{"lyrics_languages": codes}},
+                        map[string]interface{}{"terms": map[string]interface{}{"language": codes}},
+                    },
+                    "minimum_should_match": 1,
+                },
+            })
+        }
+    }
+
+    // Filtre sur la durée en secondes (ex: max_duration=180 pour moins de 3 minutes)
+    durationRange := map[string]interface{}{}
+    for param, operator := range map[string]string{"min_duration": "gte", "max_duration": "lte"} {
+        if value := c.Query(param); value != "" {
+            seconds, err := strconv.ParseFloat(value, 64)
+            if err != nil {
+                return nil, fmt.Errorf("le paramètre '%s' doit être un nombre de secondes", param)
+            }
+            durationRange[operator] = seconds
+        }
+    }
+    if len(durationRange) > 0 {
+        // Une durée nulle signifie que la partition n'a pas été analysée
+        durationRange["gt"] = 0
+        filters = append(filters, map[string]interface{}{
+            "range": map[string]interface{}{"duration": durationRange},
+        })
+    }
+
+    return filters, nil
+}
+
 func SearchPartitionsHandler(c *gin.Context) {
     query := c.Query("q") // Récupère la requête de l'utilisateur
 
-    if query == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Le paramètre 'q' est requis"})
+    filters, err := buildSearchFilters(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if query == "" && len(filters) == 0 && c.Query("lyrics") == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Le paramètre 'q', 'lyrics' ou au moins un filtre est requis"})
         return
     }
 
+    // Recherche d'un vers précis (?lyrics=) : les mots doivent se suivre
+    if lyrics := c.Query("lyrics"); lyrics != "" {
+        filters = append(filters, map[string]interface{}{
+            "match_phrase": map[string]interface{}{"lyrics.text": map[string]interface{}{"query": lyrics, "slop": 1}},
+        })
+    }
+
     // Construction de la requête Elasticsearch
-    searchQuery := fmt.Sprintf(`{
-        "query": {
-            "multi_match": {
-                "query": "%s",
-                "fields": ["title", "composer", "genre", "category"],
-                "type": "best_fields",
-                "fuzziness": "AUTO"
-            }
+    boolQuery := map[string]interface{}{"filter": filters}
+    if query != "" {
+        boolQuery["must"] = map[string]interface{}{
+            "multi_match": map[string]interface{}{
+                "query":     query,
+                // Titres traduits et descriptions, analysés selon leur langue ("Silent Night" trouve "Douce nuit")
+                "fields":    []string{"title", "titles.*", "composer", "genre", "category", "lyrics.text", "descriptions.*^0.5"},
+                "type":      "best_fields",
+                "fuzziness": "AUTO",
+            },
+        }
+    }
+
+    searchBody := map[string]interface{}{
+        "query": map[string]interface{}{"bool": boolQuery},
+        "aggs":  taxonomyFacetAggregations(),
+        // Les vers correspondants sont renvoyés dans "highlight" de chaque résultat
+        "highlight": map[string]interface{}{
+            "fields": map[string]interface{}{
+                "lyrics.text": map[string]interface{}{"fragment_size": 120, "number_of_fragments": 3},
+            },
+        },
+    }
+
+    // Regroupement par œuvre (?group=work) : un résultat par œuvre, ses autres
+    // arrangements et éditions trouvés étant renvoyés dans "inner_hits.arrangements"
+    switch c.Query("group") {
+    case "":
+    case "work":
+        searchBody["collapse"] = map[string]interface{}{
+            "field": "work_id",
+            "inner_hits": map[string]interface{}{
+                "name":    "arrangements",
+                "size":    workGroupSize,
+                "_source": []string{"id", "title", "composer", "arranger", "voicing", "accompaniment", "format", "difficulty"},
+            },
         }
-    }`, query)
+    default:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Paramètre 'group' invalide (work)"})
+        return
+    }
+
+    runPartitionSearch(c, searchBody)
+}
+
+// SearchMelodyHandler recherche les partitions dont la mélodie contient la suite
+// de notes (?notes=) ou le contour mélodique (?contour=) demandé, quelle que soit la tonalité
+func SearchMelodyHandler(c *gin.Context) {
+    notes := c.Query("notes")
+    contour := c.Query("contour")
+
+    var field string
+    var tokens []string
+    switch {
+    case notes != "":
+        melody, err := lib.ParseMelodyNotes(notes)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Paramètre 'notes' invalide: " + err.Error()})
+            return
+        }
+        if len(melody) < 3 {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Au moins 3 notes sont nécessaires"})
+            return
+        }
+        field, tokens = "melody_intervals", lib.MelodyIntervalTokens(melody)
+    case contour != "":
+        var err error
+        tokens, err = lib.ParseContour(contour)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Paramètre 'contour' invalide: " + err.Error()})
+            return
+        }
+        if len(tokens) < 3 {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Le contour doit comporter au moins 3 mouvements"})
+            return
+        }
+        field = "melody_contour"
+    default:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Le paramètre 'notes' ou 'contour' est requis"})
+        return
+    }
+
+    phrase := strings.Join(tokens, " ")
+    filters, err := buildSearchFilters(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    // La suite doit apparaître telle quelle dans l'incipit ; elle est favorisée si elle l'ouvre
+    runPartitionSearch(c, map[string]interface{}{
+        "query": map[string]interface{}{
+            "bool": map[string]interface{}{
+                "must": map[string]interface{}{
+                    "match_phrase": map[string]interface{}{field: phrase},
+                },
+                "should": map[string]interface{}{
+                    "match_phrase": map[string]interface{}{field: map[string]interface{}{"query": lib.MelodyStartToken + " " + phrase, "boost": 2}},
+                },
+                "filter": filters,
+            },
+        },
+    })
+}
+
+// Clés de tri acceptées par le paramètre ?sort= (par défaut : pertinence), avec leur champ
+// et leur ordre ; "-difficulty" classe les partitions de la plus difficile à la plus facile
+var searchSortFields = map[string]struct {
+    field string
+    order string
+}{
+    "popularity":  {"popularity", "desc"},
+    "rating":      {"rating_average", "desc"},
+    "difficulty":  {"difficulty", "asc"},
+    "-difficulty": {"difficulty", "desc"},
+}
+
+// Nombre maximal d'arrangements renvoyés avec chaque œuvre lorsque les résultats sont regroupés (?group=work)
+const workGroupSize = 10
+
+// runPartitionSearch exécute une requête sur l'index des partitions et renvoie les résultats
+func runPartitionSearch(c *gin.Context, query map[string]interface{}) {
+    // Partitions privées : seules celles des organisations de l'utilisateur sont cherchées
+    visibility, err := organizationSearchFilters(c)
+    if err != nil {
+        if errors.Is(err, errOrganizationNotFound) {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Organisation non trouvée"})
+        } else {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des organisations"})
+        }
+        return
+    }
+    query["query"] = map[string]interface{}{
+        "bool": map[string]interface{}{"must": query["query"], "filter": visibility},
+    }
+    // Les paroles ne sont communiquées qu'avec les mêmes restrictions que le téléchargement
+    query["_source"] = map[string]interface{}{"excludes": []string{"lyrics"}}
+
+    if sortKey := c.Query("sort"); sortKey != "" {
+        sortField, ok := searchSortFields[sortKey]
+        if !ok {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Paramètre 'sort' invalide"})
+            return
+        }
+        // À valeur égale, les résultats restent classés par pertinence
+        query["sort"] = []interface{}{
+            map[string]interface{}{sortField.field: map[string]interface{}{"order": sortField.order, "missing": "_last"}},
+            "_score",
+        }
+    }
+
+    searchQuery, err := json.Marshal(query)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la construction de la requête"})
+        return
+    }
 
     // Exécution de la requête
     res, err := lib.ESClient.Search(
         lib.ESClient.Search.WithIndex("partitions"),
-        lib.ESClient.Search.WithBody(strings.NewReader(searchQuery)),
+        lib.ESClient.Search.WithBody(bytes.NewReader(searchQuery)),
     )
 
     if err != nil {
@@ -205,5 +867,211 @@ func SearchPartitionsHandler(c *gin.Context) {
     }
 
     hits := result["hits"].(map[string]interface{})["hits"].([]interface{})
-    c.JSON(http.StatusOK, gin.H{"results": hits})
+    hideProtectedLyrics(hits)
+    response := gin.H{"results": hits}
+    if aggregations, ok := result["aggregations"].(map[string]interface{}); ok {
+        response["facets"] = taxonomyFacets(aggregations)
+    }
+    c.JSON(http.StatusOK, response)
+}
+
+// hideProtectedLyrics retire les vers mis en évidence des résultats qui ne sont pas libres de droits
+func hideProtectedLyrics(hits []interface{}) {
+	for _, hit := range hits {
+		hit, ok := hit.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		source, _ := hit["_source"].(map[string]interface{})
+		if free, _ := source["free"].(bool); !free {
+			delete(hit, "highlight")
+		}
+	}
+}
+
+// GetPartitionLyricsHandler renvoie les paroles d'une partition, couplet par couplet, avec les mêmes
+// restrictions que le téléchargement
+func GetPartitionLyricsHandler(c *gin.Context) {
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+	if !authorizePartitionDownload(c, &partition) {
+		return
+	}
+
+	var lyrics []models.PartitionLyric
+	if err := lib.DB.Where("partition_id = ?", partition.ID).Order("verse, voice, id").Find(&lyrics).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des paroles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"partition_id": partition.ID,
+		"title":        partition.Title,
+		"lyrics":       lyrics,
+	})
+}
+
+// loadPartition charge la partition désignée par le paramètre ":id" de la route,
+// ou répond 404 si elle n'existe pas ou n'est pas visible par l'utilisateur connecté
+func loadPartition(c *gin.Context) (models.Partition, bool) {
+	var partition models.Partition
+	// Une partition privée est introuvable pour les non-membres de son organisation
+	if err := lib.DB.First(&partition, "id = ?", c.Param("id")).Error; err != nil || !partitionVisible(c, partition) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partition non trouvée"})
+		return partition, false
+	}
+	return partition, true
+}
+
+// GetPartitionHandler renvoie les informations d'une partition
+func GetPartitionHandler(c *gin.Context) {
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	// Historique de consultation, pour les utilisateurs connectés
+	recordPartitionHistory(optionalUserID(c), partition.ID, models.HistoryViewed)
+
+	c.JSON(http.StatusOK, gin.H{"partition": partition})
+}
+
+// GetPartitionThumbnailHandler renvoie la vignette d'une partition
+func GetPartitionThumbnailHandler(c *gin.Context) {
+	servePartitionImage(c, "thumbnail")
+}
+
+// GetPartitionPreviewHandler renvoie l'aperçu de la première page d'une partition
+func GetPartitionPreviewHandler(c *gin.Context) {
+	servePartitionImage(c, "preview")
+}
+
+// servePartitionImage renvoie une image générée en tâche de fond ("thumbnail" ou "preview")
+func servePartitionImage(c *gin.Context, kind string) {
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	if (kind == "thumbnail" && partition.ThumbnailURL == "") || (kind == "preview" && partition.PreviewURL == "") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image non disponible pour cette partition"})
+		return
+	}
+	// L'aperçu d'une partition image en est une copie lisible : il suit la règle de téléchargement
+	if kind == "preview" && !authorizePartitionDownload(c, &partition) {
+		return
+	}
+
+	content, err := lib.GetObjectContent(c, lib.PreviewPath(partition.ID, kind))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture sur Minio " + err.Error()})
+		return
+	}
+
+	// Les caches partagés ne doivent conserver que les images des partitions publiques et libres
+	if partition.OrganizationID != nil || !partition.Free {
+		c.Header("Cache-Control", "private, max-age=86400")
+	} else {
+		c.Header("Cache-Control", "public, max-age=86400")
+	}
+	c.Data(http.StatusOK, "image/jpeg", content)
+}
+
+// DownloadPartitionHandler renvoie le fichier original d'une partition. Les PDF sont
+// marqués à la volée d'un filigrane au nom de l'utilisateur, pour tracer chaque copie diffusée.
+func DownloadPartitionHandler(c *gin.Context) {
+	email := ""
+	if claims, err := lib.ExtractUserClaims(c); err == nil {
+		email = claims.Email
+	}
+
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+	if !authorizePartitionDownload(c, &partition) {
+		return
+	}
+
+	content, err := lib.GetObjectContent(c, partition.Path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture sur Minio " + err.Error()})
+		return
+	}
+
+	contentType := lib.FormatContentType(partition.Format)
+	switch partition.Format {
+	case lib.FormatPDF:
+		watermark := lib.NewWatermarkInfo(email, lib.WatermarkOrganization(partition.OrganizationID, optionalUserID(c)))
+		if label := lib.PartitionLicenceLabel(partition); label != "" {
+			watermark.Licence = label
+		}
+		content, err = lib.WatermarkPDF(content, watermark.Text())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'application du filigrane: " + err.Error()})
+			return
+		}
+	case lib.FormatImage:
+		contentType = http.DetectContentType(content)
+	}
+
+	lib.LogActionWithDetails("download_partition", email, map[string]interface{}{"partition_id": partition.ID})
+	recordPartitionHistory(optionalUserID(c), partition.ID, models.HistoryDownloaded)
+
+	setLicenceHeaders(c, partition)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(partition.Path)))
+	c.Data(http.StatusOK, contentType, content)
+}
+
+// authorizePartitionDownload vérifie que le fichier d'une partition peut être téléchargé :
+// les partitions libres le sont par tous, les partitions protégées par les seuls membres
+// dont l'adresse email a été vérifiée
+func authorizePartitionDownload(c *gin.Context, partition *models.Partition) bool {
+	if status, refusal := partitionDownloadRefusal(c, partition); status != 0 {
+		c.JSON(status, refusal)
+		return false
+	}
+	return true
+}
+
+// partitionDownloadRefusal applique la règle de téléchargement sans répondre à la requête :
+// elle renvoie le statut et le message du refus, ou 0 si le téléchargement est autorisé
+func partitionDownloadRefusal(c *gin.Context, partition *models.Partition) (int, gin.H) {
+	// Le domaine public est recalculé : il évolue chaque année
+	if err := lib.RefreshPartitionLicence(partition); err != nil {
+		return http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul du statut juridique"}
+	}
+	if partition.Free {
+		return 0, nil
+	}
+
+	var user models.User
+	if userID := optionalUserID(c); userID != 0 {
+		lib.DB.Select("id", "is_verified").First(&user, userID)
+	}
+	if user.ID == 0 {
+		return http.StatusUnauthorized, gin.H{
+			"error":   "Partition protégée par le droit d'auteur : connectez-vous pour la télécharger",
+			"licence": partition.Licence,
+		}
+	}
+	if !user.IsVerified {
+		return http.StatusForbidden, gin.H{
+			"error":   "Partition protégée par le droit d'auteur : vérifiez votre adresse email pour la télécharger",
+			"licence": partition.Licence,
+		}
+	}
+	return 0, nil
+}
+
+// setLicenceHeaders indique la licence de la partition dans les en-têtes d'un téléchargement
+func setLicenceHeaders(c *gin.Context, partition models.Partition) {
+	if partition.Licence != "" {
+		c.Header("X-Partition-Licence", partition.Licence)
+	}
+	if url := lib.LicenceURL(partition.Licence); url != "" {
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"license\"", url))
+	}
 }
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+)
+
+// RatingRequest représente la note envoyée par un utilisateur
+type RatingRequest struct {
+	Value int `json:"value" binding:"required,min=1,max=5"`
+}
+
+// GetPartitionRatingHandler renvoie la note moyenne d'une partition et, pour un
+// utilisateur connecté, sa propre note
+func GetPartitionRatingHandler(c *gin.Context) {
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	response := gin.H{
+		"partition_id":   partition.ID,
+		"rating_average": partition.RatingAverage,
+		"rating_count":   partition.RatingCount,
+	}
+	if userID := optionalUserID(c); userID != 0 {
+		var rating models.Rating
+		if err := lib.DB.Where("user_id = ? AND partition_id = ?", userID, partition.ID).First(&rating).Error; err == nil {
+			response["my_rating"] = rating.Value
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RatePartitionHandler enregistre ou modifie la note de l'utilisateur connecté
+func RatePartitionHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	var request RatingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La note doit être comprise entre 1 et 5", "details": err.Error()})
+		return
+	}
+
+	rating := models.Rating{UserID: user.ID, PartitionID: partition.ID, Value: request.Value}
+	if err := lib.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "partition_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&rating).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement de la note"})
+		return
+	}
+
+	lib.LogActionWithDetails("rate_partition", user.Email, map[string]interface{}{"partition_id": partition.ID, "value": request.Value})
+	respondWithRating(c, partition.ID, request.Value)
+}
+
+// DeleteRatingHandler supprime la note de l'utilisateur connecté
+func DeleteRatingHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	result := lib.DB.Where("user_id = ? AND partition_id = ?", user.ID, partition.ID).Delete(&models.Rating{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression de la note"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vous n'avez pas noté cette partition"})
+		return
+	}
+
+	lib.LogActionWithDetails("delete_rating", user.Email, map[string]interface{}{"partition_id": partition.ID})
+	respondWithRating(c, partition.ID, 0)
+}
+
+// respondWithRating recalcule la moyenne de la partition et la renvoie
+func respondWithRating(c *gin.Context, partitionID uint, value int) {
+	if err := lib.RefreshPartitionRating(partitionID); err != nil {
+		logrus.WithFields(logrus.Fields{"partition_id": partitionID, "error": err.Error()}).Error("Erreur lors du calcul de la note moyenne")
+	}
+
+	var partition models.Partition
+	lib.DB.Select("id", "rating_average", "rating_count").First(&partition, partitionID)
+
+	response := gin.H{
+		"partition_id":   partitionID,
+		"rating_average": partition.RatingAverage,
+		"rating_count":   partition.RatingCount,
+	}
+	if value != 0 {
+		response["my_rating"] = value
+	}
+	c.JSON(http.StatusOK, response)
+}
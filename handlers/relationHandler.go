@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+)
+
+// CreateRelationRequest déclare la partition dont dérive la partition désignée par :id
+type CreateRelationRequest struct {
+	RelatedID uint   `json:"related_id" binding:"required"`
+	Kind      string `json:"kind" binding:"required"` // "arrangement_of", "translation_of", "part_of_work" ou "alternate_edition"
+}
+
+// relatedPartition est une partition du graphe des relations, avec les informations utiles à son affichage
+type relatedPartition struct {
+	ID         uint   `json:"id"`
+	Title      string `json:"title"`
+	Composer   string `json:"composer"`
+	Arranger   string `json:"arranger"`
+	Voicing    string `json:"voicing"`
+	Format     string `json:"format"`
+	Difficulty int    `json:"difficulty,omitempty"`
+	Status     string `json:"status"`
+}
+
+// GetRelatedPartitionsHandler renvoie le graphe des partitions reliées, directement ou non,
+// à une partition : les partitions (nœuds) et leurs relations (arêtes)
+func GetRelatedPartitionsHandler(c *gin.Context) {
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	ids, err := lib.RelatedPartitionIDs(partition.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des relations"})
+		return
+	}
+
+	// Les partitions privées ne figurent dans le graphe que pour les membres de leur organisation
+	nodes := []relatedPartition{}
+	if err := lib.DB.Model(&models.Partition{}).
+		Scopes(visiblePartitionsScope(c)).
+		Select("id", "title", "composer", "arranger", "voicing", "format", "difficulty", "status").
+		Where("id IN ?", ids).
+		Order("id").
+		Scan(&nodes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des partitions"})
+		return
+	}
+
+	visibleIDs := make([]uint, 0, len(nodes))
+	for _, node := range nodes {
+		visibleIDs = append(visibleIDs, node.ID)
+	}
+	edges := []models.PartitionRelation{}
+	if err := lib.DB.Where("partition_id IN ? AND related_id IN ?", visibleIDs, visibleIDs).Order("id").Find(&edges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des relations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"partition_id": partition.ID,
+		"work_id":      ids[0],
+		"nodes":        nodes,
+		"edges":        edges,
+	})
+}
+
+// sameLibrary indique si deux partitions appartiennent au catalogue public ou à la même organisation
+func sameLibrary(a, b models.Partition) bool {
+	if a.OrganizationID == nil || b.OrganizationID == nil {
+		return a.OrganizationID == nil && b.OrganizationID == nil
+	}
+	return *a.OrganizationID == *b.OrganizationID
+}
+
+// CreatePartitionRelationHandler déclare qu'une partition dérive d'une autre (arrangement,
+// traduction, extrait ou autre édition) ; réservé à l'uploader de la partition et aux modérateurs
+func CreatePartitionRelationHandler(c *gin.Context) {
+	partition, user, ok := loadEditablePartition(c)
+	if !ok {
+		return
+	}
+
+	var request CreateRelationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	// Une partition privée est introuvable pour les non-membres de son organisation
+	var related models.Partition
+	if err := lib.DB.Select("id", "organization_id").First(&related, request.RelatedID).Error; err != nil || !partitionVisible(c, related) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partition liée non trouvée"})
+		return
+	}
+	// Les partitions reliées appartiennent à la même bibliothèque : l'identifiant d'une partition
+	// privée ne doit pas devenir l'œuvre (work_id) d'une partition du catalogue public
+	if !sameLibrary(partition, related) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Les partitions reliées doivent appartenir à la même bibliothèque"})
+		return
+	}
+	if err := lib.ValidateRelation(partition.ID, related.ID, request.Kind); err != nil {
+		if errors.Is(err, lib.ErrInvalidRelation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la vérification de la relation"})
+		}
+		return
+	}
+
+	relation := models.PartitionRelation{
+		PartitionID: partition.ID,
+		RelatedID:   related.ID,
+		Kind:        request.Kind,
+		CreatedBy:   user.ID,
+	}
+	result := lib.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&relation)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement de la relation"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cette relation existe déjà"})
+		return
+	}
+
+	lib.EnqueueWorksRefresh(partition.ID)
+	lib.LogActionWithDetails("relate_partition", user.Email, map[string]interface{}{
+		"partition_id": partition.ID,
+		"related_id":   related.ID,
+		"kind":         relation.Kind,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"relation": relation})
+}
+
+// DeletePartitionRelationHandler supprime une relation (:relation) déclarée par une partition ;
+// réservé à l'uploader de la partition et aux modérateurs
+func DeletePartitionRelationHandler(c *gin.Context) {
+	partition, user, ok := loadEditablePartition(c)
+	if !ok {
+		return
+	}
+
+	var relation models.PartitionRelation
+	if err := lib.DB.Where("id = ? AND partition_id = ?", c.Param("relation"), partition.ID).First(&relation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Relation non trouvée pour cette partition"})
+		return
+	}
+
+	if err := lib.DB.Delete(&relation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression de la relation"})
+		return
+	}
+
+	// Les deux partitions peuvent désormais appartenir à des œuvres distinctes
+	lib.EnqueueWorksRefresh(relation.PartitionID, relation.RelatedID)
+	lib.LogActionWithDetails("unrelate_partition", user.Email, map[string]interface{}{
+		"partition_id": partition.ID,
+		"related_id":   relation.RelatedID,
+		"kind":         relation.Kind,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Relation supprimée"})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+	"time"
+)
+
+// ReportRequest représente un signalement envoyé par un utilisateur
+type ReportRequest struct {
+	Category    string `json:"category" binding:"required,oneof=wrong_notes bad_metadata copyright spam other"`
+	Description string `json:"description" binding:"required,max=5000"`
+}
+
+// TriageReportRequest représente le traitement d'un signalement par un modérateur
+type TriageReportRequest struct {
+	Status     string `json:"status" binding:"required,oneof=open in_review resolved dismissed"`
+	Resolution string `json:"resolution"`
+}
+
+// CreateReportHandler signale un problème sur une partition (fausses notes, métadonnées,
+// droit d'auteur, spam) ; au-delà du seuil de signalements ouverts, une partition
+// validée repasse en revue
+func CreateReportHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	var request ReportRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	// Un utilisateur ne peut avoir qu'un signalement en cours par partition
+	var existing int64
+	lib.DB.Model(&models.Report{}).
+		Where("partition_id = ? AND reporter_id = ? AND status IN ?", partition.ID, user.ID, []string{models.ReportOpen, models.ReportInReview}).
+		Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Vous avez déjà un signalement en cours pour cette partition"})
+		return
+	}
+
+	report := models.Report{
+		PartitionID: partition.ID,
+		ReporterID:  user.ID,
+		Category:    request.Category,
+		Description: request.Description,
+		Status:      models.ReportOpen,
+	}
+	if err := lib.DB.Create(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement du signalement"})
+		return
+	}
+
+	lib.LogActionWithDetails("report_partition", user.Email, map[string]interface{}{"partition_id": partition.ID, "report_id": report.ID, "category": report.Category})
+
+	demoted, err := lib.DemotePartitionIfReported(partition.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"partition_id": partition.ID, "error": err.Error()}).Error("Erreur lors de la remise en revue de la partition")
+	}
+	if demoted {
+		lib.LogActionWithDetails("demote_partition", user.Email, map[string]interface{}{"partition_id": partition.ID, "reason": "reports"})
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"report": report})
+}
+
+// ListReportsHandler liste les signalements pour les modérateurs, filtrables par
+// statut (?status=, "open" par défaut, "all" pour tous), catégorie et partition
+func ListReportsHandler(c *gin.Context) {
+	if _, ok := currentModerator(c); !ok {
+		return
+	}
+
+	query := lib.DB.Preload("Partition").Order("created_at")
+	if status := c.DefaultQuery("status", models.ReportOpen); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if partitionID := c.Query("partition_id"); partitionID != "" {
+		query = query.Where("partition_id = ?", partitionID)
+	}
+
+	var reports []models.Report
+	if err := query.Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des signalements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+// TriageReportHandler met à jour le statut d'un signalement (prise en charge, résolution, rejet)
+func TriageReportHandler(c *gin.Context) {
+	moderator, ok := currentModerator(c)
+	if !ok {
+		return
+	}
+
+	var report models.Report
+	if err := lib.DB.First(&report, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Signalement non trouvé"})
+		return
+	}
+
+	var request TriageReportRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	report.Status = request.Status
+	report.Resolution = request.Resolution
+	report.HandledBy = moderator.Email
+	report.ResolvedAt = nil
+	if request.Status == models.ReportResolved || request.Status == models.ReportDismissed {
+		now := time.Now()
+		report.ResolvedAt = &now
+	}
+
+	if err := lib.DB.Save(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour du signalement"})
+		return
+	}
+
+	lib.LogActionWithDetails("triage_report", moderator.Email, map[string]interface{}{"partition_id": report.PartitionID, "report_id": report.ID, "status": report.Status})
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+	"strconv"
+)
+
+// Nombre de tags renvoyés par défaut (nuage) et au maximum (suggestions)
+const (
+	tagCloudLimit   = 100
+	tagSuggestLimit = 10
+)
+
+// AddTagsRequest représente les tags ajoutés à une partition
+type AddTagsRequest struct {
+	Tags []string `json:"tags" binding:"required,min=1,max=20"`
+}
+
+// RenameTagRequest représente le nouveau libellé d'un tag
+type RenameTagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MergeTagRequest désigne le tag dans lequel fusionner un doublon
+type MergeTagRequest struct {
+	IntoID uint `json:"into_id" binding:"required"`
+}
+
+// tagUsage est un tag avec son nombre de partitions
+type tagUsage struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Count int    `json:"count"`
+}
+
+// usedTags retourne les tags visibles (ni interdits ni fusionnés) par nombre d'utilisations décroissant ;
+// seules les partitions visibles par l'utilisateur connecté sont comptées
+func usedTags(c *gin.Context, slugPrefix string, limit int) ([]tagUsage, error) {
+	query := lib.DB.Model(&models.Tag{}).
+		Select("tags.id, tags.name, tags.slug, COUNT(partition_tags.id) AS count").
+		Joins("JOIN partition_tags ON partition_tags.tag_id = tags.id").
+		Joins("JOIN partitions ON partitions.id = partition_tags.partition_id").
+		Scopes(visiblePartitionsScope(c)).
+		Where("tags.banned = ? AND tags.merged_into_id IS NULL", false)
+	if slugPrefix != "" {
+		query = query.Where("tags.slug LIKE ?", slugPrefix+"%")
+	}
+
+	tags := []tagUsage{}
+	err := query.Group("tags.id, tags.name, tags.slug").
+		Order("count DESC, tags.slug").
+		Limit(limit).
+		Scan(&tags).Error
+	return tags, err
+}
+
+// GetTagsHandler renvoie le nuage de tags : les tags les plus utilisés avec un poids de 1 à 5 (?limit=)
+func GetTagsHandler(c *gin.Context) {
+	limit := tagCloudLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Paramètre 'limit' invalide"})
+			return
+		}
+		limit = parsed
+	}
+
+	tags, err := usedTags(c, "", limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des tags"})
+		return
+	}
+
+	cloud := make([]gin.H, 0, len(tags))
+	for _, tag := range tags {
+		// Les tags sont triés : le premier est le plus utilisé
+		cloud = append(cloud, gin.H{
+			"id":     tag.ID,
+			"name":   tag.Name,
+			"slug":   tag.Slug,
+			"count":  tag.Count,
+			"weight": 1 + 4*tag.Count/tags[0].Count,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": cloud})
+}
+
+// SuggestTagsHandler propose les tags existants commençant par la saisie (?q=)
+func SuggestTagsHandler(c *gin.Context) {
+	prefix := lib.Slugify(c.Query("q"))
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le paramètre 'q' est requis"})
+		return
+	}
+
+	tags, err := usedTags(c, prefix, tagSuggestLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// AddPartitionTagsHandler ajoute des tags à une partition ; les tags inconnus sont créés
+func AddPartitionTagsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	var request AddTagsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	var tags []models.Tag
+	for _, name := range request.Tags {
+		tag, err := lib.FindOrCreateTag(name)
+		if err != nil {
+			if errors.Is(err, lib.ErrTagBanned) || errors.Is(err, lib.ErrInvalidTag) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement des tags"})
+			}
+			return
+		}
+		tags = append(tags, *tag)
+	}
+
+	for _, tag := range tags {
+		partitionTag := models.PartitionTag{PartitionID: partition.ID, TagID: tag.ID, UserID: user.ID}
+		if err := lib.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&partitionTag).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement des tags"})
+			return
+		}
+	}
+
+	lib.EnqueueTagsRefresh(partition.ID)
+	lib.LogActionWithDetails("tag_partition", user.Email, map[string]interface{}{"partition_id": partition.ID, "tags": request.Tags})
+
+	c.JSON(http.StatusCreated, gin.H{"tags": tags})
+}
+
+// RemovePartitionTagHandler retire un tag (:tag, son slug) d'une partition ; réservé à
+// l'utilisateur qui l'a ajouté, à l'uploader de la partition et aux modérateurs
+func RemovePartitionTagHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	partition, ok := loadPartition(c)
+	if !ok {
+		return
+	}
+
+	var partitionTag models.PartitionTag
+	if err := lib.DB.Joins("Tag").
+		Where("partition_tags.partition_id = ? AND \"Tag\".slug = ?", partition.ID, lib.Slugify(c.Param("tag"))).
+		First(&partitionTag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag non trouvé sur cette partition"})
+		return
+	}
+	if partitionTag.UserID != user.ID && partition.UploadedBy != user.ID && !user.IsModerator() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Vous ne pouvez pas retirer ce tag"})
+		return
+	}
+
+	if err := lib.DB.Delete(&partitionTag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression du tag"})
+		return
+	}
+
+	lib.EnqueueTagsRefresh(partition.ID)
+	lib.LogActionWithDetails("untag_partition", user.Email, map[string]interface{}{"partition_id": partition.ID, "tag_id": partitionTag.TagID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag retiré"})
+}
+
+// loadTag recherche le tag désigné par le paramètre :id
+func loadTag(c *gin.Context) (models.Tag, bool) {
+	var tag models.Tag
+	if err := lib.DB.First(&tag, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag non trouvé"})
+		return tag, false
+	}
+	return tag, true
+}
+
+// refreshTaggedPartitions planifie la mise à jour des partitions portant un tag
+func refreshTaggedPartitions(c *gin.Context, tagID uint) bool {
+	partitionIDs, err := lib.TagPartitionIDs(tagID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des partitions du tag"})
+		return false
+	}
+	lib.EnqueueTagsRefresh(partitionIDs...)
+	return true
+}
+
+// RenameTagHandler renomme un tag (modérateurs) ; si le nouveau nom correspond à un autre
+// tag, les deux doivent être fusionnés
+func RenameTagHandler(c *gin.Context) {
+	moderator, ok := currentModerator(c)
+	if !ok {
+		return
+	}
+	tag, ok := loadTag(c)
+	if !ok {
+		return
+	}
+
+	var request RenameTagRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+	slug := lib.Slugify(request.Name)
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le nom du tag est requis"})
+		return
+	}
+
+	var existing models.Tag
+	if err := lib.DB.Where("slug = ? AND id <> ?", slug, tag.ID).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Un tag porte déjà ce nom, fusionnez-les", "existing_tag": existing})
+		return
+	}
+
+	previousName := tag.Name
+	tag.Name = request.Name
+	tag.Slug = slug
+	if err := lib.DB.Save(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du renommage du tag"})
+		return
+	}
+	if !refreshTaggedPartitions(c, tag.ID) {
+		return
+	}
+
+	lib.LogActionWithDetails("rename_tag", moderator.Email, map[string]interface{}{"tag_id": tag.ID, "from": previousName, "to": tag.Name})
+
+	c.JSON(http.StatusOK, gin.H{"tag": tag})
+}
+
+// MergeTagHandler fusionne un tag en double dans un autre (modérateurs) ; le tag fusionné
+// reste une redirection vers la cible pour les prochains ajouts
+func MergeTagHandler(c *gin.Context) {
+	moderator, ok := currentModerator(c)
+	if !ok {
+		return
+	}
+	source, ok := loadTag(c)
+	if !ok {
+		return
+	}
+
+	var request MergeTagRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+	var target models.Tag
+	if err := lib.DB.First(&target, request.IntoID).Error; err != nil || target.ID == source.ID || target.MergedIntoID != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag cible non trouvé"})
+		return
+	}
+
+	// Les partitions concernées sont relevées avant la fusion, qui modifie leurs associations
+	partitionIDs, err := lib.TagPartitionIDs(source.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des partitions du tag"})
+		return
+	}
+	if err := lib.MergeTag(source, target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la fusion des tags"})
+		return
+	}
+	lib.EnqueueTagsRefresh(partitionIDs...)
+
+	lib.LogActionWithDetails("merge_tag", moderator.Email, map[string]interface{}{"tag_id": target.ID, "merged_id": source.ID})
+
+	c.JSON(http.StatusOK, gin.H{"tag": target})
+}
+
+// BanTagHandler interdit un tag (modérateurs) : il est retiré de toutes les partitions
+// et ne peut plus être ajouté
+func BanTagHandler(c *gin.Context) {
+	moderator, ok := currentModerator(c)
+	if !ok {
+		return
+	}
+	tag, ok := loadTag(c)
+	if !ok {
+		return
+	}
+
+	partitionIDs, err := lib.TagPartitionIDs(tag.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des partitions du tag"})
+		return
+	}
+	if err := lib.DB.Where("tag_id = ?", tag.ID).Delete(&models.PartitionTag{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du retrait du tag"})
+		return
+	}
+	if err := lib.DB.Model(&tag).Update("banned", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'interdiction du tag"})
+		return
+	}
+	lib.EnqueueTagsRefresh(partitionIDs...)
+
+	lib.LogActionWithDetails("ban_tag", moderator.Email, map[string]interface{}{"tag_id": tag.ID, "partition_count": len(partitionIDs)})
+
+	c.JSON(http.StatusOK, gin.H{"tag": tag})
+}
+
+// UnbanTagHandler autorise de nouveau un tag interdit (modérateurs)
+func UnbanTagHandler(c *gin.Context) {
+	moderator, ok := currentModerator(c)
+	if !ok {
+		return
+	}
+	tag, ok := loadTag(c)
+	if !ok {
+		return
+	}
+
+	if err := lib.DB.Model(&tag).Update("banned", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'autorisation du tag"})
+		return
+	}
+
+	lib.LogActionWithDetails("unban_tag", moderator.Email, map[string]interface{}{"tag_id": tag.ID})
+
+	c.JSON(http.StatusOK, gin.H{"tag": tag})
+}
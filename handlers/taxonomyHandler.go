@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"slices"
+	"solfa-back/lib"
+	"solfa-back/models"
+)
+
+// TaxonomyTermRequest représente un terme de taxonomie saisi par un administrateur
+type TaxonomyTermRequest struct {
+	Slug     string `json:"slug"` // Déduit du libellé français s'il est absent
+	ParentID *uint  `json:"parent_id"`
+	LabelFR  string `json:"label_fr" binding:"required"`
+	LabelEN  string `json:"label_en"`
+	Position int    `json:"position"`
+}
+
+// Agrégations Elasticsearch renvoyées comme facettes, par taxonomie
+var taxonomyFacetFields = map[string]string{
+	models.TaxonomyGenre:            "genre_path",
+	models.TaxonomyVoicing:          "category.keyword",
+	models.TaxonomyLiturgicalSeason: "liturgical_seasons",
+	models.TaxonomyOccasion:         "occasions",
+}
+
+// classifyPartition valide les champs de taxonomie d'une partition (voir lib.ClassifyPartition)
+func classifyPartition(c *gin.Context, partition *models.Partition) bool {
+	if err := lib.ClassifyPartition(partition); err != nil {
+		if errors.Is(err, lib.ErrUnknownTaxonomyTerm) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des taxonomies"})
+		}
+		return false
+	}
+	return true
+}
+
+// taxonomyTree construit l'arbre des termes d'une taxonomie, libellés dans la langue demandée
+func taxonomyTree(terms []models.TaxonomyTerm, parentID *uint, lang string) []gin.H {
+	tree := []gin.H{}
+	for _, term := range terms {
+		if (parentID == nil) != (term.ParentID == nil) || (parentID != nil && *parentID != *term.ParentID) {
+			continue
+		}
+		tree = append(tree, gin.H{
+			"id":       term.ID,
+			"slug":     term.Slug,
+			"label":    term.Label(lang),
+			"label_fr": term.LabelFR,
+			"label_en": term.LabelEN,
+			"children": taxonomyTree(terms, &term.ID, lang),
+		})
+	}
+	return tree
+}
+
+// GetTaxonomiesHandler renvoie les taxonomies sous forme d'arbres ; ?lang=en choisit les libellés anglais
+func GetTaxonomiesHandler(c *gin.Context) {
+	var terms []models.TaxonomyTerm
+	if err := lib.DB.Order("position, label_fr").Find(&terms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des taxonomies"})
+		return
+	}
+
+	byTaxonomy := map[string][]models.TaxonomyTerm{}
+	for _, term := range terms {
+		byTaxonomy[term.Taxonomy] = append(byTaxonomy[term.Taxonomy], term)
+	}
+
+	lang := c.DefaultQuery("lang", "fr")
+	taxonomies := gin.H{}
+	for _, taxonomy := range lib.Taxonomies {
+		taxonomies[taxonomy] = taxonomyTree(byTaxonomy[taxonomy], nil, lang)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"taxonomies": taxonomies})
+}
+
+// CreateTaxonomyTermHandler ajoute un terme à une taxonomie (administrateurs)
+func CreateTaxonomyTermHandler(c *gin.Context) {
+	admin, ok := currentAdmin(c)
+	if !ok {
+		return
+	}
+	if !lib.IsTaxonomy(c.Param("taxonomy")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Taxonomie inconnue"})
+		return
+	}
+
+	var request TaxonomyTermRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	term := models.TaxonomyTerm{Taxonomy: c.Param("taxonomy")}
+	if !applyTaxonomyTermRequest(c, request, &term) {
+		return
+	}
+	if err := lib.DB.Create(&term).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement du terme"})
+		return
+	}
+
+	lib.LogActionWithDetails("create_taxonomy_term", admin.Email, map[string]interface{}{"term_id": term.ID, "taxonomy": term.Taxonomy})
+
+	c.JSON(http.StatusCreated, gin.H{"term": term})
+}
+
+// UpdateTaxonomyTermHandler modifie un terme (administrateurs). Le slug, référencé par les
+// partitions, ne peut pas être modifié ; seuls les libellés, le parent et l'ordre changent.
+func UpdateTaxonomyTermHandler(c *gin.Context) {
+	admin, ok := currentAdmin(c)
+	if !ok {
+		return
+	}
+	term, ok := loadTaxonomyTerm(c)
+	if !ok {
+		return
+	}
+
+	var request TaxonomyTermRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+	request.Slug = term.Slug
+
+	previousParentID := term.ParentID
+	if !applyTaxonomyTermRequest(c, request, &term) {
+		return
+	}
+	if err := lib.DB.Save(&term).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour du terme"})
+		return
+	}
+
+	// Déplacer un genre change le chemin des partitions qui en relèvent
+	if term.Taxonomy == models.TaxonomyGenre && !sameTermID(previousParentID, term.ParentID) {
+		lib.EnqueueJob("refresh_genre_paths", func() error {
+			return lib.RefreshGenrePaths(term.Slug)
+		})
+	}
+
+	lib.LogActionWithDetails("update_taxonomy_term", admin.Email, map[string]interface{}{"term_id": term.ID, "taxonomy": term.Taxonomy})
+
+	c.JSON(http.StatusOK, gin.H{"term": term})
+}
+
+// DeleteTaxonomyTermHandler supprime un terme (administrateurs), s'il n'a pas de sous-termes
+// et qu'aucune partition ne l'utilise
+func DeleteTaxonomyTermHandler(c *gin.Context) {
+	admin, ok := currentAdmin(c)
+	if !ok {
+		return
+	}
+	term, ok := loadTaxonomyTerm(c)
+	if !ok {
+		return
+	}
+
+	var children int64
+	lib.DB.Model(&models.TaxonomyTerm{}).Where("parent_id = ?", term.ID).Count(&children)
+	if children > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Le terme a des sous-termes"})
+		return
+	}
+	used, err := lib.TaxonomyTermUsage(term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la vérification du terme"})
+		return
+	}
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Le terme est utilisé par des partitions", "partition_count": used})
+		return
+	}
+
+	if err := lib.DB.Delete(&term).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression du terme"})
+		return
+	}
+
+	lib.LogActionWithDetails("delete_taxonomy_term", admin.Email, map[string]interface{}{"term_id": term.ID, "taxonomy": term.Taxonomy})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Terme supprimé"})
+}
+
+// sameTermID compare deux références facultatives à un terme
+func sameTermID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// loadTaxonomyTerm recherche le terme désigné par le paramètre :id
+func loadTaxonomyTerm(c *gin.Context) (models.TaxonomyTerm, bool) {
+	var term models.TaxonomyTerm
+	if err := lib.DB.First(&term, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Terme non trouvé"})
+		return term, false
+	}
+	return term, true
+}
+
+// applyTaxonomyTermRequest valide la requête et reporte ses valeurs sur le terme
+func applyTaxonomyTermRequest(c *gin.Context, request TaxonomyTermRequest, term *models.TaxonomyTerm) bool {
+	slug := lib.Slugify(request.Slug)
+	if slug == "" {
+		slug = lib.Slugify(request.LabelFR)
+	}
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le libellé du terme est requis"})
+		return false
+	}
+
+	var existing int64
+	lib.DB.Model(&models.TaxonomyTerm{}).
+		Where("taxonomy = ? AND slug = ? AND id <> ?", term.Taxonomy, slug, term.ID).
+		Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Un terme de cette taxonomie porte déjà ce slug"})
+		return false
+	}
+
+	if request.ParentID != nil {
+		if term.Taxonomy != models.TaxonomyGenre {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Seuls les genres peuvent avoir un parent"})
+			return false
+		}
+		var parent models.TaxonomyTerm
+		if err := lib.DB.First(&parent, *request.ParentID).Error; err != nil || parent.Taxonomy != term.Taxonomy {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Terme parent non trouvé"})
+			return false
+		}
+		// Un terme ne peut pas être rattaché à l'un de ses descendants
+		if term.ID != 0 {
+			path, err := lib.TaxonomyPath(parent)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des taxonomies"})
+				return false
+			}
+			if slices.Contains(path, term.Slug) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Un terme ne peut pas être son propre parent"})
+				return false
+			}
+		}
+	}
+
+	term.Slug = slug
+	term.ParentID = request.ParentID
+	term.LabelFR = request.LabelFR
+	term.LabelEN = request.LabelEN
+	term.Position = request.Position
+	return true
+}
+
+// taxonomyFacetAggregations construit les agrégations Elasticsearch des facettes de recherche
+func taxonomyFacetAggregations() map[string]interface{} {
+	aggs := map[string]interface{}{}
+	for taxonomy, field := range taxonomyFacetFields {
+		aggs[taxonomy] = map[string]interface{}{
+			"terms": map[string]interface{}{"field": field, "size": 50},
+		}
+	}
+	return aggs
+}
+
+// taxonomyFacets convertit les agrégations Elasticsearch en facettes, avec les libellés
+// traduits des termes ; les valeurs qui ne sont pas des termes connus sont ignorées
+func taxonomyFacets(aggregations map[string]interface{}) gin.H {
+	terms, err := lib.TaxonomyTermsBySlug()
+	if err != nil {
+		return gin.H{}
+	}
+
+	facets := gin.H{}
+	for taxonomy := range taxonomyFacetFields {
+		aggregation, _ := aggregations[taxonomy].(map[string]interface{})
+		buckets, _ := aggregation["buckets"].([]interface{})
+		values := []gin.H{}
+		for _, bucket := range buckets {
+			bucket, _ := bucket.(map[string]interface{})
+			slug, _ := bucket["key"].(string)
+			term, ok := terms[taxonomy][slug]
+			if !ok {
+				continue
+			}
+			values = append(values, gin.H{
+				"slug":     slug,
+				"label_fr": term.LabelFR,
+				"label_en": term.LabelEN,
+				"count":    bucket["doc_count"],
+			})
+		}
+		facets[taxonomy] = values
+	}
+	return facets
+}
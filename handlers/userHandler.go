@@ -15,6 +15,60 @@ func GetSolfa(c *gin.Context) {
 
 
 
+// currentUser récupère l'utilisateur connecté à partir du token JWT
+func currentUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	claims, err := lib.ExtractUserClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Accès non autorisé: " + err.Error()})
+		return user, false
+	}
+	if err := lib.DB.Where("email = ?", claims.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": userNotFoundError})
+		return user, false
+	}
+	return user, true
+}
+
+// optionalUserID retourne l'identifiant de l'utilisateur connecté, ou 0 pour un visiteur anonyme
+func optionalUserID(c *gin.Context) uint {
+	claims, err := lib.ExtractUserClaims(c)
+	if err != nil {
+		return 0
+	}
+	var user models.User
+	if err := lib.DB.Select("id").Where("email = ?", claims.Email).First(&user).Error; err != nil {
+		return 0
+	}
+	return user.ID
+}
+
+// currentModerator récupère l'utilisateur connecté et vérifie qu'il est modérateur
+func currentModerator(c *gin.Context) (models.User, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		return user, false
+	}
+	if !user.IsModerator() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Action réservée aux modérateurs"})
+		return user, false
+	}
+	return user, true
+}
+
+// currentAdmin récupère l'utilisateur connecté et vérifie qu'il est administrateur
+func currentAdmin(c *gin.Context) (models.User, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		return user, false
+	}
+	if user.Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Action réservée aux administrateurs"})
+		return user, false
+	}
+	return user, true
+}
+
 // GetCurrentUser récupère les infos du profil utilisateur connecté
 func GetCurrentUser(c *gin.Context) {
 	// Récupérer l'utilisateur à partir du token JWT
@@ -31,6 +85,7 @@ func GetCurrentUser(c *gin.Context) {
 		"id":       user.ID,
 		"username": user.Username,
 		"email":    user.Email,
+		"role":     user.Role,
 	})
 }
 
@@ -0,0 +1,523 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"solfa-back/lib"
+	"solfa-back/models"
+	"strconv"
+	"time"
+)
+
+// UpdatePartitionRequest représente les métadonnées modifiables d'une partition ;
+// seuls les champs présents sont modifiés
+type UpdatePartitionRequest struct {
+	Title             *string                    `json:"title"`
+	Composer          *string                    `json:"composer"`
+	Genre             *string                    `json:"genre"`
+	Category          *string                    `json:"category"`
+	LiturgicalSeasons *[]string                  `json:"liturgical_seasons"`
+	Occasions         *[]string                  `json:"occasions"`
+	Voicing           *string                    `json:"voicing"`
+	Divisi            *bool                      `json:"divisi"`
+	Accompaniment     *string                    `json:"accompaniment"`
+	Instrumentation   *[]string                  `json:"instrumentation"`
+	Soloists          *[]string                  `json:"soloists"`
+	Language          *string                    `json:"language"`
+	Description       *string                    `json:"description"`
+	Translations      *[]models.TitleTranslation `json:"translations"`
+	LyricsLanguages   *[]string                  `json:"lyrics_languages"`
+	Difficulty        *int                       `json:"difficulty"` // 0 rétablit la difficulté estimée
+	Licence           *string                    `json:"licence"`
+	Publisher         *string                    `json:"publisher"`
+	Permission        *string                    `json:"permission"`
+	Source            *string                    `json:"source"`
+	Edition           *string                    `json:"edition"`
+	Arranger          *string                    `json:"arranger"`
+	ArrangerDeathYear *int                       `json:"arranger_death_year"`
+	ReleaseDate       *string                    `json:"release_date"`
+}
+
+// canEditPartition indique si l'utilisateur peut modifier une partition : son uploader, les modérateurs
+// et, pour une partition privée, les chefs de son organisation
+func canEditPartition(user models.User, partition models.Partition) bool {
+	if user.IsModerator() || (partition.UploadedBy != 0 && partition.UploadedBy == user.ID) {
+		return true
+	}
+	// Les chefs d'une organisation gèrent les partitions de sa bibliothèque privée
+	if partition.OrganizationID != nil {
+		role, err := lib.OrganizationRole(*partition.OrganizationID, user.ID)
+		return err == nil && lib.HasOrganizationRole(role, models.OrganizationRoleDirector)
+	}
+	return false
+}
+
+// loadEditablePartition charge la partition désignée par :id (avec ses paroles) et vérifie
+// que l'utilisateur connecté peut la modifier
+func loadEditablePartition(c *gin.Context) (models.Partition, models.User, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		return models.Partition{}, user, false
+	}
+
+	var partition models.Partition
+	if err := lib.DB.Preload("Lyrics").First(&partition, "id = ?", c.Param("id")).Error; err != nil || !partitionVisible(c, partition) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partition non trouvée"})
+		return partition, user, false
+	}
+	if !canEditPartition(user, partition) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Seuls l'uploader de la partition, les modérateurs et les chefs de son organisation peuvent la modifier"})
+		return partition, user, false
+	}
+	return partition, user, true
+}
+
+// savePartitionVersion archive l'état précédent d'une partition puis enregistre son nouvel état
+// comme version suivante ; une partition validée modifiée par un non-modérateur repasse en revue
+func savePartitionVersion(previous, updated models.Partition, reason string, user models.User) (models.Partition, error) {
+	updated.Version = previous.Version + 1
+	if !user.IsModerator() && updated.Status == "validated" {
+		updated.Status = "staging"
+		updated.ValidatedBy = ""
+	}
+	// Les images générées ne correspondent plus à un fichier sans aperçu
+	if updated.Format != lib.FormatPDF && updated.Format != lib.FormatImage {
+		updated.ThumbnailURL = ""
+		updated.PreviewURL = ""
+	}
+
+	// Le compositeur, l'arrangeur ou la licence ont pu changer
+	if err := lib.RefreshPartitionLicence(&updated); err != nil {
+		return updated, err
+	}
+
+	err := lib.DB.Transaction(func(tx *gorm.DB) error {
+		version := models.PartitionVersion{
+			PartitionID: previous.ID,
+			Version:     previous.Version,
+			Snapshot:    previous,
+			Reason:      reason,
+			ReplacedBy:  user.Email,
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Omit("Lyrics").Save(&updated).Error; err != nil {
+			return err
+		}
+
+		// Les paroles suivent le fichier : elles sont remplacées en même temps que lui
+		if reason != models.VersionMetadata {
+			if err := tx.Where("partition_id = ?", updated.ID).Delete(&models.PartitionLyric{}).Error; err != nil {
+				return err
+			}
+			for i := range updated.Lyrics {
+				updated.Lyrics[i].ID = 0
+				updated.Lyrics[i].PartitionID = updated.ID
+			}
+			if len(updated.Lyrics) > 0 {
+				if err := tx.Create(&updated.Lyrics).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return updated, err
+	}
+
+	if err := lib.ReindexPartitionInES(updated); err != nil {
+		return updated, err
+	}
+	if updated.Path != previous.Path {
+		lib.EnqueuePreviewGeneration(updated)
+	}
+	return updated, nil
+}
+
+// UpdatePartitionHandler modifie les métadonnées d'une partition en conservant la version précédente
+func UpdatePartitionHandler(c *gin.Context) {
+	partition, user, ok := loadEditablePartition(c)
+	if !ok {
+		return
+	}
+
+	var request UpdatePartitionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides", "details": err.Error()})
+		return
+	}
+
+	updated := partition
+	if request.Title != nil {
+		if *request.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Le titre de la partition est requis"})
+			return
+		}
+		updated.Title = *request.Title
+	}
+	if request.Composer != nil {
+		updated.Composer = *request.Composer
+		if err := resolvePartitionComposer(&updated); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'identification du compositeur"})
+			return
+		}
+	}
+	if request.Genre != nil {
+		updated.Genre = *request.Genre
+	}
+	if request.Category != nil {
+		updated.Category = *request.Category
+	}
+	if request.LiturgicalSeasons != nil {
+		updated.LiturgicalSeasons = *request.LiturgicalSeasons
+	}
+	if request.Occasions != nil {
+		updated.Occasions = *request.Occasions
+	}
+	// Les champs de taxonomie ne sont vérifiés que s'ils sont modifiés, les partitions
+	// plus anciennes pouvant porter des valeurs libres
+	if request.Genre != nil || request.Category != nil || request.LiturgicalSeasons != nil || request.Occasions != nil {
+		if !classifyPartition(c, &updated) {
+			return
+		}
+	}
+	if request.Voicing != nil {
+		updated.Voicing = *request.Voicing
+	}
+	if request.Divisi != nil {
+		updated.Divisi = *request.Divisi
+	}
+	if request.Accompaniment != nil {
+		updated.Accompaniment = *request.Accompaniment
+	}
+	if request.Instrumentation != nil {
+		updated.Instrumentation = *request.Instrumentation
+	}
+	if request.Soloists != nil {
+		updated.Soloists = *request.Soloists
+	}
+	if err := lib.NormalizeEnsemble(&updated.EnsembleMetadata); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Language != nil {
+		updated.Language = *request.Language
+	}
+	if request.Description != nil {
+		updated.Description = *request.Description
+	}
+	if request.Translations != nil {
+		updated.Translations = *request.Translations
+	}
+	if request.LyricsLanguages != nil {
+		updated.LyricsLanguages = *request.LyricsLanguages
+	}
+	if err := lib.NormalizeLanguageMetadata(&updated.LanguageMetadata); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Difficulty != nil {
+		if !lib.ValidDifficulty(*request.Difficulty) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "La difficulté doit être comprise entre 1 et 5"})
+			return
+		}
+		// Le niveau fixé par un modérateur ne peut être modifié que par un modérateur
+		if partition.DifficultySource == models.DifficultyByModerator && !user.IsModerator() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "La difficulté a été fixée par un modérateur"})
+			return
+		}
+		updated.Difficulty = *request.Difficulty
+		updated.DifficultySource = models.DifficultyByUploader
+		if user.IsModerator() {
+			updated.DifficultySource = models.DifficultyByModerator
+		}
+		if updated.Difficulty == 0 {
+			updated.DifficultySource = ""
+			lib.ApplyEstimatedDifficulty(&updated)
+		}
+	}
+	if request.Licence != nil {
+		licence, err := lib.NormalizeLicence(*request.Licence)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updated.Licence = licence
+	}
+	if request.Publisher != nil {
+		updated.Publisher = *request.Publisher
+	}
+	if request.Permission != nil {
+		updated.Permission = *request.Permission
+	}
+	if request.Source != nil {
+		updated.Source = *request.Source
+	}
+	if request.Edition != nil {
+		updated.Edition = *request.Edition
+	}
+	if request.Arranger != nil {
+		updated.Arranger = *request.Arranger
+	}
+	if request.ArrangerDeathYear != nil {
+		updated.ArrangerDeathYear = *request.ArrangerDeathYear
+	}
+	if request.ReleaseDate != nil {
+		updated.ReleaseDate = time.Time{}
+		if *request.ReleaseDate != "" {
+			parsedDate, err := time.Parse("2006-01-02", *request.ReleaseDate)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Format de date invalide, utilisez YYYY-MM-DD"})
+				return
+			}
+			updated.ReleaseDate = parsedDate
+		}
+	}
+
+	changes, err := lib.DiffPartitionMetadata(partition, updated)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la comparaison des versions"})
+		return
+	}
+	if len(changes) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Aucune modification", "partition": partition})
+		return
+	}
+
+	updated, err = savePartitionVersion(partition, updated, models.VersionMetadata, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement de la nouvelle version: " + err.Error()})
+		return
+	}
+
+	lib.LogActionWithDetails("update_partition", user.Email, map[string]interface{}{"partition_id": partition.ID, "version": updated.Version})
+
+	c.JSON(http.StatusOK, gin.H{"partition": updated, "changes": changes})
+}
+
+// ReplacePartitionFileHandler remplace le fichier d'une partition ; le fichier précédent est
+// conservé dans Minio et reste associé à la version précédente
+func ReplacePartitionFileHandler(c *gin.Context) {
+	partition, user, ok := loadEditablePartition(c)
+	if !ok {
+		return
+	}
+
+	file, err := c.FormFile("partition_file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erreur lors de l'upload du fichier"})
+		return
+	}
+	srcFile, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Impossible d'ouvrir le fichier"})
+		return
+	}
+	defer srcFile.Close()
+	content, err := lib.ReadPartitionFile(srcFile)
+	if errors.Is(err, lib.ErrPartitionFileTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Impossible de lire le fichier"})
+		return
+	}
+
+	// Les informations extraites de l'ancien fichier sont recalculées à partir du nouveau
+	base := partition
+	base.Format = lib.DetectPartitionFormat(file.Filename)
+	base.MusicXMLPath = ""
+	base.MusicMetadata = models.MusicMetadata{}
+	base.DocumentMetadata = models.DocumentMetadata{}
+	base.Lyrics = nil
+
+	uploads, err := analyzeUploadedFile(base, file.Filename, content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(uploads) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le fichier de remplacement doit contenir un seul morceau"})
+		return
+	}
+
+	updated, err := putUploadedFiles(c, uploads[0])
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err = savePartitionVersion(partition, updated, models.VersionFile, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement de la nouvelle version: " + err.Error()})
+		return
+	}
+
+	lib.LogActionWithDetails("replace_partition_file", user.Email, map[string]interface{}{"partition_id": partition.ID, "version": updated.Version, "path": updated.Path})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Fichier remplacé avec succès", "partition": updated})
+}
+
+// loadPartitionVersions charge une partition et ses versions précédentes, de la plus récente à la plus ancienne
+func loadPartitionVersions(c *gin.Context) (models.Partition, []models.PartitionVersion, bool) {
+	var partition models.Partition
+	if err := lib.DB.Preload("Lyrics").First(&partition, "id = ?", c.Param("id")).Error; err != nil || !partitionVisible(c, partition) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partition non trouvée"})
+		return partition, nil, false
+	}
+
+	var versions []models.PartitionVersion
+	if err := lib.DB.Where("partition_id = ?", partition.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la lecture des versions"})
+		return partition, nil, false
+	}
+	return partition, versions, true
+}
+
+// partitionAtVersion retourne l'état d'une partition à une version donnée
+func partitionAtVersion(partition models.Partition, versions []models.PartitionVersion, number int) (models.Partition, bool) {
+	if number == partition.Version {
+		return partition, true
+	}
+	for _, version := range versions {
+		if version.Version == number {
+			return version.Snapshot, true
+		}
+	}
+	return models.Partition{}, false
+}
+
+// GetPartitionVersionsHandler liste les versions précédentes d'une partition, avec pour
+// chacune les champs modifiés par la version suivante
+func GetPartitionVersionsHandler(c *gin.Context) {
+	partition, versions, ok := loadPartitionVersions(c)
+	if !ok {
+		return
+	}
+	hideVersionLyrics(c, &partition, versions)
+
+	// L'auteur de chaque modification n'est indiqué qu'aux personnes pouvant modifier la partition
+	var viewer models.User
+	if userID := optionalUserID(c); userID != 0 {
+		lib.DB.First(&viewer, userID)
+	}
+	showAuthors := viewer.ID != 0 && canEditPartition(viewer, partition)
+
+	var history []gin.H
+	next := partition
+	for _, version := range versions {
+		changes, err := lib.DiffPartitionMetadata(version.Snapshot, next)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la comparaison des versions"})
+			return
+		}
+		entry := gin.H{
+			"version":     version.Version,
+			"reason":      version.Reason,
+			"replaced_at": version.CreatedAt,
+			"snapshot":    version.Snapshot,
+			"changes":     changes,
+		}
+		if showAuthors {
+			entry["replaced_by"] = version.ReplacedBy
+		}
+		history = append(history, entry)
+		next = version.Snapshot
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"partition_id":    partition.ID,
+		"current_version": partition.Version,
+		"versions":        history,
+	})
+}
+
+// DiffPartitionVersionsHandler compare les métadonnées de deux versions d'une partition
+// (?from=, ?to= ; par défaut la version précédente et la version courante)
+func DiffPartitionVersionsHandler(c *gin.Context) {
+	partition, versions, ok := loadPartitionVersions(c)
+	if !ok {
+		return
+	}
+	hideVersionLyrics(c, &partition, versions)
+
+	from, to := partition.Version-1, partition.Version
+	for param, target := range map[string]*int{"from": &from, "to": &to} {
+		if value := c.Query(param); value != "" {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Le paramètre '%s' doit être un numéro de version", param)})
+				return
+			}
+			*target = number
+		}
+	}
+
+	fromPartition, foundFrom := partitionAtVersion(partition, versions, from)
+	toPartition, foundTo := partitionAtVersion(partition, versions, to)
+	if !foundFrom || !foundTo {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version non trouvée"})
+		return
+	}
+
+	changes, err := lib.DiffPartitionMetadata(fromPartition, toPartition)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la comparaison des versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"partition_id": partition.ID, "from": from, "to": to, "changes": changes})
+}
+
+// hideVersionLyrics retire les paroles de la partition et de ses versions précédentes lorsque
+// l'utilisateur ne peut pas la télécharger : l'historique ne doit pas contourner cette règle
+func hideVersionLyrics(c *gin.Context, partition *models.Partition, versions []models.PartitionVersion) {
+	if status, _ := partitionDownloadRefusal(c, partition); status == 0 {
+		return
+	}
+	partition.Lyrics = nil
+	for i := range versions {
+		versions[i].Snapshot.Lyrics = nil
+	}
+}
+
+// RollbackPartitionHandler rétablit une version précédente d'une partition (modérateurs) ;
+// l'état courant est lui-même archivé, le retour en arrière peut donc être annulé
+func RollbackPartitionHandler(c *gin.Context) {
+	moderator, ok := currentModerator(c)
+	if !ok {
+		return
+	}
+	partition, versions, ok := loadPartitionVersions(c)
+	if !ok {
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number == partition.Version {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Numéro de version invalide"})
+		return
+	}
+	snapshot, found := partitionAtVersion(partition, versions, number)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version non trouvée"})
+		return
+	}
+
+	updated, err := savePartitionVersion(partition, lib.RestorePartitionSnapshot(partition, snapshot), models.VersionRollback, moderator)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du retour à la version demandée: " + err.Error()})
+		return
+	}
+
+	lib.LogActionWithDetails("rollback_partition", moderator.Email, map[string]interface{}{"partition_id": partition.ID, "restored_version": number, "version": updated.Version})
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Version %d rétablie", number), "partition": updated})
+}
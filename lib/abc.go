@@ -0,0 +1,898 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"solfa-back/models"
+	"strconv"
+	"strings"
+)
+
+// ABCTune représente un morceau d'un fichier ABC (délimité par un champ X:)
+type ABCTune struct {
+	Number     int      `json:"number"`
+	Title      string   `json:"title"`
+	Composer   string   `json:"composer"`
+	Key        string   `json:"key"`
+	Meter      string   `json:"meter"`
+	UnitLength string   `json:"unit_length"`
+	Tempo      string   `json:"tempo"`
+	Header     []string `json:"-"` // En-tête du fichier, commun à tous les morceaux
+	Lines      []string `json:"-"` // Lignes du morceau, champ X: compris
+}
+
+// ABCNote est une note (ou un accord, ou un silence) extraite du corps d'un morceau ABC
+type ABCNote struct {
+	Pitches  []int // Numéros MIDI des hauteurs, vide pour un silence
+	Duration int   // Durée en divisions (ABCDivisions par noire)
+	TieStart bool  // La note est liée à la suivante
+}
+
+// ABCMeasure regroupe les notes d'une mesure ainsi que les changements
+// de tonalité ou de mesure intervenus au début de celle-ci
+type ABCMeasure struct {
+	Notes []ABCNote
+	Key   string
+	Meter string
+}
+
+// Nombre de divisions par noire utilisé pour les durées (multiple de 2, 3 et 4)
+const ABCDivisions = 96
+
+// Nombre maximal de mesures d'un silence de plusieurs mesures (Z ou X)
+const maxABCRestMeasures = 1000
+
+// Nombre maximal de notes et silences d'un morceau, silences de plusieurs mesures compris
+const maxABCNotes = 200000
+
+// ParseABC découpe un fichier ABC en morceaux et en lit les champs d'en-tête
+func ParseABC(content string) ([]ABCTune, error) {
+	var tunes []ABCTune
+	var header []string
+	var current *ABCTune
+
+	content = strings.TrimPrefix(content, "\ufeff")
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		field, value := abcField(trimmed)
+
+		if field == "X" {
+			number, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				number = len(tunes) + 1
+			}
+			tunes = append(tunes, ABCTune{Number: number, Header: header})
+			current = &tunes[len(tunes)-1]
+		}
+
+		if current == nil {
+			// Lignes situées avant le premier morceau : en-tête de fichier
+			header = append(header, line)
+			continue
+		}
+		current.Lines = append(current.Lines, line)
+
+		switch field {
+		case "T":
+			if current.Title == "" {
+				current.Title = value
+			}
+		case "C":
+			if current.Composer == "" {
+				current.Composer = value
+			}
+		case "K":
+			if current.Key == "" {
+				current.Key = value
+			}
+		case "M":
+			if current.Meter == "" {
+				current.Meter = value
+			}
+		case "L":
+			if current.UnitLength == "" {
+				current.UnitLength = value
+			}
+		case "Q":
+			if current.Tempo == "" {
+				current.Tempo = value
+			}
+		}
+	}
+
+	if len(tunes) == 0 {
+		return nil, errors.New("aucun morceau trouvé (champ X: manquant)")
+	}
+
+	// Les champs de l'en-tête du fichier s'appliquent aux morceaux qui ne les redéfinissent pas
+	for i := range tunes {
+		for _, line := range header {
+			field, value := abcField(strings.TrimSpace(line))
+			switch {
+			case field == "C" && tunes[i].Composer == "":
+				tunes[i].Composer = value
+			case field == "M" && tunes[i].Meter == "":
+				tunes[i].Meter = value
+			case field == "L" && tunes[i].UnitLength == "":
+				tunes[i].UnitLength = value
+			case field == "Q" && tunes[i].Tempo == "":
+				tunes[i].Tempo = value
+			}
+		}
+	}
+
+	return tunes, nil
+}
+
+// abcField retourne le nom et la valeur d'un champ d'en-tête ABC ("T:Titre")
+func abcField(line string) (string, string) {
+	if len(line) < 2 || line[1] != ':' || line[0] < 'A' || line[0] > 'z' {
+		return "", ""
+	}
+	return string(line[0]), strings.TrimSpace(line[2:])
+}
+
+// isABCBodyLine indique si une ligne contient de la musique (et non un champ ou un commentaire)
+func isABCBodyLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "%") {
+		return false
+	}
+	field, _ := abcField(trimmed)
+	return field == "" || field == "|"
+}
+
+// Source retourne le texte ABC autonome du morceau (en-tête de fichier compris)
+func (t ABCTune) Source() string {
+	lines := append([]string{}, t.Header...)
+	lines = append(lines, t.Lines...)
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n"
+}
+
+// Metadata calcule les métadonnées musicales du morceau à partir de ses champs et de ses notes
+func (t ABCTune) Metadata() (models.MusicMetadata, error) {
+	metadata := models.MusicMetadata{
+		TimeSignature: abcMeterSignature(t.Meter),
+		KeySignature:  abcKeyName(t.Key),
+		Tempo:         abcQuarterTempo(t.Tempo, t.unitLength()),
+	}
+	if metadata.TimeSignature != "" {
+		metadata.TimeSignatures = []models.TimeSignatureChange{{Signature: metadata.TimeSignature}}
+	}
+	if metadata.KeySignature != "" {
+		metadata.KeySignatures = []models.KeySignatureChange{{Key: metadata.KeySignature}}
+	}
+	if metadata.Tempo > 0 {
+		metadata.TempoMap = []models.TempoChange{{BPM: metadata.Tempo}}
+	}
+
+	measures, err := t.Measures()
+	if err != nil {
+		return metadata, err
+	}
+
+	// Durée estimée au tempo indiqué (120 à la noire par défaut, comme pour le MIDI)
+	tempo := metadata.Tempo
+	if tempo == 0 {
+		tempo = 120
+	}
+	totalDivisions := 0
+	lowest, highest := -1, -1
+	for _, measure := range measures {
+		for _, note := range measure.Notes {
+			totalDivisions += note.Duration
+			for _, pitch := range note.Pitches {
+				if lowest < 0 || pitch < lowest {
+					lowest = pitch
+				}
+				if pitch > highest {
+					highest = pitch
+				}
+			}
+		}
+	}
+	metadata.Duration = math.Round(float64(totalDivisions)/ABCDivisions*60/tempo*100) / 100
+	if lowest >= 0 {
+		metadata.LowestPitch = lowest
+		metadata.HighestPitch = highest
+	}
+	melody := abcMelody(measures)
+	SetMelodyIncipit(&metadata, melody)
+	metadata.EstimatedDifficulty = EstimateDifficulty(metadata, melody)
+
+	return metadata, nil
+}
+
+// abcMelody extrait la ligne mélodique d'un morceau : la note la plus aiguë
+// de chaque accord, sans les silences ni les notes prolongées par une liaison
+func abcMelody(measures []ABCMeasure) []int {
+	var melody []int
+	tied := false
+	for _, measure := range measures {
+		for _, note := range measure.Notes {
+			if len(note.Pitches) > 0 && !tied {
+				top := note.Pitches[0]
+				for _, pitch := range note.Pitches[1:] {
+					if pitch > top {
+						top = pitch
+					}
+				}
+				melody = append(melody, top)
+			}
+			tied = note.TieStart
+		}
+	}
+	return melody
+}
+
+// unitLength retourne la longueur de note par défaut (champ L:) en fraction de ronde
+func (t ABCTune) unitLength() float64 {
+	if length := parseABCFraction(t.UnitLength); length > 0 {
+		return length
+	}
+	// Sans L:, la longueur par défaut dépend de la mesure
+	if meter := abcMeterValue(t.Meter); meter > 0 && meter < 0.75 {
+		return 1.0 / 16
+	}
+	return 1.0 / 8
+}
+
+// Measures lit le corps du morceau et le découpe en mesures de notes
+func (t ABCTune) Measures() ([]ABCMeasure, error) {
+	parser := abcParser{
+		unit:  t.unitLength(),
+		meter: t.Meter,
+	}
+	parser.setKey(t.Key)
+
+	inBody := false
+	for _, line := range t.Lines {
+		field, value := abcField(strings.TrimSpace(line))
+		if field == "K" && !inBody {
+			// Le premier K: marque la fin de l'en-tête du morceau
+			inBody = true
+			continue
+		}
+		if !inBody {
+			continue
+		}
+		switch field {
+		case "K":
+			parser.setKey(value)
+			parser.pendingKey = value
+			continue
+		case "M":
+			parser.meter = value
+			parser.pendingMeter = value
+			continue
+		case "L":
+			if length := parseABCFraction(value); length > 0 {
+				parser.unit = length
+			}
+			continue
+		}
+		if !isABCBodyLine(line) {
+			continue
+		}
+		if err := parser.parseLine(line); err != nil {
+			return nil, err
+		}
+	}
+	parser.closeMeasure()
+
+	if len(parser.measures) == 0 {
+		return nil, errors.New("aucune note trouvée dans le morceau")
+	}
+	return parser.measures, nil
+}
+
+// abcParser conserve l'état de lecture du corps d'un morceau ABC
+type abcParser struct {
+	unit         float64
+	meter        string
+	keyAlter     map[byte]int // Altérations de l'armure par nom de note
+	barAlter     map[string]int
+	measures     []ABCMeasure
+	current      ABCMeasure
+	pendingKey   string
+	pendingMeter string
+	tupletLeft   int
+	tupletRatio  float64
+	brokenFactor float64 // Facteur à appliquer à la note suivante (rythme pointé > ou <)
+	noteCount    int     // Notes et silences lus, limités à maxABCNotes
+}
+
+// setKey met à jour les altérations de l'armure à partir d'un champ K:
+func (p *abcParser) setKey(key string) {
+	p.keyAlter = map[byte]int{}
+	fifths, _ := abcKeyFifths(key)
+	sharps, flats := "FCGDAEB", "BEADGCF"
+	for i := 0; i < fifths && i < 7; i++ {
+		p.keyAlter[sharps[i]] = 1
+	}
+	for i := 0; i < -fifths && i < 7; i++ {
+		p.keyAlter[flats[i]] = -1
+	}
+}
+
+// closeMeasure termine la mesure en cours si elle contient des notes
+func (p *abcParser) closeMeasure() {
+	p.barAlter = nil
+	if len(p.current.Notes) == 0 {
+		return
+	}
+	p.measures = append(p.measures, p.current)
+	p.current = ABCMeasure{}
+}
+
+// addNote ajoute une note à la mesure en cours en appliquant n-olets et rythmes pointés
+func (p *abcParser) addNote(pitches []int, length float64) {
+	if p.brokenFactor != 0 {
+		length *= p.brokenFactor
+		p.brokenFactor = 0
+	}
+	if p.tupletLeft > 0 {
+		length *= p.tupletRatio
+		p.tupletLeft--
+	}
+	if len(p.current.Notes) == 0 {
+		p.current.Key, p.pendingKey = p.pendingKey, ""
+		p.current.Meter, p.pendingMeter = p.pendingMeter, ""
+	}
+	duration := int(math.Round(length * 4 * ABCDivisions))
+	p.noteCount++
+	p.current.Notes = append(p.current.Notes, ABCNote{Pitches: pitches, Duration: duration})
+}
+
+// parseLine lit une ligne de musique ABC
+func (p *abcParser) parseLine(line string) error {
+	if i := strings.Index(line, "%"); i >= 0 {
+		line = line[:i]
+	}
+
+	for i := 0; i < len(line); {
+		if p.noteCount > maxABCNotes {
+			return fmt.Errorf("morceau trop long (%d notes au maximum)", maxABCNotes)
+		}
+		ch := line[i]
+		switch {
+		case ch == '"':
+			// Annotation ou symbole d'accord : ignoré
+			end := strings.IndexByte(line[i+1:], '"')
+			if end < 0 {
+				return nil
+			}
+			i += end + 2
+
+		case ch == '!' || ch == '+':
+			// Décoration longue : ignorée
+			end := strings.IndexByte(line[i+1:], ch)
+			if end < 0 {
+				i++
+				continue
+			}
+			i += end + 2
+
+		case ch == '{':
+			// Notes d'agrément : ignorées
+			end := strings.IndexByte(line[i:], '}')
+			if end < 0 {
+				return nil
+			}
+			i += end + 1
+
+		case ch == '[' && i+2 < len(line) && line[i+2] == ':' && line[i+1] >= 'A' && line[i+1] <= 'Z':
+			// Champ en ligne, ex: [K:G] ou [M:3/4]
+			end := strings.IndexByte(line[i:], ']')
+			if end < 0 {
+				return nil
+			}
+			value := strings.TrimSpace(line[i+3 : i+end])
+			switch line[i+1] {
+			case 'K':
+				p.setKey(value)
+				p.pendingKey = value
+			case 'M':
+				p.meter = value
+				p.pendingMeter = value
+			case 'L':
+				if length := parseABCFraction(value); length > 0 {
+					p.unit = length
+				}
+			}
+			i += end + 1
+
+		case ch == '[' && i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9':
+			// Début de reprise numérotée, ex: [1
+			i += 2
+
+		case ch == '[' && i+1 < len(line) && line[i+1] == '|':
+			p.closeMeasure()
+			i += 2
+
+		case ch == '[':
+			// Accord : on lit les notes jusqu'au crochet fermant
+			end := strings.IndexByte(line[i:], ']')
+			if end < 0 {
+				return errors.New("accord non fermé")
+			}
+			var pitches []int
+			var length float64
+			inner := line[i+1 : i+end]
+			for j := 0; j < len(inner); {
+				pitch, noteLength, n, ok := p.readNote(inner[j:])
+				if !ok {
+					j++
+					continue
+				}
+				if len(pitches) == 0 {
+					length = noteLength
+				}
+				pitches = append(pitches, pitch)
+				j += n
+			}
+			i += end + 1
+			// La longueur peut aussi être indiquée après l'accord
+			multiplier, n := readABCLength(line[i:])
+			i += n
+			if len(pitches) > 0 {
+				p.addNote(pitches, length*multiplier)
+			}
+
+		case ch == '|' || ch == ':':
+			// Barre de mesure (|, ||, |], |:, :|, ::), éventuellement suivie d'un numéro de reprise
+			for i < len(line) && (line[i] == '|' || line[i] == ':' || line[i] == ']') {
+				i++
+			}
+			for i < len(line) && (line[i] >= '0' && line[i] <= '9' || line[i] == ',' || line[i] == '-') {
+				i++
+			}
+			p.closeMeasure()
+
+		case ch == '(':
+			// N-olet, ex: (3 ; une parenthèse seule est une liaison d'expression
+			if i+1 < len(line) && line[i+1] >= '2' && line[i+1] <= '9' {
+				count := int(line[i+1] - '0')
+				p.tupletLeft = count
+				p.tupletRatio = abcTupletRatio(count)
+				i += 2
+				// Forme étendue (p:q:r) : on ignore q et r
+				for i < len(line) && (line[i] == ':' || line[i] >= '0' && line[i] <= '9') {
+					i++
+				}
+			} else {
+				i++
+			}
+
+		case ch == '-':
+			// Liaison de prolongation avec la note suivante
+			if len(p.current.Notes) > 0 {
+				p.current.Notes[len(p.current.Notes)-1].TieStart = true
+			} else if len(p.measures) > 0 {
+				last := &p.measures[len(p.measures)-1]
+				last.Notes[len(last.Notes)-1].TieStart = true
+			}
+			i++
+
+		case ch == '>' || ch == '<':
+			// Rythme pointé : la note précédente est allongée, la suivante raccourcie (ou l'inverse)
+			count := 0
+			for i < len(line) && line[i] == ch {
+				count++
+				i++
+			}
+			short := math.Pow(0.5, float64(count))
+			long := 2 - short
+			if ch == '<' {
+				short, long = long, short
+			}
+			if len(p.current.Notes) > 0 {
+				last := &p.current.Notes[len(p.current.Notes)-1]
+				last.Duration = int(math.Round(float64(last.Duration) * long))
+			}
+			p.brokenFactor = short
+
+		case ch == 'z' || ch == 'x':
+			multiplier, n := readABCLength(line[i+1:])
+			p.addNote(nil, p.unit*multiplier)
+			i += 1 + n
+
+		case ch == 'Z' || ch == 'X':
+			// Silence de plusieurs mesures
+			count, n := readABCLength(line[i+1:])
+			if count > maxABCRestMeasures {
+				return fmt.Errorf("silence de %.0f mesures (%d au maximum)", count, maxABCRestMeasures)
+			}
+			if p.noteCount+int(count) > maxABCNotes {
+				return fmt.Errorf("morceau trop long (%d notes au maximum)", maxABCNotes)
+			}
+			measureLength := abcMeterValue(p.meter)
+			if measureLength == 0 {
+				measureLength = 1
+			}
+			for k := 0; k < int(math.Max(count, 1)); k++ {
+				p.addNote(nil, measureLength)
+				p.closeMeasure()
+			}
+			i += 1 + n
+
+		default:
+			pitch, length, n, ok := p.readNote(line[i:])
+			if !ok {
+				// Espaces, décorations courtes (~ . H L M O P S T u v), etc.
+				i++
+				continue
+			}
+			p.addNote([]int{pitch}, length)
+			i += n
+		}
+	}
+	return nil
+}
+
+// readNote lit une note ABC (altération, nom, octave, longueur) au début de s
+func (p *abcParser) readNote(s string) (int, float64, int, bool) {
+	i := 0
+	alter, explicit := 0, false
+	for i < len(s) && (s[i] == '^' || s[i] == '_' || s[i] == '=') {
+		explicit = true
+		switch s[i] {
+		case '^':
+			alter++
+		case '_':
+			alter--
+		case '=':
+			alter = 0
+		}
+		i++
+	}
+	if i >= len(s) {
+		return 0, 0, 0, false
+	}
+
+	letter := s[i]
+	octave := 0
+	switch {
+	case letter >= 'A' && letter <= 'G':
+	case letter >= 'a' && letter <= 'g':
+		letter -= 'a' - 'A'
+		octave = 1
+	default:
+		return 0, 0, 0, false
+	}
+	i++
+	for i < len(s) && (s[i] == '\'' || s[i] == ',') {
+		if s[i] == '\'' {
+			octave++
+		} else {
+			octave--
+		}
+		i++
+	}
+
+	// Une altération accidentelle vaut jusqu'à la fin de la mesure
+	barKey := fmt.Sprintf("%c%d", letter, octave)
+	if explicit {
+		if p.barAlter == nil {
+			p.barAlter = map[string]int{}
+		}
+		p.barAlter[barKey] = alter
+	} else if barAlter, ok := p.barAlter[barKey]; ok {
+		alter = barAlter
+	} else {
+		alter = p.keyAlter[letter]
+	}
+
+	steps := map[byte]int{'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11}
+	pitch := 60 + steps[letter] + alter + 12*octave
+
+	multiplier, n := readABCLength(s[i:])
+	return pitch, p.unit * multiplier, i + n, true
+}
+
+// readABCLength lit un multiplicateur de longueur ABC ("2", "/", "3/2", "//")
+func readABCLength(s string) (float64, int) {
+	i := 0
+	numerator, denominator := 1.0, 1.0
+
+	start := i
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i > start {
+		numerator, _ = strconv.ParseFloat(s[start:i], 64)
+	}
+
+	for i < len(s) && s[i] == '/' {
+		i++
+		start = i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i > start {
+			value, _ := strconv.ParseFloat(s[start:i], 64)
+			if value > 0 {
+				denominator *= value
+			}
+		} else {
+			denominator *= 2
+		}
+	}
+
+	return numerator / denominator, i
+}
+
+// abcTupletRatio retourne le facteur de durée d'un n-olet (p notes dans le temps de q)
+func abcTupletRatio(count int) float64 {
+	switch count {
+	case 2:
+		return 3.0 / 2
+	case 3, 6:
+		return 2.0 / float64(count)
+	case 4, 8:
+		return 3.0 / float64(count)
+	default:
+		return 2.0 / float64(count)
+	}
+}
+
+// parseABCFraction lit une fraction ("1/8") et retourne sa valeur
+func parseABCFraction(value string) float64 {
+	parts := strings.SplitN(strings.TrimSpace(value), "/", 2)
+	if len(parts) != 2 {
+		return 0
+	}
+	numerator, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	denominator, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || denominator == 0 {
+		return 0
+	}
+	return numerator / denominator
+}
+
+// abcMeterSignature normalise un champ M: ("C" devient "4/4", "C|" devient "2/2")
+func abcMeterSignature(meter string) string {
+	meter = strings.TrimSpace(meter)
+	switch meter {
+	case "C":
+		return "4/4"
+	case "C|":
+		return "2/2"
+	case "", "none":
+		return ""
+	}
+	if parseABCFraction(meter) == 0 {
+		return ""
+	}
+	return strings.ReplaceAll(meter, " ", "")
+}
+
+// abcMeterValue retourne la durée d'une mesure en fraction de ronde
+func abcMeterValue(meter string) float64 {
+	return parseABCFraction(abcMeterSignature(meter))
+}
+
+// abcQuarterTempo convertit un champ Q: en battements par minute à la noire
+func abcQuarterTempo(tempo string, unit float64) float64 {
+	// On retire les éventuels textes entre guillemets ("Allegro")
+	for strings.Contains(tempo, "\"") {
+		start := strings.Index(tempo, "\"")
+		end := strings.Index(tempo[start+1:], "\"")
+		if end < 0 {
+			tempo = tempo[:start]
+			break
+		}
+		tempo = tempo[:start] + tempo[start+end+2:]
+	}
+	tempo = strings.TrimSpace(tempo)
+	if tempo == "" {
+		return 0
+	}
+
+	beat := unit
+	bpmText := tempo
+	if parts := strings.SplitN(tempo, "=", 2); len(parts) == 2 {
+		// Plusieurs unités peuvent être additionnées : "1/4 1/8=60"
+		beat = 0
+		for _, fraction := range strings.Fields(parts[0]) {
+			beat += parseABCFraction(fraction)
+		}
+		bpmText = strings.TrimSpace(parts[1])
+	}
+	bpm, err := strconv.ParseFloat(bpmText, 64)
+	if err != nil || beat == 0 {
+		return 0
+	}
+	return bpm * beat * 4
+}
+
+// abcKeyFifths convertit un champ K: en nombre de quintes et mode ("major", "minor"...)
+func abcKeyFifths(key string) (int, string) {
+	key = strings.TrimSpace(key)
+	if key == "" || strings.EqualFold(key, "none") || strings.HasPrefix(key, "H") {
+		return 0, "major"
+	}
+
+	letterFifths := map[byte]int{'F': -1, 'C': 0, 'G': 1, 'D': 2, 'A': 3, 'E': 4, 'B': 5}
+	fifths, ok := letterFifths[key[0]]
+	if !ok {
+		return 0, "major"
+	}
+	rest := key[1:]
+	if strings.HasPrefix(rest, "#") {
+		fifths += 7
+		rest = rest[1:]
+	} else if strings.HasPrefix(rest, "b") {
+		fifths -= 7
+		rest = rest[1:]
+	}
+
+	mode := "major"
+	fields := strings.Fields(strings.ToLower(rest))
+	if len(fields) > 0 {
+		modeOffsets := []struct {
+			prefix string
+			name   string
+			offset int
+		}{
+			{"maj", "major", 0}, {"ion", "ionian", 0}, {"min", "minor", -3}, {"m", "minor", -3},
+			{"aeo", "aeolian", -3}, {"mix", "mixolydian", -1}, {"dor", "dorian", -2},
+			{"phr", "phrygian", -4}, {"lyd", "lydian", 1}, {"loc", "locrian", -5},
+		}
+		for _, m := range modeOffsets {
+			if strings.HasPrefix(fields[0], m.prefix) {
+				mode = m.name
+				fifths += m.offset
+				break
+			}
+		}
+	}
+
+	return fifths, mode
+}
+
+// abcKeyName retourne un nom de tonalité lisible, au même format que pour le MIDI ("D major")
+func abcKeyName(key string) string {
+	key = strings.TrimSpace(key)
+	if key == "" || strings.EqualFold(key, "none") || strings.HasPrefix(key, "H") {
+		return ""
+	}
+	_, mode := abcKeyFifths(key)
+	tonic := key[:1]
+	if len(key) > 1 && (key[1] == '#' || key[1] == 'b') {
+		tonic = key[:2]
+	}
+	return tonic + " " + mode
+}
+
+// Lyrics extrait les paroles d'un morceau : les lignes w: alignées sur la musique
+// (la n-ième ligne w: suivant une ligne de musique appartient au n-ième couplet)
+// puis les couplets W: placés en fin de morceau
+func (t ABCTune) Lyrics() []models.PartitionLyric {
+	type lyricKey struct {
+		voice string
+		verse int
+	}
+	verses := map[lyricKey][]string{}
+	var order []lyricKey
+	addLine := func(key lyricKey, text string) {
+		if _, ok := verses[key]; !ok {
+			order = append(order, key)
+		}
+		verses[key] = append(verses[key], text)
+	}
+
+	voice := ""
+	wordsCount := 0
+	var trailing [][]string
+	var block []string
+	for _, line := range t.Lines {
+		trimmed := strings.TrimSpace(line)
+		field, value := abcField(trimmed)
+		switch {
+		case field == "V":
+			voice = abcVoiceName(value)
+		case field == "w":
+			wordsCount++
+			if text := abcLyricText(value); text != "" {
+				addLine(lyricKey{voice: voice, verse: wordsCount}, text)
+			}
+		case field == "W":
+			// Une ligne W: vide sépare deux couplets
+			if value == "" {
+				if len(block) > 0 {
+					trailing = append(trailing, block)
+				}
+				block = nil
+			} else {
+				block = append(block, value)
+			}
+		case isABCBodyLine(line):
+			if strings.HasPrefix(trimmed, "[V:") {
+				if end := strings.Index(trimmed, "]"); end > 0 {
+					voice = abcVoiceName(trimmed[3:end])
+				}
+			}
+			wordsCount = 0
+		}
+	}
+	if len(block) > 0 {
+		trailing = append(trailing, block)
+	}
+
+	var lyrics []models.PartitionLyric
+	maxVerse := map[string]int{}
+	for _, key := range order {
+		lyrics = append(lyrics, models.PartitionLyric{
+			Voice: key.voice,
+			Verse: key.verse,
+			Text:  strings.Join(verses[key], "\n"),
+		})
+		if key.verse > maxVerse[key.voice] {
+			maxVerse[key.voice] = key.verse
+		}
+	}
+	// Les couplets W: suivent les couplets alignés de la première voix
+	firstVoice := ""
+	if len(order) > 0 {
+		firstVoice = order[0].voice
+	}
+	for i, lines := range trailing {
+		lyrics = append(lyrics, models.PartitionLyric{
+			Voice: firstVoice,
+			Verse: maxVerse[firstVoice] + i + 1,
+			Text:  strings.Join(lines, "\n"),
+		})
+	}
+	return lyrics
+}
+
+// abcVoiceName retourne le nom d'une voix ABC ("V:S name=\"Soprano\"" donne "Soprano")
+func abcVoiceName(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	for _, attribute := range []string{"name=", "nm="} {
+		if i := strings.Index(value, attribute); i >= 0 {
+			name := strings.TrimSpace(value[i+len(attribute):])
+			if strings.HasPrefix(name, "\"") {
+				if end := strings.Index(name[1:], "\""); end >= 0 {
+					return name[1 : end+1]
+				}
+			}
+			if nameFields := strings.Fields(name); len(nameFields) > 0 {
+				return nameFields[0]
+			}
+		}
+	}
+	return fields[0]
+}
+
+// abcLyricText convertit une ligne w: en texte lisible en réunissant les syllabes
+func abcLyricText(value string) string {
+	var text []byte
+	for i := 0; i < len(value); i++ {
+		switch ch := value[i]; ch {
+		case '\\':
+			// Caractère échappé, ex: \- pour un tiret littéral
+			if i+1 < len(value) {
+				text = append(text, value[i+1])
+				i++
+			}
+		case '-':
+			// Séparation de syllabes : on supprime les espaces qui l'entourent
+			text = []byte(strings.TrimRight(string(text), " "))
+			for i+1 < len(value) && value[i+1] == ' ' {
+				i++
+			}
+		case '_', '*', '|':
+		case '~':
+			text = append(text, ' ')
+		default:
+			text = append(text, ch)
+		}
+	}
+	return strings.Join(strings.Fields(string(text)), " ")
+}
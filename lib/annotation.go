@@ -0,0 +1,153 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"regexp"
+	"slices"
+	"solfa-back/models"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Nombre maximal d'annotations d'un utilisateur sur une partition
+const maxAnnotationMarks = 2000
+
+// Longueur maximale du texte d'une note
+const maxAnnotationTextLength = 500
+
+// Largeur des notes imprimées sur le PDF, en points
+const annotationNoteWidth = 160.0
+
+// ErrInvalidAnnotation est renvoyée pour une annotation incomplète ou hors de la page
+var ErrInvalidAnnotation = errors.New("annotation invalide")
+
+// AnnotationKinds liste les types d'annotation
+var AnnotationKinds = []string{models.AnnotationNote, models.AnnotationBreath, models.AnnotationHighlight}
+
+// Couleurs par défaut des annotations imprimées
+var annotationDefaultColors = map[string]string{
+	models.AnnotationNote:      "#fff3a0",
+	models.AnnotationBreath:    "#d32f2f",
+	models.AnnotationHighlight: "#ffeb3b",
+}
+
+var annotationColorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// ValidateAnnotationMarks vérifie des annotations (type connu, position dans la page,
+// texte des notes, dimensions des surlignages) et normalise leur texte et leur couleur
+func ValidateAnnotationMarks(marks []models.AnnotationMark) error {
+	if len(marks) > maxAnnotationMarks {
+		return fmt.Errorf("%w: %d annotations au maximum", ErrInvalidAnnotation, maxAnnotationMarks)
+	}
+
+	for i := range marks {
+		mark := &marks[i]
+		mark.Text = strings.TrimSpace(mark.Text)
+		mark.Color = strings.ToLower(strings.TrimSpace(mark.Color))
+
+		var problem string
+		switch {
+		case !slices.Contains(AnnotationKinds, mark.Kind):
+			problem = fmt.Sprintf("type %q (note, breath ou highlight)", mark.Kind)
+		case mark.Page < 0 || mark.Measure < 0:
+			problem = "page ou mesure négative"
+		case mark.Page == 0 && mark.Measure == 0:
+			problem = "une page ou une mesure est requise"
+		case mark.X < 0 || mark.X > 1 || mark.Y < 0 || mark.Y > 1:
+			problem = "les coordonnées doivent être comprises entre 0 et 1"
+		case mark.Kind == models.AnnotationHighlight &&
+			(mark.Width <= 0 || mark.Height <= 0 || mark.X+mark.Width > 1 || mark.Y+mark.Height > 1):
+			problem = "le surlignage doit avoir une largeur et une hauteur et rester dans la page"
+		case mark.Kind == models.AnnotationNote && mark.Text == "":
+			problem = "le texte de la note est requis"
+		case utf8.RuneCountInString(mark.Text) > maxAnnotationTextLength:
+			problem = fmt.Sprintf("texte limité à %d caractères", maxAnnotationTextLength)
+		case mark.Color != "" && !annotationColorPattern.MatchString(mark.Color):
+			problem = fmt.Sprintf("couleur %q (format #rrggbb)", mark.Color)
+		}
+		if problem != "" {
+			return fmt.Errorf("%w: annotation %d : %s", ErrInvalidAnnotation, i+1, problem)
+		}
+	}
+	return nil
+}
+
+// AnnotatePDF imprime des annotations sur les pages d'un PDF, avec le filigrane donné en pied
+// de page ; les annotations rattachées à une mesure seulement ou à une page absente sont ignorées
+func AnnotatePDF(content []byte, marks []models.AnnotationMark, watermark string) ([]byte, error) {
+	marksByPage := make(map[int][]models.AnnotationMark)
+	for _, mark := range marks {
+		if mark.Page > 0 {
+			marksByPage[mark.Page] = append(marksByPage[mark.Page], mark)
+		}
+	}
+
+	return OverlayPDF(content, func(overlay *gofpdf.Fpdf, page int, dimension PageDimension) {
+		// Les surlignages sont imprimés d'abord, pour ne pas recouvrir les notes
+		pageMarks := marksByPage[page]
+		slices.SortStableFunc(pageMarks, func(a, b models.AnnotationMark) int {
+			return annotationLayer(a) - annotationLayer(b)
+		})
+		for _, mark := range pageMarks {
+			drawAnnotation(overlay, dimension, mark)
+		}
+		drawPageStamp(overlay, dimension, PageStamp{Watermark: watermark})
+	})
+}
+
+// annotationLayer ordonne l'impression des annotations : surlignages, respirations puis notes
+func annotationLayer(mark models.AnnotationMark) int {
+	switch mark.Kind {
+	case models.AnnotationHighlight:
+		return 0
+	case models.AnnotationBreath:
+		return 1
+	}
+	return 2
+}
+
+// drawAnnotation imprime une annotation sur une page de surimpression
+func drawAnnotation(overlay *gofpdf.Fpdf, page PageDimension, mark models.AnnotationMark) {
+	color := mark.Color
+	if color == "" {
+		color = annotationDefaultColors[mark.Kind]
+	}
+	r, g, b := hexColor(color)
+	x, y := mark.X*page.Width, mark.Y*page.Height
+
+	switch mark.Kind {
+	case models.AnnotationHighlight:
+		overlay.SetAlpha(0.35, "Multiply")
+		overlay.SetFillColor(r, g, b)
+		overlay.Rect(x, y, mark.Width*page.Width, mark.Height*page.Height, "F")
+		overlay.SetAlpha(1, "Normal")
+	case models.AnnotationBreath:
+		// Virgule de respiration, placée au-dessus de la portée
+		overlay.SetFont("Helvetica", "B", 20)
+		overlay.SetTextColor(r, g, b)
+		overlay.Text(x, y, ",")
+	case models.AnnotationNote:
+		if x+annotationNoteWidth > page.Width-6 {
+			x = max(6, page.Width-6-annotationNoteWidth)
+		}
+		overlay.SetFont("Helvetica", "", 8)
+		overlay.SetTextColor(30, 30, 30)
+		overlay.SetFillColor(r, g, b)
+		overlay.SetAlpha(0.9, "Normal")
+		overlay.SetXY(x, y)
+		overlay.MultiCell(annotationNoteWidth, 10, overlay.UnicodeTranslatorFromDescriptor("")(mark.Text), "", "L", true)
+		overlay.SetAlpha(1, "Normal")
+	}
+}
+
+// hexColor décompose une couleur "#rrggbb"
+func hexColor(color string) (int, int, int) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+	if err != nil {
+		return 0, 0, 0
+	}
+	return int(value >> 16 & 0xff), int(value >> 8 & 0xff), int(value & 0xff)
+}
@@ -0,0 +1,189 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/minio/minio-go/v7"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+// Durée de conservation des livrets générés dans Minio
+const BookletRetention = 24 * time.Hour
+
+// Préfixe des livrets temporaires dans le bucket "solfa"
+const bookletPrefix = "booklets/"
+
+// Nombre d'entrées par page de la table des matières
+const bookletTOCEntriesPerPage = 34
+
+// BookletEntry est une partition PDF à insérer dans un livret
+type BookletEntry struct {
+	Title    string
+	Composer string
+	Content  []byte
+}
+
+// BookletPath retourne l'emplacement temporaire d'un livret dans Minio
+func BookletPath(name string) string {
+	return bookletPrefix + name
+}
+
+// BuildBooklet assemble les partitions dans l'ordre donné, précédées d'une page de
+// garde et d'une table des matières ; toutes les pages sauf la page de garde sont
+// numérotées et portent le filigrane
+func BuildBooklet(title, subtitle string, entries []BookletEntry, watermark string) ([]byte, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("le livret ne contient aucune partition")
+	}
+
+	// Pages de chaque partition, pour calculer la table des matières
+	pageCounts := make([]int, len(entries))
+	for i, entry := range entries {
+		dimensions, err := PDFPageDimensions(entry.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Title, err)
+		}
+		if len(dimensions) == 0 {
+			return nil, fmt.Errorf("%s: le PDF ne contient aucune page", entry.Title)
+		}
+		pageCounts[i] = len(dimensions)
+	}
+
+	tocPages := (len(entries) + bookletTOCEntriesPerPage - 1) / bookletTOCEntriesPerPage
+	startPages := make([]int, len(entries))
+	next := 1 + tocPages + 1
+	for i, count := range pageCounts {
+		startPages[i] = next
+		next += count
+	}
+
+	front, err := bookletFrontMatter(title, subtitle, entries, startPages)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{"front.pdf": front}
+	args := []string{"--empty", "--pages", "front.pdf"}
+	for i, entry := range entries {
+		name := fmt.Sprintf("entry_%d.pdf", i)
+		files[name] = entry.Content
+		args = append(args, name)
+	}
+	args = append(args, "--")
+
+	merged, err := RunQPDF(files, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return StampPDF(merged, func(page int) PageStamp {
+		if page == 1 {
+			return PageStamp{Watermark: watermark}
+		}
+		return PageStamp{PageNumber: strconv.Itoa(page), Watermark: watermark}
+	})
+}
+
+// bookletFrontMatter génère la page de garde et la table des matières (format A4)
+func bookletFrontMatter(title, subtitle string, entries []BookletEntry, startPages []int) ([]byte, error) {
+	doc := gofpdf.New("P", "pt", "A4", "")
+	doc.SetAutoPageBreak(false, 0)
+	translate := doc.UnicodeTranslatorFromDescriptor("")
+	width, height := doc.GetPageSize()
+
+	// Page de garde
+	doc.AddPage()
+	doc.SetFont("Helvetica", "B", 28)
+	doc.SetXY(50, height/3)
+	doc.MultiCell(width-100, 34, translate(title), "", "C", false)
+	if subtitle != "" {
+		doc.SetFont("Helvetica", "", 16)
+		doc.SetX(50)
+		doc.MultiCell(width-100, 22, translate(subtitle), "", "C", false)
+	}
+	doc.SetFont("Helvetica", "", 11)
+	doc.SetTextColor(90, 90, 90)
+	doc.SetXY(50, height-120)
+	doc.CellFormat(width-100, 14, translate(fmt.Sprintf("%d partitions - %s", len(entries), time.Now().Format("02/01/2006"))), "", 0, "C", false, 0, "")
+	doc.SetTextColor(0, 0, 0)
+
+	// Table des matières, avec points de conduite jusqu'au numéro de page
+	for i, entry := range entries {
+		if i%bookletTOCEntriesPerPage == 0 {
+			doc.AddPage()
+			doc.SetFont("Helvetica", "B", 18)
+			doc.SetXY(50, 50)
+			doc.CellFormat(width-100, 24, translate("Table des matières"), "", 0, "L", false, 0, "")
+			doc.SetY(95)
+		}
+
+		label := entry.Title
+		if entry.Composer != "" {
+			label += " - " + entry.Composer
+		}
+		number := strconv.Itoa(startPages[i])
+
+		doc.SetFont("Helvetica", "", 11)
+		// Tronquer les titres trop longs pour laisser la place au numéro de page
+		runes := []rune(label)
+		label = translate(label)
+		for len(runes) > 1 && doc.GetStringWidth(label) > width-180 {
+			runes = runes[:len(runes)-1]
+			label = translate(string(runes) + "...")
+		}
+		y := doc.GetY()
+		labelWidth := doc.GetStringWidth(label)
+		numberWidth := doc.GetStringWidth(number)
+
+		doc.SetXY(50, y)
+		doc.CellFormat(labelWidth, 20, label, "", 0, "L", false, 0, "")
+		doc.SetXY(width-50-numberWidth, y)
+		doc.CellFormat(numberWidth, 20, number, "", 0, "R", false, 0, "")
+		doc.SetDashPattern([]float64{1, 3}, 0)
+		doc.Line(50+labelWidth+6, y+14, width-50-numberWidth-6, y+14)
+		doc.SetDashPattern([]float64{}, 0)
+		doc.SetY(y + 20)
+	}
+
+	var content bytes.Buffer
+	if err := doc.Output(&content); err != nil {
+		return nil, fmt.Errorf("génération de la table des matières: %v", err)
+	}
+	return content.Bytes(), nil
+}
+
+// StartBookletCleanup supprime régulièrement les livrets plus anciens que BookletRetention
+func StartBookletCleanup() {
+	go func() {
+		for {
+			cleanupBooklets()
+			time.Sleep(time.Hour)
+		}
+	}()
+}
+
+// cleanupBooklets supprime les livrets expirés du bucket "solfa"
+func cleanupBooklets() {
+	if MinioClient == nil {
+		return
+	}
+
+	ctx := context.Background()
+	for object := range MinioClient.ListObjects(ctx, "solfa", minio.ListObjectsOptions{Prefix: bookletPrefix}) {
+		if object.Err != nil {
+			logrus.WithField("error", object.Err.Error()).Error("Erreur lors du listage des livrets")
+			return
+		}
+		if time.Since(object.LastModified) < BookletRetention {
+			continue
+		}
+		if err := MinioClient.RemoveObject(ctx, "solfa", object.Key, minio.RemoveObjectOptions{}); err != nil {
+			logrus.WithFields(logrus.Fields{"object": object.Key, "error": err.Error()}).Error("Erreur lors de la suppression d'un livret")
+		}
+	}
+}
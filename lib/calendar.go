@@ -0,0 +1,130 @@
+package lib
+
+import (
+	"fmt"
+	"solfa-back/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Durée supposée d'un événement sans heure de fin
+const defaultEventDuration = 2 * time.Hour
+
+// Ancienneté des événements passés conservés dans les flux iCalendar
+const CalendarHistory = 90 * 24 * time.Hour
+
+// Format des dates UTC d'un fichier iCalendar
+const icsTimeFormat = "20060102T150405Z"
+
+// eventKindLabels nomme les types d'événement dans les calendriers et les rappels
+var eventKindLabels = map[string]string{
+	models.EventRehearsal: "Répétition",
+	models.EventConcert:   "Concert",
+	models.EventService:   "Office",
+}
+
+// icsEscaper échappe les caractères spéciaux d'une valeur texte iCalendar (RFC 5545, 3.3.11)
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// EventEnd retourne la fin d'un événement, ou sa fin supposée s'il n'en a pas
+func EventEnd(event models.Event) time.Time {
+	if event.EndsAt != nil {
+		return *event.EndsAt
+	}
+	return event.StartsAt.Add(defaultEventDuration)
+}
+
+// EventProgramme retourne les lignes du programme d'un événement ("1. Titre (compositeur) - indications") ;
+// les éléments doivent être chargés dans l'ordre, avec leur partition
+func EventProgramme(event models.Event) []string {
+	lines := make([]string, 0, len(event.Items))
+	for _, item := range event.Items {
+		if item.Partition == nil {
+			continue
+		}
+		line := fmt.Sprintf("%d. %s", item.Position, item.Partition.Title)
+		if item.Partition.Composer != "" {
+			line += fmt.Sprintf(" (%s)", item.Partition.Composer)
+		}
+		if item.Notes != "" {
+			line += " - " + item.Notes
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// eventDescription rédige la description d'un événement : organisation, notes et programme
+func eventDescription(event models.Event, organization string) string {
+	var parts []string
+	if organization != "" {
+		parts = append(parts, organization)
+	}
+	if event.Notes != "" {
+		parts = append(parts, event.Notes)
+	}
+	if programme := EventProgramme(event); len(programme) > 0 {
+		parts = append(parts, "Programme :\n"+strings.Join(programme, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// BuildCalendar génère un flux iCalendar (.ics) à partir d'événements chargés avec leur programme ;
+// organizationNames, facultatif, ajoute le nom de l'organisation à la description de chaque événement
+func BuildCalendar(name string, events []models.Event, organizationNames map[uint]string) []byte {
+	var calendar strings.Builder
+	writeICSLine(&calendar, "BEGIN:VCALENDAR")
+	writeICSLine(&calendar, "VERSION:2.0")
+	writeICSLine(&calendar, "PRODID:-//Solfa//Calendrier//FR")
+	writeICSLine(&calendar, "CALSCALE:GREGORIAN")
+	writeICSLine(&calendar, "METHOD:PUBLISH")
+	writeICSLine(&calendar, "X-WR-CALNAME:"+icsEscaper.Replace(name))
+
+	for _, event := range events {
+		writeICSLine(&calendar, "BEGIN:VEVENT")
+		writeICSLine(&calendar, fmt.Sprintf("UID:event-%d@solfa", event.ID))
+		writeICSLine(&calendar, "DTSTAMP:"+event.UpdatedAt.UTC().Format(icsTimeFormat))
+		writeICSLine(&calendar, "DTSTART:"+event.StartsAt.UTC().Format(icsTimeFormat))
+		writeICSLine(&calendar, "DTEND:"+EventEnd(event).UTC().Format(icsTimeFormat))
+		writeICSLine(&calendar, "SUMMARY:"+icsEscaper.Replace(event.Title))
+		if label := eventKindLabels[event.Kind]; label != "" {
+			writeICSLine(&calendar, "CATEGORIES:"+icsEscaper.Replace(label))
+		}
+		if event.Location != "" {
+			writeICSLine(&calendar, "LOCATION:"+icsEscaper.Replace(event.Location))
+		}
+		if description := eventDescription(event, organizationNames[event.OrganizationID]); description != "" {
+			writeICSLine(&calendar, "DESCRIPTION:"+icsEscaper.Replace(description))
+		}
+		writeICSLine(&calendar, fmt.Sprintf("URL:%s/events/%d", AppURL(), event.ID))
+		if event.ReminderHours > 0 {
+			writeICSLine(&calendar, "BEGIN:VALARM")
+			writeICSLine(&calendar, "ACTION:DISPLAY")
+			writeICSLine(&calendar, "DESCRIPTION:"+icsEscaper.Replace(event.Title))
+			writeICSLine(&calendar, fmt.Sprintf("TRIGGER:-PT%dH", event.ReminderHours))
+			writeICSLine(&calendar, "END:VALARM")
+		}
+		writeICSLine(&calendar, "END:VEVENT")
+	}
+
+	writeICSLine(&calendar, "END:VCALENDAR")
+	return []byte(calendar.String())
+}
+
+// writeICSLine écrit une ligne iCalendar, repliée à 75 octets sans couper de caractère UTF-8
+func writeICSLine(calendar *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		calendar.WriteString(line[:cut])
+		calendar.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // L'espace de continuation compte dans la longueur de la ligne
+	}
+	calendar.WriteString(line)
+	calendar.WriteString("\r\n")
+}
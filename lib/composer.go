@@ -0,0 +1,193 @@
+package lib
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"slices"
+	"solfa-back/models"
+	"strings"
+)
+
+// NormalizeComposerName réduit un nom de compositeur à une forme comparable :
+// minuscules, sans accents ni ponctuation, et "Nom, Prénom" remis dans l'ordre "Prénom Nom"
+func NormalizeComposerName(name string) string {
+	if last, first, found := strings.Cut(name, ","); found {
+		name = first + " " + last
+	}
+	return NormalizeText(name)
+}
+
+// CanonicalComposerName met en forme un nom saisi pour en faire un nom canonique
+// ("Bach, Johann Sebastian" devient "Johann Sebastian Bach")
+func CanonicalComposerName(name string) string {
+	if last, first, found := strings.Cut(name, ","); found && strings.TrimSpace(first) != "" {
+		name = first + " " + last
+	}
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// composerInitialsMatch indique si un nom abrégé ("j s bach") désigne le nom complet
+// ("johann sebastian bach") : même nom de famille, et prénoms ou initiales compatibles
+func composerInitialsMatch(short, full string) bool {
+	shortParts := strings.Fields(short)
+	fullParts := strings.Fields(full)
+	// Un nom de famille seul est ambigu ("Bach") : il doit être enregistré comme alias
+	if len(shortParts) < 2 || len(shortParts) > len(fullParts) {
+		return false
+	}
+	if shortParts[len(shortParts)-1] != fullParts[len(fullParts)-1] {
+		return false
+	}
+
+	givenNames := fullParts[:len(fullParts)-1]
+	for i, part := range shortParts[:len(shortParts)-1] {
+		if i >= len(givenNames) || !strings.HasPrefix(givenNames[i], part) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetComposerSearchNames recopie le nom canonique et les alias normalisés d'un compositeur
+// dans SearchNames, sur lequel ResolveComposer fait ses recherches ; à appeler avant chaque
+// enregistrement d'un compositeur
+func SetComposerSearchNames(composer *models.Composer) {
+	names := []string{NormalizeComposerName(composer.Name)}
+	for _, alias := range composer.Aliases {
+		if normalized := NormalizeComposerName(alias); normalized != "" && !slices.Contains(names, normalized) {
+			names = append(names, normalized)
+		}
+	}
+	composer.SearchNames = "|" + strings.Join(names, "|") + "|"
+}
+
+// ResolveComposer recherche le compositeur désigné par un nom libre : nom canonique ou
+// alias identique (sans tenir compte des accents, de la casse ni de l'ordre "Nom, Prénom"),
+// puis initiales compatibles avec un seul compositeur. Retourne nil si aucun ne correspond.
+func ResolveComposer(name string) (*models.Composer, error) {
+	// Les noms normalisés ne contiennent que des lettres, des chiffres et des espaces :
+	// ils peuvent être placés tels quels dans un motif LIKE
+	normalized := NormalizeComposerName(name)
+	if normalized == "" {
+		return nil, nil
+	}
+
+	var composer models.Composer
+	err := DB.Where("search_names LIKE ?", "%|"+normalized+"|%").First(&composer).Error
+	if err == nil {
+		return &composer, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Initiales : seuls les compositeurs portant le même nom de famille sont comparés
+	parts := strings.Fields(normalized)
+	if len(parts) < 2 {
+		return nil, nil
+	}
+	var candidates []models.Composer
+	if err := DB.Where("search_names LIKE ?", "|% "+parts[len(parts)-1]+"|%").Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	var candidate *models.Composer
+	for i, composer := range candidates {
+		if composerInitialsMatch(normalized, NormalizeComposerName(composer.Name)) {
+			if candidate != nil {
+				return nil, nil // Plusieurs compositeurs possibles (ex: "J. Strauss")
+			}
+			candidate = &candidates[i]
+		}
+	}
+	return candidate, nil
+}
+
+// LinkPartitionComposers rattache à un compositeur connu les partitions qui n'en ont pas
+// encore : partitions enregistrées avant l'introduction des compositeurs, ou dont le nom de
+// compositeur n'était pas reconnu. Les noms inconnus ne créent pas de compositeur : les
+// modérateurs les ajoutent, ou complètent les alias d'un compositeur existant.
+func LinkPartitionComposers() error {
+	if err := fillComposerSearchNames(); err != nil {
+		return err
+	}
+
+	var partitions []models.Partition
+	if err := DB.Select("id", "composer").
+		Where("composer_id IS NULL AND composer <> ''").
+		Find(&partitions).Error; err != nil {
+		return err
+	}
+
+	for _, partition := range partitions {
+		composer, err := ResolveComposer(partition.Composer)
+		if err != nil {
+			return err
+		}
+		if composer == nil {
+			continue
+		}
+		if err := linkPartitionComposer(partition.ID, composer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillComposerSearchNames renseigne SearchNames pour les compositeurs enregistrés avant son introduction
+func fillComposerSearchNames() error {
+	var composers []models.Composer
+	if err := DB.Where("search_names IS NULL OR search_names = ''").Find(&composers).Error; err != nil {
+		return err
+	}
+	for i := range composers {
+		SetComposerSearchNames(&composers[i])
+		if err := DB.Model(&composers[i]).UpdateColumn("search_names", composers[i].SearchNames).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkPartitionComposer rattache une partition à un compositeur, en base et dans Elasticsearch
+func linkPartitionComposer(partitionID uint, composer *models.Composer) error {
+	fields := map[string]interface{}{"composer_id": composer.ID, "composer": composer.Name}
+	if err := DB.Model(&models.Partition{ID: partitionID}).UpdateColumns(fields).Error; err != nil {
+		return err
+	}
+	return UpdatePartitionFieldsInES(partitionID, fields)
+}
+
+// RenameComposerPartitions reporte le nom canonique d'un compositeur sur ses partitions
+func RenameComposerPartitions(composer models.Composer) error {
+	if err := DB.Model(&models.Partition{}).
+		Where("composer_id = ?", composer.ID).
+		UpdateColumn("composer", composer.Name).Error; err != nil {
+		return err
+	}
+	return updatePartitionsInES(map[string]interface{}{"composer_id": composer.ID}, map[string]interface{}{"composer": composer.Name})
+}
+
+// MoveComposerPartitions rattache les partitions d'un compositeur (fusionné) à un autre
+func MoveComposerPartitions(fromID uint, into models.Composer) error {
+	fields := map[string]interface{}{"composer_id": into.ID, "composer": into.Name}
+	if err := DB.Model(&models.Partition{}).Where("composer_id = ?", fromID).UpdateColumns(fields).Error; err != nil {
+		return err
+	}
+	return updatePartitionsInES(map[string]interface{}{"composer_id": fromID}, fields)
+}
+
+// ErrComposerExists est renvoyée lorsqu'un nom canonique est déjà utilisé
+var ErrComposerExists = errors.New("un compositeur porte déjà ce nom")
+
+// CheckComposerName vérifie qu'aucun autre compositeur ne porte déjà ce nom
+func CheckComposerName(name string, exceptID uint) error {
+	var existing models.Composer
+	err := DB.Where("LOWER(name) = LOWER(?) AND id <> ?", name, exceptID).First(&existing).Error
+	if err == nil {
+		return ErrComposerExists
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
@@ -25,9 +25,39 @@ func InitDB() {
 		log.Fatalf("Erreur de connexion à la base de données : %v", err)
 	}
 
+	// Les jetons de calendrier vides deviennent NULL avant la création de leur index unique
+	if db.Migrator().HasIndex(&models.User{}, "idx_users_calendar_token") {
+		db.Exec("UPDATE users SET calendar_token = NULL WHERE calendar_token = ''")
+		db.Migrator().DropIndex(&models.User{}, "idx_users_calendar_token")
+	}
+
 	// Auto-migration
 	db.AutoMigrate(&models.User{})
 	db.AutoMigrate(&models.Partition{})
+	db.AutoMigrate(&models.PartitionLyric{})
+	db.AutoMigrate(&models.Collection{})
+	db.AutoMigrate(&models.CollectionItem{})
+	db.AutoMigrate(&models.Favorite{})
+	db.AutoMigrate(&models.PartitionHistory{})
+	db.AutoMigrate(&models.Rating{})
+	db.AutoMigrate(&models.Comment{})
+	db.AutoMigrate(&models.Report{})
+	db.AutoMigrate(&models.PartitionVersion{})
+	db.AutoMigrate(&models.Composer{})
+	db.AutoMigrate(&models.TaxonomyTerm{})
+	db.AutoMigrate(&models.Tag{})
+	db.AutoMigrate(&models.PartitionTag{})
+	db.AutoMigrate(&models.PartitionRelation{})
+	db.AutoMigrate(&models.Organization{})
+	db.AutoMigrate(&models.OrganizationMember{})
+	db.AutoMigrate(&models.OrganizationInvitation{})
+	db.AutoMigrate(&models.Event{})
+	db.AutoMigrate(&models.EventItem{})
+	db.AutoMigrate(&models.Annotation{})
+
+	if err := SeedTaxonomies(db); err != nil {
+		log.Printf("Erreur lors de l'initialisation des taxonomies : %v", err)
+	}
 
 	DB = db
 }
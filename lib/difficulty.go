@@ -0,0 +1,130 @@
+package lib
+
+import (
+	"math"
+	"solfa-back/models"
+	"strings"
+)
+
+// Échelle des niveaux de difficulté (0 : non renseigné)
+const (
+	DifficultyMin = 1 // Débutant
+	DifficultyMax = 5 // Très difficile
+)
+
+// Nombre minimal de notes de la mélodie pour estimer une difficulté
+const difficultyMinNotes = 8
+
+// Poids des critères dans l'estimation de la difficulté
+const (
+	difficultyRangeWeight       = 0.25 // Ambitus de la mélodie
+	difficultyDensityWeight     = 0.25 // Notes par seconde (tempo et rythme)
+	difficultyChromaticWeight   = 0.2  // Altérations étrangères à la tonalité
+	difficultyLeapWeight        = 0.15 // Sauts de plus d'une tierce majeure
+	difficultyKeyChangeWeight   = 0.1  // Changements de tonalité
+	difficultyMeterChangeWeight = 0.05 // Changements de mesure
+)
+
+// ValidDifficulty indique si un niveau saisi appartient à l'échelle (0 efface le niveau)
+func ValidDifficulty(level int) bool {
+	return level == 0 || (level >= DifficultyMin && level <= DifficultyMax)
+}
+
+// EstimateDifficulty estime la difficulté (de 1 à 5, au dixième) d'une pièce à partir de ses
+// métadonnées et de sa ligne mélodique : ambitus, densité rythmique, altérations, sauts,
+// changements de tonalité et de mesure. Retourne 0 si la mélodie est trop courte.
+func EstimateDifficulty(metadata models.MusicMetadata, melody []int) float64 {
+	if len(melody) < difficultyMinNotes || metadata.Duration <= 0 {
+		return 0
+	}
+
+	lowest, highest := melody[0], melody[0]
+	for _, pitch := range melody {
+		lowest = min(lowest, pitch)
+		highest = max(highest, pitch)
+	}
+	// Une octave est à la portée de tous ; deux octaves sont exigeantes
+	rangeScore := clamp01(float64(highest-lowest-12) / 12)
+
+	// Une note par seconde est lente ; quatre notes par seconde sont rapides
+	densityScore := clamp01((float64(len(melody))/metadata.Duration - 1) / 3)
+
+	chromaticScore := 0.0
+	key := metadata.KeySignature
+	if len(metadata.KeySignatures) > 0 {
+		key = metadata.KeySignatures[0].Key
+	}
+	if scale, ok := diatonicScale(key); ok {
+		chromatic := 0
+		for _, pitch := range melody {
+			if !scale[((pitch%12)+12)%12] {
+				chromatic++
+			}
+		}
+		chromaticScore = clamp01(float64(chromatic) / float64(len(melody)) / 0.2)
+	}
+
+	leaps := 0
+	for i := 1; i < len(melody); i++ {
+		if interval := melody[i] - melody[i-1]; interval > 4 || interval < -4 {
+			leaps++
+		}
+	}
+	leapScore := clamp01(float64(leaps) / float64(len(melody)-1) / 0.3)
+
+	keyChangeScore := clamp01(float64(len(metadata.KeySignatures)-1) / 3)
+	meterChangeScore := clamp01(float64(len(metadata.TimeSignatures)-1) / 4)
+
+	score := difficultyRangeWeight*rangeScore +
+		difficultyDensityWeight*densityScore +
+		difficultyChromaticWeight*chromaticScore +
+		difficultyLeapWeight*leapScore +
+		difficultyKeyChangeWeight*keyChangeScore +
+		difficultyMeterChangeWeight*meterChangeScore
+	return math.Round((DifficultyMin+score*(DifficultyMax-DifficultyMin))*10) / 10
+}
+
+// diatonicScale retourne les classes de hauteur (0 = do) de la gamme d'une tonalité
+// au format "D major" ou "B minor" (la mineure relative partage la gamme de la majeure)
+func diatonicScale(key string) ([12]bool, bool) {
+	var scale [12]bool
+	tonic, mode, found := strings.Cut(strings.TrimSpace(key), " ")
+	if !found {
+		return scale, false
+	}
+	names := majorKeys
+	if mode == "minor" {
+		names = minorKeys
+	}
+	for i, name := range names {
+		if name != tonic {
+			continue
+		}
+		fifths := i - 7
+		// Gamme majeure : sept quintes consécutives à partir de la sous-dominante
+		for degree := -1; degree < 6; degree++ {
+			scale[(((fifths+degree)*7)%12+12)%12] = true
+		}
+		return scale, true
+	}
+	return scale, false
+}
+
+// clamp01 ramène une valeur dans l'intervalle [0, 1]
+func clamp01(value float64) float64 {
+	return math.Max(0, math.Min(1, value))
+}
+
+// ApplyEstimatedDifficulty renseigne le niveau d'une partition avec la difficulté estimée
+// lorsque ni l'uploader ni un modérateur ne l'ont fixé
+func ApplyEstimatedDifficulty(partition *models.Partition) {
+	if partition.Difficulty != 0 && partition.DifficultySource != models.DifficultyEstimated {
+		return
+	}
+	partition.Difficulty = 0
+	partition.DifficultySource = ""
+	if partition.EstimatedDifficulty > 0 {
+		partition.Difficulty = int(math.Round(partition.EstimatedDifficulty))
+		partition.DifficultySource = models.DifficultyEstimated
+	}
+}
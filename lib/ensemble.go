@@ -0,0 +1,317 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"solfa-back/models"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidEnsemble est renvoyée lorsque la formation saisie n'est pas reconnue
+var ErrInvalidEnsemble = errors.New("formation invalide")
+
+// Voicing à l'unisson
+const VoicingUnison = "unison"
+
+// Ordre des pupitres, du plus aigu au plus grave
+const voicingOrder = "SATB"
+
+// Noms de voix (normalisés) reconnus dans les noms de parties et de solistes,
+// avec le pupitre correspondant et le nom de soliste canonique
+var voiceNames = map[string]struct {
+	section string
+	soloist string
+}{
+	"soprano":       {"S", "soprano"},
+	"sopran":        {"S", "soprano"},
+	"sopranos":      {"S", "soprano"},
+	"treble":        {"S", "soprano"},
+	"dessus":        {"S", "soprano"},
+	"mezzo":         {"A", "mezzo-soprano"},
+	"alto":          {"A", "alto"},
+	"altos":         {"A", "alto"},
+	"contralto":     {"A", "alto"},
+	"countertenor":  {"A", "countertenor"},
+	"contre-tenor":  {"A", "countertenor"},
+	"tenor":         {"T", "tenor"},
+	"tenors":        {"T", "tenor"},
+	"tenore":        {"T", "tenor"},
+	"baritone":      {"B", "baritone"},
+	"baryton":       {"B", "baritone"},
+	"bariton":       {"B", "baritone"},
+	"bass":          {"B", "bass"},
+	"basse":         {"B", "bass"},
+	"basses":        {"B", "bass"},
+	"basso":         {"B", "bass"},
+	"mezzo-soprano": {"A", "mezzo-soprano"},
+}
+
+// Noms de parties vocales sans pupitre (mélodie à l'unisson)
+var unisonVoiceNames = []string{"voice", "voix", "chant", "melody", "melodie", "choir", "choeur", "chorus", "unison", "unisson"}
+
+// Mots désignant un instrument dans un nom de partie qui comporte aussi un nom de voix
+var instrumentWords = []string{
+	"sax", "saxophone", "flute", "clarinet", "clarinette", "recorder", "oboe", "hautbois",
+	"bassoon", "basson", "horn", "cor", "trumpet", "trompette", "trombone", "tuba",
+	"violin", "violon", "viola", "viole", "gamba", "cello", "violoncelle", "contrabass", "contrebasse",
+	"guitar", "guitare", "ukulele", "banjo", "drum", "drums", "tambour", "strings", "cordes",
+}
+
+// Instruments d'accompagnement reconnus (noms normalisés)
+var accompanimentInstruments = map[string]string{
+	"piano":       models.AccompanimentPiano,
+	"pianoforte":  models.AccompanimentPiano,
+	"keyboard":    models.AccompanimentPiano,
+	"clavier":     models.AccompanimentPiano,
+	"organ":       models.AccompanimentOrgan,
+	"orgue":       models.AccompanimentOrgan,
+	"organo":      models.AccompanimentOrgan,
+	"harmonium":   models.AccompanimentOrgan,
+	"orgel":       models.AccompanimentOrgan,
+	"choir organ": models.AccompanimentOrgan,
+}
+
+// Valeurs d'accompagnement saisies (slugifiées) acceptées
+var accompanimentAliases = map[string]string{
+	"a-cappella":  models.AccompanimentACappella,
+	"acappella":   models.AccompanimentACappella,
+	"a-capella":   models.AccompanimentACappella,
+	"piano":       models.AccompanimentPiano,
+	"organ":       models.AccompanimentOrgan,
+	"orgue":       models.AccompanimentOrgan,
+	"instruments": models.AccompanimentInstruments,
+	"ensemble":    models.AccompanimentInstruments,
+	"orchestra":   models.AccompanimentInstruments,
+	"orchestre":   models.AccompanimentInstruments,
+}
+
+// NormalizeVoicing met en forme une formation saisie ("s a t b", "ssa", "Unisson") :
+// pupitres en majuscules du plus aigu au plus grave, ou "unison"
+func NormalizeVoicing(voicing string) (string, error) {
+	normalized := NormalizeText(voicing)
+	if normalized == "" {
+		return "", nil
+	}
+	if slices.Contains(unisonVoiceNames, normalized) {
+		return VoicingUnison, nil
+	}
+
+	sections := []rune(strings.ToUpper(strings.ReplaceAll(normalized, " ", "")))
+	for _, section := range sections {
+		if !strings.ContainsRune(voicingOrder, section) {
+			return "", fmt.Errorf("%w: pupitres inconnus %q (S, A, T, B ou unison)", ErrInvalidEnsemble, voicing)
+		}
+	}
+	sort.SliceStable(sections, func(i, j int) bool {
+		return strings.IndexRune(voicingOrder, sections[i]) < strings.IndexRune(voicingOrder, sections[j])
+	})
+	return string(sections), nil
+}
+
+// NormalizeAccompaniment reconnaît un type d'accompagnement saisi ("a cappella", "orgue"...)
+func NormalizeAccompaniment(accompaniment string) (string, error) {
+	slug := strings.ReplaceAll(Slugify(accompaniment), "_", "-")
+	if slug == "" {
+		return "", nil
+	}
+	if value, ok := accompanimentAliases[slug]; ok {
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: accompagnement inconnu %q (a_cappella, piano, organ ou instruments)", ErrInvalidEnsemble, accompaniment)
+}
+
+// NormalizeEnsemble valide et met en forme les informations de formation d'une partition
+func NormalizeEnsemble(ensemble *models.EnsembleMetadata) error {
+	var err error
+	if ensemble.Voicing, err = NormalizeVoicing(ensemble.Voicing); err != nil {
+		return err
+	}
+	if ensemble.Accompaniment, err = NormalizeAccompaniment(ensemble.Accompaniment); err != nil {
+		return err
+	}
+	ensemble.Instrumentation = cleanNames(ensemble.Instrumentation)
+
+	var soloists []string
+	for _, soloist := range cleanNames(ensemble.Soloists) {
+		if voice, ok := voiceNames[Slugify(soloist)]; ok {
+			soloist = voice.soloist
+		}
+		if !slices.Contains(soloists, soloist) {
+			soloists = append(soloists, soloist)
+		}
+	}
+	ensemble.Soloists = soloists
+	return nil
+}
+
+// cleanNames supprime les espaces superflus, les valeurs vides et les doublons
+func cleanNames(names []string) []string {
+	var cleaned []string
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		if name != "" && !slices.ContainsFunc(cleaned, func(existing string) bool {
+			return NormalizeText(existing) == NormalizeText(name)
+		}) {
+			cleaned = append(cleaned, name)
+		}
+	}
+	return cleaned
+}
+
+// FillEnsemble complète les champs de formation laissés vides avec ceux déduits du fichier
+func FillEnsemble(ensemble *models.EnsembleMetadata, detected models.EnsembleMetadata) {
+	if ensemble.Voicing == "" {
+		ensemble.Voicing = detected.Voicing
+	}
+	ensemble.Divisi = ensemble.Divisi || detected.Divisi
+	if ensemble.Accompaniment == "" {
+		ensemble.Accompaniment = detected.Accompaniment
+	}
+	if len(ensemble.Instrumentation) == 0 {
+		ensemble.Instrumentation = detected.Instrumentation
+	}
+	if len(ensemble.Soloists) == 0 {
+		ensemble.Soloists = detected.Soloists
+	}
+}
+
+// partVoices retourne les pupitres désignés par le nom d'une partie ("Soprano 1" donne S,
+// "S/A" donne S et A), et indique s'il s'agit d'une partie soliste
+func partVoices(name string) (sections []string, soloists []string, vocal bool) {
+	words := strings.Fields(NormalizeText(name))
+	// "Alto Sax", "Bass Guitar", "Tenor Trombone" sont des instruments
+	if slices.ContainsFunc(words, func(word string) bool { return slices.Contains(instrumentWords, word) }) {
+		return nil, nil, false
+	}
+	solo := slices.Contains(words, "solo") || slices.Contains(words, "soliste")
+
+	// Abréviations ("S A", "T1", "B") : le nom ne contient que des lettres de pupitre et des numéros
+	abbreviated := len(words) > 0
+	for _, word := range words {
+		trimmed := strings.TrimRight(word, "0123456789")
+		if len(trimmed) != 1 || !strings.Contains("satb", trimmed) {
+			abbreviated = false
+			break
+		}
+	}
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if abbreviated {
+			sections = append(sections, strings.ToUpper(strings.TrimRight(word, "0123456789")))
+			continue
+		}
+		// Noms composés ("mezzo soprano", "contre ténor")
+		if i+1 < len(words) {
+			if voice, ok := voiceNames[word+"-"+words[i+1]]; ok {
+				sections, soloists = append(sections, voice.section), append(soloists, voice.soloist)
+				i++
+				continue
+			}
+		}
+		if voice, ok := voiceNames[word]; ok {
+			sections, soloists = append(sections, voice.section), append(soloists, voice.soloist)
+		}
+	}
+
+	if len(sections) == 0 && slices.ContainsFunc(words, func(word string) bool { return slices.Contains(unisonVoiceNames, word) }) {
+		return nil, nil, true
+	}
+	if !solo {
+		soloists = nil
+	}
+	return sections, soloists, len(sections) > 0
+}
+
+// Ensemble déduit la formation des parties d'une partition MusicXML : pupitres vocaux,
+// solistes, instruments et accompagnement. Un pupitre qui contient des accords ou
+// plusieurs voix est considéré comme divisé.
+func (score MusicXMLScore) Ensemble() models.EnsembleMetadata {
+	var ensemble models.EnsembleMetadata
+	var sections []rune
+	unison := false
+
+	for _, scorePart := range score.PartList.ScoreParts {
+		name := strings.TrimSpace(scorePart.Name)
+		partSections, soloists, vocal := partVoices(name)
+		if !vocal && len(scorePart.Instruments) > 0 {
+			// Les logiciels de gravure déclarent souvent les voix comme instruments ("Soprano", "Voice")
+			partSections, soloists, vocal = partVoices(scorePart.Instruments[0].Name)
+		}
+
+		if vocal {
+			if len(soloists) > 0 {
+				ensemble.Soloists = append(ensemble.Soloists, soloists...)
+				continue
+			}
+			if len(partSections) == 0 {
+				unison = true
+				continue
+			}
+			for _, section := range partSections {
+				sections = append(sections, rune(section[0]))
+			}
+			if len(partSections) == 1 && score.partIsDivided(scorePart.ID) {
+				ensemble.Divisi = true
+			}
+			continue
+		}
+
+		instrument := name
+		if len(scorePart.Instruments) > 0 && strings.TrimSpace(scorePart.Instruments[0].Name) != "" {
+			instrument = strings.TrimSpace(scorePart.Instruments[0].Name)
+		}
+		if instrument != "" {
+			ensemble.Instrumentation = append(ensemble.Instrumentation, instrument)
+		}
+	}
+
+	switch {
+	case len(sections) > 0:
+		ensemble.Voicing, _ = NormalizeVoicing(string(sections))
+	case unison:
+		ensemble.Voicing = VoicingUnison
+	}
+
+	ensemble.Instrumentation = cleanNames(ensemble.Instrumentation)
+	for _, instrument := range ensemble.Instrumentation {
+		accompaniment, ok := accompanimentInstruments[NormalizeText(instrument)]
+		if !ok {
+			accompaniment = models.AccompanimentInstruments
+		}
+		// Un piano ou un orgue seul est un accompagnement ; plusieurs instruments forment un ensemble
+		if ensemble.Accompaniment != "" && ensemble.Accompaniment != accompaniment {
+			accompaniment = models.AccompanimentInstruments
+		}
+		ensemble.Accompaniment = accompaniment
+	}
+	if ensemble.Accompaniment == "" && (ensemble.Voicing != "" || len(ensemble.Soloists) > 0) {
+		ensemble.Accompaniment = models.AccompanimentACappella
+	}
+	ensemble.Soloists = cleanNames(ensemble.Soloists)
+	return ensemble
+}
+
+// partIsDivided indique si une partie contient des accords ou plusieurs voix
+func (score MusicXMLScore) partIsDivided(partID string) bool {
+	for _, part := range score.Parts {
+		if part.ID != partID {
+			continue
+		}
+		voices := map[string]bool{}
+		for _, measure := range part.Measures {
+			for _, note := range measure.Notes {
+				if note.Chord != nil && note.Pitch != nil {
+					return true
+				}
+				if note.Pitch != nil && note.Voice != "" {
+					voices[note.Voice] = true
+				}
+			}
+		}
+		return len(voices) > 1
+	}
+	return false
+}
@@ -46,15 +46,120 @@ func InitES() {
 	if err != nil {
 		log.Fatalf("Erreur lors de la connexion à Elasticsearch: %s", err)
 	}
+
+	EnsurePartitionIndex()
+}
+
+// Mapping des champs de l'index des partitions qui ne peuvent pas être laissés au mapping dynamique
+var partitionMappingProperties = map[string]interface{}{
+	// Paroles : champ analysé, avec vecteurs de termes pour surligner les vers trouvés
+	"lyrics": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"voice": map[string]interface{}{"type": "keyword"},
+			"verse": map[string]interface{}{"type": "integer"},
+			"text": map[string]interface{}{
+				"type":        "text",
+				"analyzer":    "standard",
+				"term_vector": "with_positions_offsets",
+			},
+		},
+	},
+	// Compositeur identifié (voir models.Composer)
+	"composer_id": map[string]interface{}{"type": "integer"},
+	// Taxonomies (slugs), utilisées comme facettes
+	"genre_path":         map[string]interface{}{"type": "keyword"},
+	"liturgical_seasons": map[string]interface{}{"type": "keyword"},
+	"occasions":          map[string]interface{}{"type": "keyword"},
+	// Formation (voir models.EnsembleMetadata)
+	"voicing":         map[string]interface{}{"type": "keyword"},
+	"divisi":          map[string]interface{}{"type": "boolean"},
+	"accompaniment":   map[string]interface{}{"type": "keyword"},
+	"instrumentation": map[string]interface{}{"type": "text", "fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword"}}},
+	"soloists":        map[string]interface{}{"type": "keyword"},
+	// Licence et domaine public
+	"licence":          map[string]interface{}{"type": "keyword"},
+	"public_domain_in": map[string]interface{}{"type": "keyword"},
+	"free":             map[string]interface{}{"type": "boolean"},
+	// Difficulté
+	"difficulty":           map[string]interface{}{"type": "integer"},
+	"estimated_difficulty": map[string]interface{}{"type": "float"},
+	// Tags libres (slugs)
+	"tags": map[string]interface{}{"type": "keyword"},
+	// Langues (codes ISO 639-1), titres et descriptions par langue (voir partitionDocument)
+	"language":         map[string]interface{}{"type": "keyword"},
+	"lyrics_languages": map[string]interface{}{"type": "keyword"},
+	"translations": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"language": map[string]interface{}{"type": "keyword"},
+		},
+	},
+	"titles":       languageFieldsMapping(),
+	"descriptions": languageFieldsMapping(),
+	// Organisation propriétaire d'une partition privée (absent pour le catalogue public)
+	"organization_id": map[string]interface{}{"type": "integer"},
+	// Œuvre regroupant les arrangements et éditions reliés (voir models.PartitionRelation)
+	"work_id": map[string]interface{}{"type": "integer"},
+	// Champs de tri
+	"collection_count": map[string]interface{}{"type": "integer"},
+	"favorite_count":   map[string]interface{}{"type": "integer"},
+	"popularity":       map[string]interface{}{"type": "float"},
+	"rating_average":   map[string]interface{}{"type": "float"},
+	"rating_count":     map[string]interface{}{"type": "integer"},
+}
+
+// EnsurePartitionIndex crée l'index des partitions s'il n'existe pas et y ajoute les champs explicites
+func EnsurePartitionIndex() {
+	mapping := map[string]interface{}{"properties": partitionMappingProperties}
+
+	res, err := ESClient.Indices.PutMapping(
+		[]string{partition_index_name},
+		esutil.NewJSONReader(mapping),
+	)
+	if err != nil {
+		logrus.WithField("error", err.Error()).Error("Erreur lors de la mise à jour du mapping Elasticsearch")
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		// L'index n'existe pas encore : on le crée directement avec le mapping
+		createRes, err := ESClient.Indices.Create(
+			partition_index_name,
+			ESClient.Indices.Create.WithBody(esutil.NewJSONReader(map[string]interface{}{"mappings": mapping})),
+		)
+		if err != nil {
+			logrus.WithField("error", err.Error()).Error("Erreur lors de la création de l'index Elasticsearch")
+			return
+		}
+		defer createRes.Body.Close()
+		res = createRes
+	}
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		logrus.WithFields(logrus.Fields{
+			"status":   res.Status(),
+			"response": string(body),
+		}).Error("Elasticsearch a refusé le mapping de l'index des partitions")
+	}
 }
 
 // LogAction enregistre une action utilisateur dans Elasticsearch
 func LogAction(action string, email string) {
+	LogActionWithDetails(action, email, nil)
+}
+
+// LogActionWithDetails enregistre une action utilisateur accompagnée d'informations
+// complémentaires (ex: l'identifiant de la partition concernée)
+func LogActionWithDetails(action string, email string, details map[string]interface{}) {
 	logData := map[string]interface{}{
 		"action":    action,
 		"email":     email,
 		"timestamp": time.Now().Format(time.RFC3339),
 	}
+	for key, value := range details {
+		logData[key] = value
+	}
 
 	// Indexer dans Elasticsearch sans spécifier de type de document
 	res, err := ESClient.Index(
@@ -80,10 +185,21 @@ func LogAction(action string, email string) {
 	}).Info("Action enregistrée dans Elasticsearch")
 }
 
+// partitionDocument est le document indexé pour une partition : ses champs, complétés
+// de ses titres et descriptions regroupés par langue pour être analysés selon leur langue
+type partitionDocument struct {
+	models.Partition
+	Titles       map[string][]string `json:"titles"`
+	Descriptions map[string][]string `json:"descriptions"`
+}
+
 // IndexPartitionInES indexe une partition dans Elasticsearch
 func IndexPartitionInES(partition models.Partition) {
+	document := partitionDocument{Partition: partition}
+	document.Titles, document.Descriptions = PartitionTitlesByLanguage(partition)
+
 	// Convertir la partition en JSON
-	jsonData, err := json.Marshal(partition)
+	jsonData, err := json.Marshal(document)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"partition": partition,
@@ -132,7 +248,7 @@ func IndexPartitionInES(partition models.Partition) {
 
 func generateHash(partition models.Partition) string {
 	// Concaténer les champs de la partition
-	data := fmt.Sprintf("%s%s%s", partition.Title, partition.Composer, partition.Genre, partition.Category) 
+	data := fmt.Sprintf("%s%s%s%s", partition.Title, partition.Composer, partition.Genre, partition.Category) 
 	// Générer le hash MD5
 	hash := md5.Sum([]byte(data))
 	return hex.EncodeToString(hash[:])
@@ -173,21 +289,36 @@ func SearchPartitionByHash(partition models.Partition) (bool, interface{}) {
 }
 
 func SearchPartitionByFields(partition models.Partition) (bool, interface{}) {
+	// Les doublons sont recherchés dans la même bibliothèque : catalogue public ou organisation
+	library := map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must_not": map[string]interface{}{"exists": map[string]interface{}{"field": "organization_id"}},
+		},
+	}
+	if partition.OrganizationID != nil {
+		library = map[string]interface{}{"term": map[string]interface{}{"organization_id": *partition.OrganizationID}}
+	}
+
 	// Rechercher sur plusieurs champs de la partition
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": map[string]interface{}{
+					"multi_match": map[string]interface{}{
+						"query":     partition.Title,
+						"fields":    []string{"title", "composer", "genre", "category"},
+						"operator":  "and",
+						"fuzziness": "AUTO",
+					},
+				},
+				"filter": library,
+			},
+		},
+	}
 	res, err := ESClient.Search(
 		ESClient.Search.WithIndex(partition_index_name),
-		ESClient.Search.WithBody(strings.NewReader(fmt.Sprintf(`
-			{
-				"query": {
-					"multi_match": {
-						"query": "%s",
-						"fields": ["title", "composer", "genre", "category"],
-						"operator": "and",
-						"fuzziness": "AUTO"
-					}
-				}
-			}`, partition.Title)), // Ici tu peux inclure un autre champ (par exemple partition.Title)
-	))
+		ESClient.Search.WithBody(esutil.NewJSONReader(query)),
+	)
 	if err != nil {
 		fmt.Println("Erreur lors de la recherche dans Elasticsearch:", err)
 		return false, nil
@@ -206,3 +337,84 @@ func SearchPartitionByFields(partition models.Partition) (bool, interface{}) {
 	return false, nil
 }
 
+
+// UpdatePartitionFieldsInES met à jour certains champs d'une partition déjà indexée
+func UpdatePartitionFieldsInES(partitionID uint, fields map[string]interface{}) error {
+	// Les documents sont retrouvés par leur champ "id" (et non par l'_id Elasticsearch)
+	return updatePartitionsInES(map[string]interface{}{"id": partitionID}, fields)
+}
+
+// updatePartitionsInES met à jour les champs donnés de toutes les partitions
+// dont le champ indiqué par term a la valeur demandée
+func updatePartitionsInES(term map[string]interface{}, fields map[string]interface{}) error {
+	body := map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": term,
+		},
+		"script": map[string]interface{}{
+			"source": "for (entry in params.fields.entrySet()) { ctx._source[entry.getKey()] = entry.getValue() }",
+			"lang":   "painless",
+			"params": map[string]interface{}{"fields": fields},
+		},
+	}
+
+	res, err := ESClient.UpdateByQuery(
+		[]string{partition_index_name},
+		ESClient.UpdateByQuery.WithBody(esutil.NewJSONReader(body)),
+		ESClient.UpdateByQuery.WithRefresh(true),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		responseBody, _ := io.ReadAll(res.Body)
+		logrus.WithFields(logrus.Fields{
+			"query":    term,
+			"status":   res.Status(),
+			"response": string(responseBody),
+		}).Error("Elasticsearch a renvoyé une erreur lors de la mise à jour")
+		return fmt.Errorf("erreur Elasticsearch: %s", res.Status())
+	}
+
+	return nil
+}
+
+// UpdatePartitionStatusInES met à jour le statut d'une partition dans Elasticsearch
+func UpdatePartitionStatusInES(partitionID uint, status string) error {
+	return UpdatePartitionFieldsInES(partitionID, map[string]interface{}{"status": status})
+}
+
+// ReindexPartitionInES remplace le document Elasticsearch d'une partition par son état
+// actuel (après un remplacement de fichier ou un retour à une version précédente)
+func ReindexPartitionInES(partition models.Partition) error {
+	body := map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{"id": partition.ID},
+		},
+	}
+
+	res, err := ESClient.DeleteByQuery(
+		[]string{partition_index_name},
+		esutil.NewJSONReader(body),
+		ESClient.DeleteByQuery.WithRefresh(true),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		responseBody, _ := io.ReadAll(res.Body)
+		logrus.WithFields(logrus.Fields{
+			"partition_id": partition.ID,
+			"status":       res.Status(),
+			"response":     string(responseBody),
+		}).Error("Elasticsearch a renvoyé une erreur lors de la suppression")
+		return fmt.Errorf("erreur Elasticsearch: %s", res.Status())
+	}
+
+	IndexPartitionInES(partition)
+	return nil
+}
@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"solfa-back/models"
+	"strings"
+	"time"
+)
+
+// Délai par défaut des rappels envoyés aux membres avant un événement
+const DefaultEventReminderHours = 24
+
+// Intervalle de recherche des rappels à envoyer
+const eventReminderInterval = 15 * time.Minute
+
+// PreloadEventItems charge le programme d'un événement dans l'ordre, avec les partitions
+// visibles par les membres des organisations données
+func PreloadEventItems(db *gorm.DB, organizationIDs []uint) *gorm.DB {
+	return db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Preload("Items.Partition", VisiblePartitions(organizationIDs))
+}
+
+// StartEventReminders planifie régulièrement l'envoi des rappels des événements à venir
+func StartEventReminders() {
+	go func() {
+		for {
+			enqueueEventReminders()
+			time.Sleep(eventReminderInterval)
+		}
+	}()
+}
+
+// enqueueEventReminders planifie l'envoi des rappels des événements entrés dans leur délai de rappel
+func enqueueEventReminders() {
+	if DB == nil {
+		return
+	}
+
+	now := time.Now()
+	var events []models.Event
+	if err := DB.Select("id").
+		Where("reminded_at IS NULL AND reminder_hours > 0 AND starts_at > ?", now).
+		Where("starts_at - reminder_hours * INTERVAL '1 hour' <= ?", now).
+		Find(&events).Error; err != nil {
+		logrus.WithField("error", err.Error()).Error("Erreur lors de la recherche des rappels d'événements")
+		return
+	}
+
+	for _, event := range events {
+		// Le rappel est marqué envoyé avant d'être planifié, pour ne pas être planifié deux fois ;
+		// la marque est retirée si la tâche est ignorée ou si aucun membre n'a pu être prévenu
+		result := DB.Model(&models.Event{}).Where("id = ? AND reminded_at IS NULL", event.ID).UpdateColumn("reminded_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+		eventID := event.ID
+		queued := EnqueueJob(fmt.Sprintf("event_reminder_%d", eventID), func() error {
+			sent, err := SendEventReminder(eventID)
+			if err != nil && sent == 0 {
+				releaseEventReminder(eventID)
+			}
+			return err
+		})
+		if !queued {
+			releaseEventReminder(eventID)
+		}
+	}
+}
+
+// releaseEventReminder retire la marque d'envoi du rappel d'un événement : il sera de nouveau
+// planifié lors de la prochaine recherche des rappels
+func releaseEventReminder(eventID uint) {
+	if err := DB.Model(&models.Event{}).Where("id = ?", eventID).UpdateColumn("reminded_at", nil).Error; err != nil {
+		logrus.WithFields(logrus.Fields{"event_id": eventID, "error": err.Error()}).Error("Erreur lors de la replanification d'un rappel d'événement")
+	}
+}
+
+// SendEventReminder envoie par email le rappel d'un événement à tous les membres de son organisation
+// et renvoie le nombre d'emails envoyés
+func SendEventReminder(eventID uint) (int, error) {
+	var event models.Event
+	if err := DB.First(&event, eventID).Error; err != nil {
+		return 0, err
+	}
+	var organization models.Organization
+	if err := DB.First(&organization, event.OrganizationID).Error; err != nil {
+		return 0, err
+	}
+	if err := PreloadEventItems(DB, []uint{organization.ID}).First(&event, eventID).Error; err != nil {
+		return 0, err
+	}
+	var emails []string
+	if err := DB.Model(&models.OrganizationMember{}).
+		Joins("JOIN users ON users.id = organization_members.user_id").
+		Where("organization_members.organization_id = ?", organization.ID).
+		Pluck("users.email", &emails).Error; err != nil {
+		return 0, err
+	}
+
+	startsAt := event.StartsAt.Local()
+	subject := fmt.Sprintf("Rappel : %s le %s", event.Title, startsAt.Format("02/01/2006 à 15h04"))
+	body := []string{fmt.Sprintf("%s - %s", organization.Name, event.Title)}
+	if label := eventKindLabels[event.Kind]; label != "" {
+		body = append(body, label)
+	}
+	body = append(body, "Le "+startsAt.Format("02/01/2006 à 15h04"))
+	if event.Location != "" {
+		body = append(body, "Lieu : "+event.Location)
+	}
+	if event.Notes != "" {
+		body = append(body, "", event.Notes)
+	}
+	if programme := EventProgramme(event); len(programme) > 0 {
+		body = append(body, "", "Programme :")
+		body = append(body, programme...)
+	}
+	body = append(body, "", fmt.Sprintf("%s/events/%d", AppURL(), event.ID))
+
+	sent := 0
+	var errs []error
+	for _, email := range emails {
+		if err := SendMail(email, subject, strings.Join(body, "\n")+"\n"); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", email, err))
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
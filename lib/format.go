@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Taille maximale d'un fichier de partition envoyé
+const MaxPartitionFileSize = 50 << 20
+
+// ErrPartitionFileTooLarge est renvoyée pour un fichier dépassant MaxPartitionFileSize
+var ErrPartitionFileTooLarge = fmt.Errorf("fichier trop volumineux (%d Mo au maximum)", MaxPartitionFileSize>>20)
+
+// ReadPartitionFile lit un fichier de partition envoyé, dans la limite de MaxPartitionFileSize
+func ReadPartitionFile(file io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(file, MaxPartitionFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > MaxPartitionFileSize {
+		return nil, ErrPartitionFileTooLarge
+	}
+	return content, nil
+}
+
+// Formats de partition reconnus
+const (
+	FormatPDF      = "pdf"
+	FormatMIDI     = "midi"
+	FormatABC      = "abc"
+	FormatMusicXML = "musicxml"
+	FormatImage    = "image"
+	FormatOther    = "other"
+)
+
+// DetectPartitionFormat déduit le format d'une partition à partir de l'extension du fichier
+func DetectPartitionFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".pdf":
+		return FormatPDF
+	case ".mid", ".midi":
+		return FormatMIDI
+	case ".abc":
+		return FormatABC
+	case ".musicxml", ".xml":
+		return FormatMusicXML
+	case ".png", ".jpg", ".jpeg", ".gif", ".tif", ".tiff":
+		return FormatImage
+	default:
+		return FormatOther
+	}
+}
+
+// FormatContentType retourne le type MIME utilisé pour stocker un fichier du format donné
+func FormatContentType(format string) string {
+	switch format {
+	case FormatPDF:
+		return "application/pdf"
+	case FormatMIDI:
+		return "audio/midi"
+	case FormatABC:
+		return "text/vnd.abc; charset=utf-8"
+	case FormatMusicXML:
+		return "application/vnd.recordare.musicxml+xml"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,81 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+)
+
+// backgroundJob est une tâche exécutée en dehors du cycle requête/réponse
+type backgroundJob struct {
+	name string
+	run  func() error
+}
+
+// File d'attente des tâches de fond
+var jobQueue = make(chan backgroundJob, 256)
+
+// StartJobWorkers démarre les goroutines qui exécutent les tâches de fond
+func StartJobWorkers(workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobQueue {
+				runJob(job)
+			}
+		}()
+	}
+}
+
+// EnqueueJob ajoute une tâche à la file d'attente ; si la file est pleine,
+// la tâche est ignorée, l'erreur est journalisée et false est renvoyé
+func EnqueueJob(name string, run func() error) bool {
+	select {
+	case jobQueue <- backgroundJob{name: name, run: run}:
+		return true
+	default:
+		logrus.WithField("job", name).Error("File des tâches de fond pleine, tâche ignorée")
+		return false
+	}
+}
+
+// enqueuePartitionsJob planifie une seule tâche qui applique refresh à chaque partition
+// (sans doublon) : une longue liste ne doit pas remplir la file d'attente. Une partition
+// en échec n'interrompt pas le traitement des suivantes.
+func enqueuePartitionsJob(name string, partitionIDs []uint, refresh func(partitionID uint) error) {
+	seen := make(map[uint]bool, len(partitionIDs))
+	var ids []uint
+	for _, id := range partitionIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	EnqueueJob(fmt.Sprintf("%s_%d_partitions", name, len(ids)), func() error {
+		var errs []error
+		for _, id := range ids {
+			if err := refresh(id); err != nil {
+				errs = append(errs, fmt.Errorf("partition %d: %w", id, err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// runJob exécute une tâche en journalisant son résultat (et en interceptant les panics)
+func runJob(job backgroundJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.WithFields(logrus.Fields{"job": job.name, "panic": r}).Error("Tâche de fond interrompue")
+		}
+	}()
+
+	if err := job.run(); err != nil {
+		logrus.WithFields(logrus.Fields{"job": job.name, "error": err.Error()}).Error("Échec de la tâche de fond")
+		return
+	}
+	logrus.WithField("job", job.name).Info("Tâche de fond terminée")
+}
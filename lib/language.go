@@ -0,0 +1,237 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"solfa-back/models"
+	"strings"
+)
+
+// ErrInvalidLanguage est renvoyée lorsqu'une langue saisie n'est pas reconnue
+var ErrInvalidLanguage = errors.New("langue inconnue")
+
+// Nombre minimal de mots outils reconnus pour attribuer une langue à des paroles
+const languageDetectionMinHits = 3
+
+// language décrit une langue reconnue : noms acceptés à la saisie (normalisés), analyseur
+// Elasticsearch des titres et mots outils servant à reconnaître la langue des paroles
+type language struct {
+	names     []string
+	analyzer  string
+	stopWords []string
+}
+
+// Langues reconnues, par code ISO 639-1
+var languages = map[string]language{
+	"fr": {
+		names:     []string{"francais", "french", "fra", "fre"},
+		analyzer:  "french",
+		stopWords: []string{"le", "les", "des", "du", "une", "est", "dans", "pour", "qui", "sur", "au", "aux", "nous", "vous", "il", "elle", "pas", "notre", "mon", "ton", "ses", "tes", "ce"},
+	},
+	"en": {
+		names:     []string{"anglais", "english", "eng"},
+		analyzer:  "english",
+		stopWords: []string{"the", "and", "of", "to", "is", "that", "it", "for", "with", "you", "my", "his", "he", "we", "our", "thy", "thou", "thee", "be", "all", "this"},
+	},
+	"de": {
+		names:     []string{"allemand", "german", "deutsch", "deu", "ger"},
+		analyzer:  "german",
+		stopWords: []string{"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "mit", "den", "dem", "zu", "ich", "du", "wir", "sich", "auf", "im", "uns", "dich", "alles", "nur", "sind", "wie"},
+	},
+	"la": {
+		names:     []string{"latin", "lat"},
+		analyzer:  "standard",
+		stopWords: []string{"et", "est", "non", "ad", "cum", "quae", "quod", "nobis", "deus", "domine", "dominus", "sanctus", "tu", "me", "mea", "meus", "sunt", "pax", "gloria", "nos", "tibi", "qui", "in", "dominum", "dei", "mundi", "ave", "nostri"},
+	},
+	"es": {
+		names:     []string{"espagnol", "spanish", "espanol", "castellano", "spa"},
+		analyzer:  "spanish",
+		stopWords: []string{"el", "los", "las", "y", "que", "en", "una", "por", "con", "mi", "su", "es", "del", "al", "se", "tu", "yo"},
+	},
+	"it": {
+		names:     []string{"italien", "italian", "italiano", "ita"},
+		analyzer:  "italian",
+		stopWords: []string{"il", "lo", "gli", "di", "che", "non", "per", "con", "mi", "si", "della", "nel", "sono", "io", "ti", "ed", "alla"},
+	},
+	"pt": {
+		names:     []string{"portugais", "portuguese", "portugues", "por"},
+		analyzer:  "portuguese",
+		stopWords: []string{"os", "as", "que", "nao", "um", "uma", "com", "por", "do", "da", "em", "meu", "minha", "voce", "eu"},
+	},
+	"nl": {
+		names:     []string{"neerlandais", "dutch", "nederlands", "nld", "dut"},
+		analyzer:  "dutch",
+		stopWords: []string{"het", "een", "en", "van", "ik", "je", "niet", "dat", "op", "te", "met", "zijn", "wij", "ons"},
+	},
+	"ca": {
+		names:     []string{"catalan", "catala", "cat"},
+		analyzer:  "catalan",
+		stopWords: []string{"els", "les", "amb", "per", "que", "del", "una", "som", "nostre", "vostre"},
+	},
+	"ru": {names: []string{"russe", "russian", "rus"}, analyzer: "russian"},
+	"pl": {names: []string{"polonais", "polish", "polski", "pol"}, analyzer: "standard"},
+	"cs": {names: []string{"tcheque", "czech", "cestina", "ces", "cze"}, analyzer: "czech"},
+	"sv": {names: []string{"suedois", "swedish", "svenska", "swe"}, analyzer: "swedish"},
+	"no": {names: []string{"norvegien", "norwegian", "norsk", "nor"}, analyzer: "norwegian"},
+	"da": {names: []string{"danois", "danish", "dansk", "dan"}, analyzer: "danish"},
+	"hu": {names: []string{"hongrois", "hungarian", "magyar", "hun"}, analyzer: "hungarian"},
+	"el": {names: []string{"grec", "greek", "ell", "gre"}, analyzer: "greek"},
+	"eu": {names: []string{"basque", "euskara", "eus", "baq"}, analyzer: "basque"},
+	"br": {names: []string{"breton", "brezhoneg", "bre"}, analyzer: "standard"},
+	"oc": {names: []string{"occitan", "oci"}, analyzer: "standard"},
+}
+
+// NormalizeLanguage reconnaît une langue saisie par son code ("fr", "en-GB") ou son nom
+// ("français", "German") et retourne son code ISO 639-1
+func NormalizeLanguage(value string) (string, error) {
+	normalized := NormalizeText(value)
+	if normalized == "" {
+		return "", nil
+	}
+	// Variante régionale ("en-GB", "pt_BR") : seule la langue est conservée
+	code, _, _ := strings.Cut(strings.ReplaceAll(normalized, "_", " "), " ")
+	if _, ok := languages[code]; ok {
+		return code, nil
+	}
+	for code, language := range languages {
+		if slices.Contains(language.names, normalized) {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidLanguage, value)
+}
+
+// NormalizeLanguages reconnaît une liste de langues et supprime les doublons
+func NormalizeLanguages(values []string) ([]string, error) {
+	var codes []string
+	for _, value := range values {
+		code, err := NormalizeLanguage(value)
+		if err != nil {
+			return nil, err
+		}
+		if code != "" && !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
+
+// NormalizeLanguageMetadata valide et met en forme les langues et les titres traduits
+// d'une partition : une seule traduction par langue, différente de la langue originale
+func NormalizeLanguageMetadata(metadata *models.LanguageMetadata) error {
+	var err error
+	if metadata.Language, err = NormalizeLanguage(metadata.Language); err != nil {
+		return err
+	}
+	if metadata.LyricsLanguages, err = NormalizeLanguages(metadata.LyricsLanguages); err != nil {
+		return err
+	}
+	metadata.Description = strings.TrimSpace(metadata.Description)
+
+	var translations []models.TitleTranslation
+	for _, translation := range metadata.Translations {
+		code, err := NormalizeLanguage(translation.Language)
+		if err != nil {
+			return err
+		}
+		translation.Language = code
+		translation.Title = strings.Join(strings.Fields(translation.Title), " ")
+		translation.Description = strings.TrimSpace(translation.Description)
+		if code == "" || translation.Title == "" {
+			return fmt.Errorf("%w: chaque traduction doit indiquer sa langue et son titre", ErrInvalidLanguage)
+		}
+		if code == metadata.Language || slices.ContainsFunc(translations, func(existing models.TitleTranslation) bool {
+			return existing.Language == code
+		}) {
+			return fmt.Errorf("%w: plusieurs titres en %q", ErrInvalidLanguage, code)
+		}
+		translations = append(translations, translation)
+	}
+	metadata.Translations = translations
+	return nil
+}
+
+// DetectLanguage devine la langue d'un texte (des paroles) d'après ses mots outils ;
+// retourne une chaîne vide si aucune langue ne se distingue
+func DetectLanguage(text string) string {
+	scores := map[string]int{}
+	for _, word := range strings.Fields(NormalizeText(text)) {
+		for code, language := range languages {
+			if slices.Contains(language.stopWords, word) {
+				scores[code]++
+			}
+		}
+	}
+
+	best, bestScore, tied := "", 0, false
+	for code, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, tied = code, score, false
+		case score == bestScore:
+			tied = true
+		}
+	}
+	if bestScore < languageDetectionMinHits || tied {
+		return ""
+	}
+	return best
+}
+
+// DetectLyricsLanguages retourne les langues reconnues des paroles, couplet par couplet
+func DetectLyricsLanguages(lyrics []models.PartitionLyric) []string {
+	var codes []string
+	for _, lyric := range lyrics {
+		if code := DetectLanguage(lyric.Text); code != "" && !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// FillLyricsLanguages complète les langues des paroles lorsqu'elles n'ont pas été saisies :
+// langues déclarées par le fichier, ou à défaut reconnues dans les paroles
+func FillLyricsLanguages(metadata *models.LanguageMetadata, declared []string, lyrics []models.PartitionLyric) {
+	if len(metadata.LyricsLanguages) > 0 {
+		return
+	}
+	// Les langues déclarées par le fichier peuvent être invalides ("und", "x-none")
+	for _, value := range declared {
+		if code, err := NormalizeLanguage(value); err == nil && code != "" && !slices.Contains(metadata.LyricsLanguages, code) {
+			metadata.LyricsLanguages = append(metadata.LyricsLanguages, code)
+		}
+	}
+	if len(metadata.LyricsLanguages) == 0 {
+		metadata.LyricsLanguages = DetectLyricsLanguages(lyrics)
+	}
+}
+
+// languageFieldsMapping retourne le mapping d'un objet Elasticsearch comportant un champ
+// texte par langue, analysé selon cette langue
+func languageFieldsMapping() map[string]interface{} {
+	properties := map[string]interface{}{}
+	for code, language := range languages {
+		properties[code] = map[string]interface{}{"type": "text", "analyzer": language.analyzer}
+	}
+	return map[string]interface{}{"properties": properties}
+}
+
+// PartitionTitlesByLanguage retourne les titres et descriptions d'une partition regroupés
+// par langue, pour les champs "titles" et "descriptions" de l'index
+func PartitionTitlesByLanguage(partition models.Partition) (titles, descriptions map[string][]string) {
+	titles, descriptions = map[string][]string{}, map[string][]string{}
+	if partition.Language != "" {
+		titles[partition.Language] = append(titles[partition.Language], partition.Title)
+		if partition.Description != "" {
+			descriptions[partition.Language] = append(descriptions[partition.Language], partition.Description)
+		}
+	}
+	for _, translation := range partition.Translations {
+		titles[translation.Language] = append(titles[translation.Language], translation.Title)
+		if translation.Description != "" {
+			descriptions[translation.Language] = append(descriptions[translation.Language], translation.Description)
+		}
+	}
+	return titles, descriptions
+}
@@ -0,0 +1,216 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"solfa-back/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidLicence est renvoyée lorsqu'une licence saisie n'est pas reconnue
+var ErrInvalidLicence = errors.New("licence inconnue")
+
+// copyrightRule décrit la durée de protection d'une œuvre dans une juridiction
+type copyrightRule struct {
+	lifePlus       int // Années de protection après la mort du dernier auteur
+	publishedTerm  int // Années de protection après publication (0 : non applicable)
+	formerLifePlus int // Ancienne durée après la mort, pour les œuvres déjà libres avant l'allongement
+	extendedIn     int // Année de l'allongement de la durée de protection
+}
+
+// Règles de calcul du domaine public ; la protection court jusqu'à la fin de l'année civile
+var copyrightRules = map[string]copyrightRule{
+	"EU": {lifePlus: 70},
+	"UK": {lifePlus: 70},
+	"CH": {lifePlus: 70},
+	// Œuvres publiées : 95 ans après publication ; sinon 70 ans après la mort
+	"US": {lifePlus: 70, publishedTerm: 95},
+	// Allongement de 50 à 70 ans fin 2022, sans effet sur les œuvres déjà libres
+	"CA": {lifePlus: 70, formerLifePlus: 50, extendedIn: 2022},
+}
+
+// Licences libres : la partition peut être téléchargée par tous
+var freeLicences = []string{models.LicencePublicDomain, models.LicenceCCBY, models.LicenceCCBYSA}
+
+// Libellés et adresses des licences
+var licenceLabels = map[string]string{
+	models.LicencePublicDomain:      "Domaine public",
+	models.LicenceCCBY:              "CC BY 4.0",
+	models.LicenceCCBYSA:            "CC BY-SA 4.0",
+	models.LicenceAllRightsReserved: "Tous droits réservés, reproduit avec autorisation",
+	models.LicencePublisher:         "Tous droits réservés, sous licence de l'éditeur",
+}
+
+var licenceURLs = map[string]string{
+	models.LicencePublicDomain: "https://creativecommons.org/publicdomain/mark/1.0/",
+	models.LicenceCCBY:         "https://creativecommons.org/licenses/by/4.0/",
+	models.LicenceCCBYSA:       "https://creativecommons.org/licenses/by-sa/4.0/",
+}
+
+// Valeurs de licence saisies (slugifiées) acceptées
+var licenceAliases = map[string]string{
+	"public-domain":        models.LicencePublicDomain,
+	"domaine-public":       models.LicencePublicDomain,
+	"pd":                   models.LicencePublicDomain,
+	"cc0":                  models.LicencePublicDomain,
+	"cc-by":                models.LicenceCCBY,
+	"cc-by-4-0":            models.LicenceCCBY,
+	"cc-by-sa":             models.LicenceCCBYSA,
+	"cc-by-sa-4-0":         models.LicenceCCBYSA,
+	"all-rights-reserved":  models.LicenceAllRightsReserved,
+	"tous-droits-reserves": models.LicenceAllRightsReserved,
+	"publisher":            models.LicencePublisher,
+	"editeur":              models.LicencePublisher,
+}
+
+// Compositeurs désignant une œuvre sans auteur connu
+var anonymousComposers = []string{"traditionnel", "traditional", "trad", "anonyme", "anonymous", "anon"}
+
+// CopyrightJurisdiction retourne la juridiction utilisée pour autoriser les téléchargements
+// (variable COPYRIGHT_JURISDICTION, "EU" par défaut)
+func CopyrightJurisdiction() string {
+	if jurisdiction := strings.ToUpper(os.Getenv("COPYRIGHT_JURISDICTION")); copyrightRules[jurisdiction] != (copyrightRule{}) {
+		return jurisdiction
+	}
+	return "EU"
+}
+
+// NormalizeLicence reconnaît une licence saisie ("CC BY-SA", "domaine public"...)
+func NormalizeLicence(licence string) (string, error) {
+	slug := strings.ReplaceAll(Slugify(licence), "_", "-")
+	if slug == "" {
+		return "", nil
+	}
+	if value, ok := licenceAliases[slug]; ok {
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: %q (public_domain, cc_by, cc_by_sa, all_rights_reserved ou publisher)", ErrInvalidLicence, licence)
+}
+
+// LicenceLabel retourne le libellé d'une licence, ou une chaîne vide si elle est inconnue
+func LicenceLabel(licence string) string {
+	return licenceLabels[licence]
+}
+
+// LicenceURL retourne l'adresse du texte d'une licence libre
+func LicenceURL(licence string) string {
+	return licenceURLs[licence]
+}
+
+// PublicDomainJurisdictions retourne les juridictions où une œuvre est probablement dans le
+// domaine public en l'année donnée, d'après les dates de décès de son compositeur et de son
+// arrangeur et sa date de publication. Sans date de décès connue, l'œuvre est considérée protégée.
+func PublicDomainJurisdictions(partition models.Partition, composer *models.Composer, year int) []string {
+	var deathYears []int
+	if composer != nil && composer.DeathYear > 0 {
+		deathYears = append(deathYears, composer.DeathYear)
+	} else if !slices.Contains(anonymousComposers, NormalizeText(partition.Composer)) {
+		return []string{} // Compositeur inconnu ou vivant
+	}
+	if partition.Arranger != "" {
+		if partition.ArrangerDeathYear == 0 {
+			return []string{}
+		}
+		deathYears = append(deathYears, partition.ArrangerDeathYear)
+	}
+	lastDeath := 0 // Œuvre traditionnelle sans arrangeur
+	for _, deathYear := range deathYears {
+		lastDeath = max(lastDeath, deathYear)
+	}
+
+	jurisdictions := []string{}
+	for jurisdiction, rule := range copyrightRules {
+		free := lastDeath+rule.lifePlus < year
+		if rule.formerLifePlus > 0 && lastDeath+rule.formerLifePlus < rule.extendedIn {
+			free = true
+		}
+		if rule.publishedTerm > 0 && !partition.ReleaseDate.IsZero() {
+			free = partition.ReleaseDate.Year()+rule.publishedTerm < year
+		}
+		if free {
+			jurisdictions = append(jurisdictions, jurisdiction)
+		}
+	}
+	sort.Strings(jurisdictions)
+	return jurisdictions
+}
+
+// RefreshPartitionLicence recalcule le statut de domaine public d'une partition et indique
+// si elle peut être téléchargée par tous : une licence libre, ou à défaut de licence, un
+// domaine public probable dans la juridiction configurée
+func RefreshPartitionLicence(partition *models.Partition) error {
+	var composer *models.Composer
+	if partition.ComposerID != nil {
+		composer = &models.Composer{}
+		if err := DB.First(composer, *partition.ComposerID).Error; err != nil {
+			return err
+		}
+	}
+
+	partition.PublicDomainIn = PublicDomainJurisdictions(*partition, composer, time.Now().Year())
+	switch {
+	case partition.Licence != "":
+		partition.Free = slices.Contains(freeLicences, partition.Licence)
+	default:
+		partition.Free = slices.Contains(partition.PublicDomainIn, CopyrightJurisdiction())
+	}
+	return nil
+}
+
+// RefreshPartitionsLicence recalcule le statut juridique des partitions sélectionnées
+// (toutes si composerID vaut 0), en base et dans Elasticsearch. Le domaine public évoluant
+// chaque 1er janvier, il est recalculé au démarrage.
+func RefreshPartitionsLicence(composerID uint) error {
+	query := DB.Model(&models.Partition{})
+	if composerID != 0 {
+		query = query.Where("composer_id = ?", composerID)
+	}
+	var partitions []models.Partition
+	if err := query.Select("id", "composer", "composer_id", "release_date", "licence", "arranger", "arranger_death_year", "public_domain_in", "free").
+		Find(&partitions).Error; err != nil {
+		return err
+	}
+
+	for _, partition := range partitions {
+		previousFree, previousIn := partition.Free, partition.PublicDomainIn
+		if err := RefreshPartitionLicence(&partition); err != nil {
+			return err
+		}
+		if partition.Free == previousFree && slices.Equal(partition.PublicDomainIn, previousIn) {
+			continue
+		}
+		if err := DB.Model(&models.Partition{ID: partition.ID}).Select("public_domain_in", "free").
+			UpdateColumns(&models.Partition{LicenceMetadata: partition.LicenceMetadata}).Error; err != nil {
+			return err
+		}
+		if err := UpdatePartitionFieldsInES(partition.ID, map[string]interface{}{
+			"public_domain_in": partition.PublicDomainIn,
+			"free":             partition.Free,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshAllPartitionsLicence recalcule le statut juridique de toutes les partitions
+func RefreshAllPartitionsLicence() error {
+	return RefreshPartitionsLicence(0)
+}
+
+// PartitionLicenceLabel retourne la mention de licence d'une partition, reprise dans le
+// filigrane et les en-têtes des téléchargements ; vide si la partition est protégée sans licence connue
+func PartitionLicenceLabel(partition models.Partition) string {
+	label := LicenceLabel(partition.Licence)
+	switch {
+	case partition.Licence == models.LicencePublisher && partition.Publisher != "":
+		label += " (" + partition.Publisher + ")"
+	case partition.Licence == "" && partition.Free:
+		label = "Domaine public (" + CopyrightJurisdiction() + ")"
+	}
+	return label
+}
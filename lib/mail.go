@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// AppURL retourne l'adresse publique de l'application, utilisée dans les liens des emails
+// (variable APP_URL, "http://localhost:8080" par défaut)
+func AppURL() string {
+	if url := strings.TrimRight(os.Getenv("APP_URL"), "/"); url != "" {
+		return url
+	}
+	return "http://localhost:8080"
+}
+
+// SendMail envoie un email texte avec le serveur SMTP configuré
+// (SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD et SMTP_FROM)
+func SendMail(to, subject, body string) error {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return errors.New("serveur SMTP non configuré (SMTP_HOST)")
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	user := os.Getenv("SMTP_USER")
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = user
+	}
+
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		from, to, mime.QEncoding.Encode("utf-8", subject), body)
+	return smtp.SendMail(host+":"+port, auth, from, []string{to}, []byte(message))
+}
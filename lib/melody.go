@@ -0,0 +1,171 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"solfa-back/models"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Nombre de notes conservées pour l'incipit mélodique d'une partition
+const IncipitLength = 32
+
+// MelodyStartToken marque le début de la mélodie, pour favoriser les correspondances sur l'incipit
+const MelodyStartToken = "s"
+
+// SetMelodyIncipit enregistre l'incipit d'une mélodie et ses jetons de recherche
+// (intervalles et code de Parsons), indépendants de la transposition
+func SetMelodyIncipit(metadata *models.MusicMetadata, melody []int) {
+	if len(melody) > IncipitLength {
+		melody = melody[:IncipitLength]
+	}
+	if len(melody) < 2 {
+		return
+	}
+	metadata.Incipit = melody
+	metadata.MelodyIntervals = MelodyStartToken + " " + strings.Join(MelodyIntervalTokens(melody), " ")
+	metadata.MelodyContour = MelodyStartToken + " " + strings.Join(MelodyContourTokens(melody), " ")
+}
+
+// MelodyIntervalTokens convertit une suite de hauteurs en jetons d'intervalles ("u2", "d3", "r")
+func MelodyIntervalTokens(melody []int) []string {
+	var tokens []string
+	for i := 1; i < len(melody); i++ {
+		interval := melody[i] - melody[i-1]
+		switch {
+		case interval > 0:
+			tokens = append(tokens, fmt.Sprintf("u%d", interval))
+		case interval < 0:
+			tokens = append(tokens, fmt.Sprintf("d%d", -interval))
+		default:
+			tokens = append(tokens, "r")
+		}
+	}
+	return tokens
+}
+
+// MelodyContourTokens convertit une suite de hauteurs en code de Parsons ("u", "d", "r")
+func MelodyContourTokens(melody []int) []string {
+	var tokens []string
+	for _, token := range MelodyIntervalTokens(melody) {
+		tokens = append(tokens, token[:1])
+	}
+	return tokens
+}
+
+// ParseContour lit un code de Parsons saisi par l'utilisateur ("*UDRU", "u d r u")
+func ParseContour(contour string) ([]string, error) {
+	var tokens []string
+	for _, r := range strings.ToLower(contour) {
+		switch r {
+		case 'u', 'd', 'r':
+			tokens = append(tokens, string(r))
+		case '*', ' ', ',', '-':
+		default:
+			return nil, fmt.Errorf("caractère '%c' invalide dans le contour (U, D ou R attendus)", r)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("contour vide")
+	}
+	return tokens, nil
+}
+
+// Noms de notes reconnus : lettres anglo-saxonnes et syllabes du solfège
+var noteSteps = map[string]int{
+	"c": 0, "d": 2, "e": 4, "f": 5, "g": 7, "a": 9, "b": 11,
+	"do": 0, "ut": 0, "re": 2, "ré": 2, "mi": 4, "fa": 5, "sol": 7, "so": 7, "la": 9, "si": 11, "ti": 11,
+}
+
+// ParseMelodyNotes lit une suite de notes saisie par l'utilisateur. Les notes peuvent être
+// des numéros MIDI ("60,62,64"), des noms avec ou sans octave ("C4 D4 Eb4", "C D E")
+// ou des syllabes du solfège ("do ré mi"). Sans octave, la note la plus proche de la
+// précédente est retenue.
+func ParseMelodyNotes(notes string) ([]int, error) {
+	fields := strings.FieldsFunc(notes, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+
+	var melody []int
+	for _, field := range fields {
+		if number, err := strconv.Atoi(field); err == nil {
+			if number < 0 || number > 127 {
+				return nil, fmt.Errorf("note MIDI '%s' hors limites", field)
+			}
+			melody = append(melody, number)
+			continue
+		}
+
+		pitch, hasOctave, err := parseNoteName(field)
+		if err != nil {
+			return nil, err
+		}
+		if !hasOctave && len(melody) > 0 {
+			// Choisir l'octave la plus proche de la note précédente
+			previous := melody[len(melody)-1]
+			for pitch-previous > 6 {
+				pitch -= 12
+			}
+			for previous-pitch > 6 {
+				pitch += 12
+			}
+		}
+		melody = append(melody, pitch)
+	}
+
+	if len(melody) == 0 {
+		return nil, errors.New("aucune note")
+	}
+	return melody, nil
+}
+
+// parseNoteName lit un nom de note ("C#4", "Bb", "sol", "fa#3") et retourne sa hauteur MIDI
+func parseNoteName(name string) (int, bool, error) {
+	lower := strings.ToLower(name)
+
+	// Séparer le nom, les altérations et l'octave
+	end := strings.IndexAny(lower, "#♯♭0123456789-")
+	if end < 0 {
+		end = len(lower)
+	}
+	letters := lower[:end]
+	rest := lower[end:]
+
+	// "b" après une lettre est un bémol ("eb", "bb") sauf pour les syllabes
+	alter := 0
+	if _, ok := noteSteps[letters]; !ok && len(letters) > 1 && strings.HasSuffix(letters, "b") {
+		letters = letters[:len(letters)-1]
+		alter--
+	}
+	step, ok := noteSteps[letters]
+	if !ok {
+		return 0, false, fmt.Errorf("note '%s' non reconnue", name)
+	}
+
+	for strings.HasPrefix(rest, "#") || strings.HasPrefix(rest, "♯") || strings.HasPrefix(rest, "♭") {
+		if strings.HasPrefix(rest, "#") {
+			alter++
+			rest = rest[1:]
+		} else if strings.HasPrefix(rest, "♯") {
+			alter++
+			rest = strings.TrimPrefix(rest, "♯")
+		} else {
+			alter--
+			rest = strings.TrimPrefix(rest, "♭")
+		}
+	}
+
+	// Octave par défaut : celle du do central
+	octave, hasOctave := 4, false
+	if rest != "" {
+		value, err := strconv.Atoi(rest)
+		if err != nil {
+			return 0, false, fmt.Errorf("octave invalide dans '%s'", name)
+		}
+		octave, hasOctave = value, true
+	}
+
+	return (octave+1)*12 + step + alter, hasOctave, nil
+}
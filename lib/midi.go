@@ -0,0 +1,401 @@
+package lib
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"solfa-back/models"
+	"sort"
+)
+
+// Noms des instruments General MIDI, indexés par numéro de programme
+var gmInstruments = [128]string{
+	"Acoustic Grand Piano", "Bright Acoustic Piano", "Electric Grand Piano", "Honky-tonk Piano",
+	"Electric Piano 1", "Electric Piano 2", "Harpsichord", "Clavinet",
+	"Celesta", "Glockenspiel", "Music Box", "Vibraphone",
+	"Marimba", "Xylophone", "Tubular Bells", "Dulcimer",
+	"Drawbar Organ", "Percussive Organ", "Rock Organ", "Church Organ",
+	"Reed Organ", "Accordion", "Harmonica", "Tango Accordion",
+	"Acoustic Guitar (nylon)", "Acoustic Guitar (steel)", "Electric Guitar (jazz)", "Electric Guitar (clean)",
+	"Electric Guitar (muted)", "Overdriven Guitar", "Distortion Guitar", "Guitar Harmonics",
+	"Acoustic Bass", "Electric Bass (finger)", "Electric Bass (pick)", "Fretless Bass",
+	"Slap Bass 1", "Slap Bass 2", "Synth Bass 1", "Synth Bass 2",
+	"Violin", "Viola", "Cello", "Contrabass",
+	"Tremolo Strings", "Pizzicato Strings", "Orchestral Harp", "Timpani",
+	"String Ensemble 1", "String Ensemble 2", "Synth Strings 1", "Synth Strings 2",
+	"Choir Aahs", "Voice Oohs", "Synth Voice", "Orchestra Hit",
+	"Trumpet", "Trombone", "Tuba", "Muted Trumpet",
+	"French Horn", "Brass Section", "Synth Brass 1", "Synth Brass 2",
+	"Soprano Sax", "Alto Sax", "Tenor Sax", "Baritone Sax",
+	"Oboe", "English Horn", "Bassoon", "Clarinet",
+	"Piccolo", "Flute", "Recorder", "Pan Flute",
+	"Blown Bottle", "Shakuhachi", "Whistle", "Ocarina",
+	"Lead 1 (square)", "Lead 2 (sawtooth)", "Lead 3 (calliope)", "Lead 4 (chiff)",
+	"Lead 5 (charang)", "Lead 6 (voice)", "Lead 7 (fifths)", "Lead 8 (bass + lead)",
+	"Pad 1 (new age)", "Pad 2 (warm)", "Pad 3 (polysynth)", "Pad 4 (choir)",
+	"Pad 5 (bowed)", "Pad 6 (metallic)", "Pad 7 (halo)", "Pad 8 (sweep)",
+	"FX 1 (rain)", "FX 2 (soundtrack)", "FX 3 (crystal)", "FX 4 (atmosphere)",
+	"FX 5 (brightness)", "FX 6 (goblins)", "FX 7 (echoes)", "FX 8 (sci-fi)",
+	"Sitar", "Banjo", "Shamisen", "Koto",
+	"Kalimba", "Bagpipe", "Fiddle", "Shanai",
+	"Tinkle Bell", "Agogo", "Steel Drums", "Woodblock",
+	"Taiko Drum", "Melodic Tom", "Synth Drum", "Reverse Cymbal",
+	"Guitar Fret Noise", "Breath Noise", "Seashore", "Bird Tweet",
+	"Telephone Ring", "Helicopter", "Applause", "Gunshot",
+}
+
+// Tonalités indexées par nombre d'altérations (-7 à +7)
+var majorKeys = [15]string{"Cb", "Gb", "Db", "Ab", "Eb", "Bb", "F", "C", "G", "D", "A", "E", "B", "F#", "C#"}
+var minorKeys = [15]string{"Ab", "Eb", "Bb", "F", "C", "G", "D", "A", "E", "B", "F#", "C#", "G#", "D#", "A#"}
+
+// Tempo par défaut d'un fichier MIDI sans événement de tempo (120 BPM)
+const defaultMicrosecondsPerQuarter = 500000
+
+// midiEvent est un événement méta horodaté en ticks, collecté sur toutes les pistes
+type midiEvent struct {
+	tick  uint64
+	kind  byte
+	value []byte
+}
+
+// midiNoteOn est le début d'une note dans une piste
+type midiNoteOn struct {
+	tick  uint64
+	pitch int
+}
+
+// midiTrackData regroupe les informations lues dans une piste MTrk
+type midiTrackData struct {
+	track   models.Track
+	events  []midiEvent
+	notes   []midiNoteOn // Notes mélodiques (hors canal de percussion)
+	endTick uint64
+	lowest  int
+	highest int
+}
+
+// ParseMIDI analyse un fichier MIDI standard (SMF type 0 ou 1) et en extrait
+// la carte des tempos, les mesures, les armures, les pistes, la durée et l'ambitus
+func ParseMIDI(data []byte) (models.MusicMetadata, error) {
+	var metadata models.MusicMetadata
+
+	if len(data) < 14 || string(data[0:4]) != "MThd" {
+		return metadata, errors.New("en-tête MThd introuvable")
+	}
+	headerLength := binary.BigEndian.Uint32(data[4:8])
+	if headerLength < 6 || uint64(len(data)) < 8+uint64(headerLength) {
+		return metadata, errors.New("en-tête MThd tronqué")
+	}
+	format := binary.BigEndian.Uint16(data[8:10])
+	trackCount := binary.BigEndian.Uint16(data[10:12])
+	division := binary.BigEndian.Uint16(data[12:14])
+
+	if format > 1 {
+		return metadata, fmt.Errorf("format SMF %d non supporté", format)
+	}
+	if division == 0 {
+		return metadata, errors.New("division temporelle invalide")
+	}
+	// Division SMPTE : nombre d'images par seconde (négatif) et de ticks par image
+	if division&0x8000 != 0 && (-int8(division>>8) <= 0 || division&0xFF == 0) {
+		return metadata, errors.New("division temporelle SMPTE invalide")
+	}
+
+	var metaEvents []midiEvent
+	var melodyNotes []midiNoteOn
+	var lastTick uint64
+	lowest, highest := -1, -1
+
+	offset := 8 + int(headerLength)
+	for i := 0; i < int(trackCount); i++ {
+		if offset+8 > len(data) || string(data[offset:offset+4]) != "MTrk" {
+			return metadata, fmt.Errorf("piste %d introuvable", i+1)
+		}
+		length := int(binary.BigEndian.Uint32(data[offset+4 : offset+8]))
+		start := offset + 8
+		if start+length > len(data) {
+			return metadata, fmt.Errorf("piste %d tronquée", i+1)
+		}
+
+		trackData, err := parseMIDITrack(data[start : start+length])
+		if err != nil {
+			return metadata, fmt.Errorf("piste %d: %v", i+1, err)
+		}
+		metaEvents = append(metaEvents, trackData.events...)
+		if trackData.endTick > lastTick {
+			lastTick = trackData.endTick
+		}
+		if trackData.lowest >= 0 && (lowest < 0 || trackData.lowest < lowest) {
+			lowest = trackData.lowest
+		}
+		if trackData.highest > highest {
+			highest = trackData.highest
+		}
+		if trackData.track.Name != "" || trackData.track.Instrument != "" {
+			metadata.Tracks = append(metadata.Tracks, trackData.track)
+		}
+		// La mélodie est prise dans la première piste contenant des notes
+		if melodyNotes == nil && len(trackData.notes) > 0 {
+			melodyNotes = trackData.notes
+		}
+
+		offset = start + length
+	}
+
+	// Trier les événements méta de toutes les pistes par position
+	sort.SliceStable(metaEvents, func(a, b int) bool { return metaEvents[a].tick < metaEvents[b].tick })
+
+	// Conversion ticks -> secondes en tenant compte de la carte des tempos
+	toSeconds := midiTimeConverter(division, metaEvents)
+
+	for _, event := range metaEvents {
+		seconds := toSeconds(event.tick)
+		switch event.kind {
+		case 0x51:
+			tempo := uint32(event.value[0])<<16 | uint32(event.value[1])<<8 | uint32(event.value[2])
+			if tempo == 0 {
+				continue
+			}
+			metadata.TempoMap = append(metadata.TempoMap, models.TempoChange{Seconds: seconds, BPM: 60000000 / float64(tempo)})
+		case 0x58:
+			signature := fmt.Sprintf("%d/%d", event.value[0], 1<<event.value[1])
+			metadata.TimeSignatures = append(metadata.TimeSignatures, models.TimeSignatureChange{Seconds: seconds, Signature: signature})
+		case 0x59:
+			metadata.KeySignatures = append(metadata.KeySignatures, models.KeySignatureChange{Seconds: seconds, Key: midiKeyName(int8(event.value[0]), event.value[1])})
+		}
+	}
+
+	metadata.Duration = toSeconds(lastTick)
+	metadata.Tempo = 60000000 / float64(defaultMicrosecondsPerQuarter)
+	if len(metadata.TempoMap) > 0 && metadata.TempoMap[0].Seconds == 0 {
+		metadata.Tempo = metadata.TempoMap[0].BPM
+	}
+	if len(metadata.TimeSignatures) > 0 {
+		metadata.TimeSignature = metadata.TimeSignatures[0].Signature
+	}
+	if len(metadata.KeySignatures) > 0 {
+		metadata.KeySignature = metadata.KeySignatures[0].Key
+	}
+	if lowest >= 0 {
+		metadata.LowestPitch = lowest
+		metadata.HighestPitch = highest
+	}
+	melody := midiMelody(melodyNotes)
+	SetMelodyIncipit(&metadata, melody)
+	metadata.EstimatedDifficulty = EstimateDifficulty(metadata, melody)
+
+	return metadata, nil
+}
+
+// midiMelody réduit les notes d'une piste à une ligne mélodique,
+// en ne gardant que la note la plus aiguë parmi celles qui commencent ensemble
+func midiMelody(notes []midiNoteOn) []int {
+	var melody []int
+	for i := 0; i < len(notes); {
+		top := notes[i].pitch
+		j := i + 1
+		for j < len(notes) && notes[j].tick == notes[i].tick {
+			if notes[j].pitch > top {
+				top = notes[j].pitch
+			}
+			j++
+		}
+		melody = append(melody, top)
+		i = j
+	}
+	return melody
+}
+
+// parseMIDITrack parcourt les événements d'une piste MTrk
+func parseMIDITrack(data []byte) (midiTrackData, error) {
+	result := midiTrackData{lowest: -1, highest: -1}
+	track := &result.track
+	var tick uint64
+	var runningStatus byte
+
+	pos := 0
+	for pos < len(data) {
+		delta, n, err := readVarLen(data[pos:])
+		if err != nil {
+			return result, err
+		}
+		pos += n
+		tick += uint64(delta)
+
+		if pos >= len(data) {
+			return result, errors.New("événement tronqué")
+		}
+		status := data[pos]
+
+		switch {
+		case status == 0xFF:
+			// Événement méta
+			if pos+2 > len(data) {
+				return result, errors.New("événement méta tronqué")
+			}
+			kind := data[pos+1]
+			length, n, err := readVarLen(data[pos+2:])
+			if err != nil {
+				return result, err
+			}
+			start := pos + 2 + n
+			end := start + int(length)
+			if end > len(data) {
+				return result, errors.New("événement méta tronqué")
+			}
+			value := data[start:end]
+
+			switch kind {
+			case 0x03:
+				if track.Name == "" {
+					track.Name = string(value)
+				}
+			case 0x04:
+				if track.Instrument == "" {
+					track.Instrument = string(value)
+				}
+			case 0x51:
+				if len(value) == 3 {
+					result.events = append(result.events, midiEvent{tick: tick, kind: kind, value: value})
+				}
+			case 0x58:
+				if len(value) >= 2 {
+					result.events = append(result.events, midiEvent{tick: tick, kind: kind, value: value})
+				}
+			case 0x59:
+				if len(value) == 2 {
+					result.events = append(result.events, midiEvent{tick: tick, kind: kind, value: value})
+				}
+			}
+			pos = end
+			if kind == 0x2F {
+				// Fin de piste
+				result.endTick = tick
+				return result, nil
+			}
+
+		case status == 0xF0 || status == 0xF7:
+			// Événement SysEx : on l'ignore
+			length, n, err := readVarLen(data[pos+1:])
+			if err != nil {
+				return result, err
+			}
+			pos += 1 + n + int(length)
+			runningStatus = 0
+
+		default:
+			// Événement de canal, avec gestion du "running status"
+			if status&0x80 != 0 {
+				runningStatus = status
+				pos++
+			} else if runningStatus == 0 {
+				return result, errors.New("octet de statut manquant")
+			}
+
+			dataLength := 2
+			if runningStatus&0xF0 == 0xC0 || runningStatus&0xF0 == 0xD0 {
+				dataLength = 1
+			}
+			if pos+dataLength > len(data) {
+				return result, errors.New("événement de canal tronqué")
+			}
+
+			switch runningStatus & 0xF0 {
+			case 0x90:
+				// Note On (une vélocité nulle équivaut à un Note Off)
+				note, velocity := int(data[pos]), data[pos+1]
+				if velocity > 0 && runningStatus&0x0F != 9 {
+					if result.lowest < 0 || note < result.lowest {
+						result.lowest = note
+					}
+					if note > result.highest {
+						result.highest = note
+					}
+					result.notes = append(result.notes, midiNoteOn{tick: tick, pitch: note})
+				}
+			case 0xC0:
+				if track.Instrument == "" {
+					if runningStatus&0x0F == 9 {
+						track.Instrument = "Percussion"
+					} else {
+						track.Instrument = gmInstruments[data[pos]&0x7F]
+					}
+				}
+			}
+			pos += dataLength
+		}
+	}
+
+	// Piste sans événement de fin : on la considère tout de même
+	result.endTick = tick
+	return result, nil
+}
+
+// readVarLen lit une quantité de longueur variable (VLQ) MIDI
+func readVarLen(data []byte) (uint32, int, error) {
+	var value uint32
+	for i := 0; i < 4; i++ {
+		if i >= len(data) {
+			return 0, 0, errors.New("quantité de longueur variable tronquée")
+		}
+		value = value<<7 | uint32(data[i]&0x7F)
+		if data[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+	return 0, 0, errors.New("quantité de longueur variable invalide")
+}
+
+// midiTimeConverter retourne une fonction de conversion ticks -> secondes
+func midiTimeConverter(division uint16, events []midiEvent) func(uint64) float64 {
+	// Division SMPTE : nombre fixe de ticks par seconde
+	if division&0x8000 != 0 {
+		framesPerSecond := float64(-int8(division >> 8))
+		ticksPerFrame := float64(division & 0xFF)
+		return func(tick uint64) float64 {
+			return float64(tick) / (framesPerSecond * ticksPerFrame)
+		}
+	}
+
+	ticksPerQuarter := float64(division)
+	type segment struct {
+		tick     uint64
+		seconds  float64
+		perQuart float64 // Microsecondes par noire
+	}
+	segments := []segment{{tick: 0, seconds: 0, perQuart: defaultMicrosecondsPerQuarter}}
+	for _, event := range events {
+		if event.kind != 0x51 {
+			continue
+		}
+		tempo := float64(uint32(event.value[0])<<16 | uint32(event.value[1])<<8 | uint32(event.value[2]))
+		if tempo == 0 {
+			continue
+		}
+		last := segments[len(segments)-1]
+		seconds := last.seconds + float64(event.tick-last.tick)/ticksPerQuarter*last.perQuart/1e6
+		segments = append(segments, segment{tick: event.tick, seconds: seconds, perQuart: tempo})
+	}
+
+	return func(tick uint64) float64 {
+		current := segments[0]
+		for _, s := range segments[1:] {
+			if s.tick > tick {
+				break
+			}
+			current = s
+		}
+		return current.seconds + float64(tick-current.tick)/ticksPerQuarter*current.perQuart/1e6
+	}
+}
+
+// midiKeyName convertit un événement d'armure MIDI en nom de tonalité
+func midiKeyName(accidentals int8, minor byte) string {
+	if accidentals < -7 || accidentals > 7 {
+		return ""
+	}
+	if minor == 1 {
+		return minorKeys[accidentals+7] + " minor"
+	}
+	return majorKeys[accidentals+7] + " major"
+}
@@ -1,5 +1,8 @@
 package lib
 import (
+	"bytes"
+	"context"
+	"io"
 	"os"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -19,3 +22,28 @@ func InitMC() {
 		fmt.Println("Erreur lors de la connexion à Minio", err)
 	}
 }
+
+// GetObjectContent lit entièrement un objet du bucket "solfa"
+func GetObjectContent(ctx context.Context, path string) ([]byte, error) {
+	object, err := MinioClient.GetObject(ctx, "solfa", path, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	return io.ReadAll(object)
+}
+
+// PutObjectContent enregistre un contenu dans le bucket "solfa"
+func PutObjectContent(ctx context.Context, path string, content []byte, contentType string) error {
+	_, err := MinioClient.PutObject(ctx, "solfa", path, bytes.NewReader(content), int64(len(content)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+// ObjectExists indique si un objet est présent dans le bucket "solfa"
+func ObjectExists(ctx context.Context, path string) bool {
+	_, err := MinioClient.StatObject(ctx, "solfa", path, minio.StatObjectOptions{})
+	return err == nil
+}
@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"os"
+	"solfa-back/models"
+	"strconv"
+)
+
+// Nombre de signalements ouverts par défaut au-delà duquel une partition validée repasse en revue
+const defaultReportDemotionThreshold = 3
+
+// ReportDemotionThreshold retourne le nombre de signalements ouverts entraînant le retour
+// d'une partition validée en revue (variable d'environnement REPORT_DEMOTION_THRESHOLD)
+func ReportDemotionThreshold() int {
+	if threshold, err := strconv.Atoi(os.Getenv("REPORT_DEMOTION_THRESHOLD")); err == nil && threshold > 0 {
+		return threshold
+	}
+	return defaultReportDemotionThreshold
+}
+
+// DemotePartitionIfReported repasse une partition validée en staging lorsqu'elle
+// atteint le seuil de signalements ouverts ; retourne true si elle a été rétrogradée
+func DemotePartitionIfReported(partitionID uint) (bool, error) {
+	var openReports int64
+	if err := DB.Model(&models.Report{}).
+		Where("partition_id = ? AND status IN ?", partitionID, []string{models.ReportOpen, models.ReportInReview}).
+		Count(&openReports).Error; err != nil {
+		return false, err
+	}
+	if int(openReports) < ReportDemotionThreshold() {
+		return false, nil
+	}
+
+	result := DB.Model(&models.Partition{}).
+		Where("id = ? AND status = ?", partitionID, "validated").
+		Updates(map[string]interface{}{"status": "staging", "validated_by": ""})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	return true, UpdatePartitionFieldsInES(partitionID, map[string]interface{}{"status": "staging", "validated_by": ""})
+}
@@ -0,0 +1,686 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"solfa-back/models"
+	"strconv"
+	"strings"
+)
+
+// Structures MusicXML (format partwise) limitées aux éléments utilisés par l'application
+
+type MusicXMLScore struct {
+	XMLName        xml.Name                `xml:"score-partwise"`
+	Version        string                  `xml:"version,attr,omitempty"`
+	Work           *MusicXMLWork           `xml:"work,omitempty"`
+	MovementTitle  string                  `xml:"movement-title,omitempty"`
+	Identification *MusicXMLIdentification `xml:"identification,omitempty"`
+	PartList       MusicXMLPartList        `xml:"part-list"`
+	Parts          []MusicXMLPart          `xml:"part"`
+}
+
+type MusicXMLWork struct {
+	Title string `xml:"work-title,omitempty"`
+}
+
+type MusicXMLIdentification struct {
+	Creators []MusicXMLCreator `xml:"creator"`
+	Encoding *MusicXMLEncoding `xml:"encoding,omitempty"`
+}
+
+type MusicXMLCreator struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type MusicXMLEncoding struct {
+	Software string `xml:"software,omitempty"`
+}
+
+type MusicXMLPartList struct {
+	ScoreParts []MusicXMLScorePart `xml:"score-part"`
+}
+
+type MusicXMLScorePart struct {
+	ID          string                    `xml:"id,attr"`
+	Name        string                    `xml:"part-name"`
+	Instruments []MusicXMLScoreInstrument `xml:"score-instrument"`
+}
+
+type MusicXMLScoreInstrument struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"instrument-name"`
+}
+
+type MusicXMLPart struct {
+	ID       string            `xml:"id,attr"`
+	Measures []MusicXMLMeasure `xml:"measure"`
+}
+
+type MusicXMLMeasure struct {
+	Number     string              `xml:"number,attr"`
+	Attributes *MusicXMLAttributes `xml:"attributes,omitempty"`
+	Directions []MusicXMLDirection `xml:"direction"`
+	Notes      []MusicXMLNote      `xml:"note"`
+}
+
+type MusicXMLDirection struct {
+	Sound *MusicXMLSound `xml:"sound,omitempty"`
+}
+
+type MusicXMLSound struct {
+	Tempo string `xml:"tempo,attr,omitempty"`
+}
+
+type MusicXMLAttributes struct {
+	Divisions int           `xml:"divisions,omitempty"`
+	Key       *MusicXMLKey  `xml:"key,omitempty"`
+	Time      *MusicXMLTime `xml:"time,omitempty"`
+	Clef      *MusicXMLClef `xml:"clef,omitempty"`
+}
+
+type MusicXMLKey struct {
+	Fifths int    `xml:"fifths"`
+	Mode   string `xml:"mode,omitempty"`
+}
+
+type MusicXMLTime struct {
+	Beats    string `xml:"beats"`
+	BeatType string `xml:"beat-type"`
+}
+
+type MusicXMLClef struct {
+	Sign string `xml:"sign"`
+	Line int    `xml:"line"`
+}
+
+type MusicXMLNote struct {
+	Grace     *struct{}          `xml:"grace"`
+	Chord     *struct{}          `xml:"chord"`
+	Pitch     *MusicXMLPitch     `xml:"pitch"`
+	Rest      *struct{}          `xml:"rest"`
+	Duration  int                `xml:"duration,omitempty"`
+	Ties      []MusicXMLTie      `xml:"tie"`
+	Voice     string             `xml:"voice,omitempty"`
+	Type      string             `xml:"type,omitempty"`
+	Dots      []struct{}         `xml:"dot"`
+	Notations *MusicXMLNotations `xml:"notations,omitempty"`
+	Lyrics    []MusicXMLLyric    `xml:"lyric"`
+}
+
+type MusicXMLLyric struct {
+	Number   string   `xml:"number,attr,omitempty"`
+	Language string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Syllabic string   `xml:"syllabic,omitempty"`
+	Texts    []string `xml:"text"`
+}
+
+type MusicXMLPitch struct {
+	Step   string  `xml:"step"`
+	Alter  float64 `xml:"alter,omitempty"`
+	Octave int     `xml:"octave"`
+}
+
+type MusicXMLTie struct {
+	Type string `xml:"type,attr"`
+}
+
+type MusicXMLNotations struct {
+	Tied []MusicXMLTie `xml:"tied"`
+}
+
+// En-tête des documents MusicXML générés
+const musicXMLHeader = xml.Header + `<!DOCTYPE score-partwise PUBLIC "-//Recordare//DTD MusicXML 4.0 Partwise//EN" "http://www.musicxml.org/dtds/partwise.dtd">` + "\n"
+
+// MarshalMusicXML sérialise une partition MusicXML avec son en-tête
+func MarshalMusicXML(score MusicXMLScore) ([]byte, error) {
+	body, err := xml.MarshalIndent(score, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(musicXMLHeader), body...), nil
+}
+
+// ConvertABCToMusicXML convertit un morceau ABC en document MusicXML à une partie
+func ConvertABCToMusicXML(tune ABCTune) ([]byte, error) {
+	measures, err := tune.Measures()
+	if err != nil {
+		return nil, err
+	}
+
+	score := MusicXMLScore{
+		Version: "4.0",
+		Work:    &MusicXMLWork{Title: tune.Title},
+		Identification: &MusicXMLIdentification{
+			Encoding: &MusicXMLEncoding{Software: "solfa-back"},
+		},
+		PartList: MusicXMLPartList{ScoreParts: []MusicXMLScorePart{{ID: "P1", Name: "Music"}}},
+	}
+	if tune.Composer != "" {
+		score.Identification.Creators = []MusicXMLCreator{{Type: "composer", Value: tune.Composer}}
+	}
+
+	part := MusicXMLPart{ID: "P1"}
+	tiedFromPrevious := false
+	currentFifths := 0
+	for i, measure := range measures {
+		xmlMeasure := MusicXMLMeasure{Number: fmt.Sprint(i + 1)}
+
+		key, meter := measure.Key, measure.Meter
+		if i == 0 {
+			key, meter = tune.Key, tune.Meter
+		}
+		if i == 0 || key != "" || meter != "" {
+			attributes := &MusicXMLAttributes{}
+			if i == 0 {
+				attributes.Divisions = ABCDivisions
+				attributes.Clef = &MusicXMLClef{Sign: "G", Line: 2}
+			}
+			if i == 0 || key != "" {
+				fifths, mode := abcKeyFifths(key)
+				currentFifths = fifths
+				attributes.Key = &MusicXMLKey{Fifths: fifths, Mode: mode}
+			}
+			if signature := abcMeterSignature(meter); signature != "" {
+				parts := strings.SplitN(signature, "/", 2)
+				attributes.Time = &MusicXMLTime{Beats: parts[0], BeatType: parts[1]}
+			}
+			xmlMeasure.Attributes = attributes
+		}
+
+		for _, note := range measure.Notes {
+			noteType, dots := musicXMLNoteType(note.Duration, ABCDivisions)
+
+			if len(note.Pitches) == 0 {
+				xmlMeasure.Notes = append(xmlMeasure.Notes, MusicXMLNote{
+					Rest:     &struct{}{},
+					Duration: note.Duration,
+					Voice:    "1",
+					Type:     noteType,
+					Dots:     make([]struct{}, dots),
+				})
+				tiedFromPrevious = false
+				continue
+			}
+
+			for j, pitch := range note.Pitches {
+				xmlNote := MusicXMLNote{
+					Pitch:    midiToMusicXMLPitch(pitch, currentFifths < 0),
+					Duration: note.Duration,
+					Voice:    "1",
+					Type:     noteType,
+					Dots:     make([]struct{}, dots),
+				}
+				if j > 0 {
+					xmlNote.Chord = &struct{}{}
+				}
+				var ties []MusicXMLTie
+				if tiedFromPrevious {
+					ties = append(ties, MusicXMLTie{Type: "stop"})
+				}
+				if note.TieStart {
+					ties = append(ties, MusicXMLTie{Type: "start"})
+				}
+				if len(ties) > 0 {
+					xmlNote.Ties = ties
+					xmlNote.Notations = &MusicXMLNotations{Tied: ties}
+				}
+				xmlMeasure.Notes = append(xmlMeasure.Notes, xmlNote)
+			}
+			tiedFromPrevious = note.TieStart
+		}
+
+		part.Measures = append(part.Measures, xmlMeasure)
+	}
+	score.Parts = []MusicXMLPart{part}
+
+	return MarshalMusicXML(score)
+}
+
+// midiToMusicXMLPitch convertit un numéro de note MIDI en hauteur MusicXML,
+// en utilisant des bémols dans les tonalités bémolisées et des dièses sinon
+func midiToMusicXMLPitch(pitch int, preferFlats bool) *MusicXMLPitch {
+	type spelling struct {
+		step  string
+		alter int
+	}
+	steps := []spelling{
+		{"C", 0}, {"C", 1}, {"D", 0}, {"D", 1}, {"E", 0}, {"F", 0},
+		{"F", 1}, {"G", 0}, {"G", 1}, {"A", 0}, {"A", 1}, {"B", 0},
+	}
+	if preferFlats {
+		steps = []spelling{
+			{"C", 0}, {"D", -1}, {"D", 0}, {"E", -1}, {"E", 0}, {"F", 0},
+			{"G", -1}, {"G", 0}, {"A", -1}, {"A", 0}, {"B", -1}, {"B", 0},
+		}
+	}
+	s := steps[((pitch%12)+12)%12]
+	return &MusicXMLPitch{Step: s.step, Alter: float64(s.alter), Octave: pitch/12 - 1}
+}
+
+// musicXMLNoteType retourne le type graphique et le nombre de points correspondant à une durée
+func musicXMLNoteType(duration int, divisions int) (string, int) {
+	types := []struct {
+		name     string
+		quarters float64
+	}{
+		{"breve", 8}, {"whole", 4}, {"half", 2}, {"quarter", 1}, {"eighth", 0.5},
+		{"16th", 0.25}, {"32nd", 0.125}, {"64th", 0.0625},
+	}
+	for _, t := range types {
+		base := t.quarters * float64(divisions)
+		for dots, factor := range []float64{1, 1.5, 1.75} {
+			if float64(duration) == base*factor {
+				return t.name, dots
+			}
+		}
+	}
+	// Durée irrégulière (n-olet) : on prend le type immédiatement supérieur
+	for i := len(types) - 1; i >= 0; i-- {
+		if float64(duration) < types[i].quarters*float64(divisions) {
+			return types[i].name, 0
+		}
+	}
+	return "", 0
+}
+
+// ParseMusicXML lit un document MusicXML au format partwise
+func ParseMusicXML(content []byte) (MusicXMLScore, error) {
+	var score MusicXMLScore
+	if bytes.Contains(content, []byte("<score-timewise")) {
+		return score, errors.New("format MusicXML timewise non supporté")
+	}
+	if err := xml.Unmarshal(content, &score); err != nil {
+		return score, err
+	}
+	if len(score.Parts) == 0 {
+		return score, errors.New("aucune partie trouvée")
+	}
+	return score, nil
+}
+
+// Title retourne le titre de l'œuvre (ou du mouvement)
+func (score MusicXMLScore) Title() string {
+	if score.Work != nil && score.Work.Title != "" {
+		return strings.TrimSpace(score.Work.Title)
+	}
+	return strings.TrimSpace(score.MovementTitle)
+}
+
+// Composer retourne le compositeur déclaré dans l'identification
+func (score MusicXMLScore) Composer() string {
+	if score.Identification == nil {
+		return ""
+	}
+	for _, creator := range score.Identification.Creators {
+		if creator.Type == "composer" {
+			return strings.TrimSpace(creator.Value)
+		}
+	}
+	return ""
+}
+
+// MIDIPitch convertit une hauteur MusicXML en numéro de note MIDI
+func (pitch MusicXMLPitch) MIDIPitch() int {
+	steps := map[string]int{"C": 0, "D": 2, "E": 4, "F": 5, "G": 7, "A": 9, "B": 11}
+	return (pitch.Octave+1)*12 + steps[strings.ToUpper(strings.TrimSpace(pitch.Step))] + int(math.Round(pitch.Alter))
+}
+
+// Metadata calcule les métadonnées musicales d'une partition MusicXML
+func (score MusicXMLScore) Metadata() models.MusicMetadata {
+	var metadata models.MusicMetadata
+
+	for _, scorePart := range score.PartList.ScoreParts {
+		track := models.Track{Name: strings.TrimSpace(scorePart.Name)}
+		if len(scorePart.Instruments) > 0 {
+			track.Instrument = strings.TrimSpace(scorePart.Instruments[0].Name)
+		}
+		metadata.Tracks = append(metadata.Tracks, track)
+	}
+
+	// Tonalité, mesure et tempo sont lus dans la première partie
+	first := score.Parts[0]
+	divisions := 1
+	seconds := 0.0
+	tempo := 0.0
+	for _, measure := range first.Measures {
+		if attributes := measure.Attributes; attributes != nil {
+			if attributes.Divisions > 0 {
+				divisions = attributes.Divisions
+			}
+			if attributes.Key != nil {
+				key := musicXMLKeyName(attributes.Key)
+				if key != "" && (len(metadata.KeySignatures) == 0 || metadata.KeySignatures[len(metadata.KeySignatures)-1].Key != key) {
+					metadata.KeySignatures = append(metadata.KeySignatures, models.KeySignatureChange{Seconds: seconds, Key: key})
+				}
+			}
+			if attributes.Time != nil && attributes.Time.Beats != "" {
+				signature := attributes.Time.Beats + "/" + attributes.Time.BeatType
+				metadata.TimeSignatures = append(metadata.TimeSignatures, models.TimeSignatureChange{Seconds: seconds, Signature: signature})
+			}
+		}
+		for _, direction := range measure.Directions {
+			if direction.Sound == nil || direction.Sound.Tempo == "" {
+				continue
+			}
+			if bpm, err := strconv.ParseFloat(direction.Sound.Tempo, 64); err == nil && bpm > 0 {
+				tempo = bpm
+				metadata.TempoMap = append(metadata.TempoMap, models.TempoChange{Seconds: seconds, BPM: bpm})
+			}
+		}
+
+		// Durée de la mesure : somme des notes de la première voix rencontrée
+		measureDivisions := 0
+		voice := ""
+		for _, note := range measure.Notes {
+			if note.Grace != nil || note.Chord != nil {
+				continue
+			}
+			if voice == "" {
+				voice = note.Voice
+			}
+			if note.Voice == voice {
+				measureDivisions += note.Duration
+			}
+		}
+		currentTempo := tempo
+		if currentTempo == 0 {
+			currentTempo = 120
+		}
+		seconds += float64(measureDivisions) / float64(divisions) * 60 / currentTempo
+	}
+	metadata.Duration = math.Round(seconds*100) / 100
+
+	if len(metadata.TimeSignatures) > 0 {
+		metadata.TimeSignature = metadata.TimeSignatures[0].Signature
+	}
+	if len(metadata.KeySignatures) > 0 {
+		metadata.KeySignature = metadata.KeySignatures[0].Key
+	}
+	if len(metadata.TempoMap) > 0 {
+		metadata.Tempo = metadata.TempoMap[0].BPM
+	}
+
+	// Ambitus sur l'ensemble des parties
+	lowest, highest := -1, -1
+	for _, part := range score.Parts {
+		for _, measure := range part.Measures {
+			for _, note := range measure.Notes {
+				if note.Pitch == nil {
+					continue
+				}
+				pitch := note.Pitch.MIDIPitch()
+				if lowest < 0 || pitch < lowest {
+					lowest = pitch
+				}
+				if pitch > highest {
+					highest = pitch
+				}
+			}
+		}
+	}
+	if lowest >= 0 {
+		metadata.LowestPitch = lowest
+		metadata.HighestPitch = highest
+	}
+
+	melody := first.Melody()
+	SetMelodyIncipit(&metadata, melody)
+	metadata.EstimatedDifficulty = EstimateDifficulty(metadata, melody)
+	return metadata
+}
+
+// Melody extrait la ligne mélodique d'une partie : première voix, note la plus aiguë
+// des accords, sans silences, notes d'agrément ni notes prolongées par une liaison
+func (part MusicXMLPart) Melody() []int {
+	var melody []int
+	voice := ""
+	for _, measure := range part.Measures {
+		for _, note := range measure.Notes {
+			if note.Grace != nil {
+				continue
+			}
+			if voice == "" {
+				voice = note.Voice
+			}
+			if note.Voice != voice || note.Pitch == nil {
+				continue
+			}
+			tieStop := false
+			for _, tie := range note.Ties {
+				if tie.Type == "stop" {
+					tieStop = true
+				}
+			}
+
+			pitch := note.Pitch.MIDIPitch()
+			if note.Chord != nil {
+				// Note d'un accord : elle remplace la précédente si elle est plus aiguë
+				if len(melody) > 0 && pitch > melody[len(melody)-1] && !tieStop {
+					melody[len(melody)-1] = pitch
+				}
+				continue
+			}
+			if !tieStop {
+				melody = append(melody, pitch)
+			}
+		}
+	}
+	return melody
+}
+
+// musicXMLKeyName retourne un nom de tonalité au même format que pour le MIDI ("D major")
+func musicXMLKeyName(key *MusicXMLKey) string {
+	if key.Fifths < -7 || key.Fifths > 7 {
+		return ""
+	}
+	if key.Mode == "minor" {
+		return minorKeys[key.Fifths+7] + " minor"
+	}
+	return majorKeys[key.Fifths+7] + " major"
+}
+
+// Lyrics extrait les paroles de la partition, par partie, voix et couplet,
+// en réunissant les syllabes d'un même mot
+func (score MusicXMLScore) Lyrics() []models.PartitionLyric {
+	partNames := map[string]string{}
+	for _, scorePart := range score.PartList.ScoreParts {
+		partNames[scorePart.ID] = strings.TrimSpace(scorePart.Name)
+	}
+
+	type lyricKey struct {
+		voice string
+		verse string
+	}
+
+	var lyrics []models.PartitionLyric
+	for _, part := range score.Parts {
+		builders := map[lyricKey]*strings.Builder{}
+		var order []lyricKey
+		voices := map[string]bool{}
+
+		for _, measure := range part.Measures {
+			for _, note := range measure.Notes {
+				for _, lyric := range note.Lyrics {
+					text := strings.TrimSpace(strings.Join(lyric.Texts, " "))
+					if text == "" {
+						continue
+					}
+					key := lyricKey{voice: note.Voice, verse: lyric.Number}
+					builder, ok := builders[key]
+					if !ok {
+						builder = &strings.Builder{}
+						builders[key] = builder
+						order = append(order, key)
+						voices[note.Voice] = true
+					}
+					builder.WriteString(text)
+					// Une syllabe de début ou de milieu de mot est collée à la suivante
+					if lyric.Syllabic != "begin" && lyric.Syllabic != "middle" {
+						builder.WriteString(" ")
+					}
+				}
+			}
+		}
+
+		name := partNames[part.ID]
+		if name == "" {
+			name = part.ID
+		}
+		for i, key := range order {
+			voice := name
+			if len(voices) > 1 {
+				voice = fmt.Sprintf("%s (voix %s)", name, key.voice)
+			}
+			verse, err := strconv.Atoi(strings.TrimLeft(key.verse, "abcdefghijklmnopqrstuvwxyz"))
+			if err != nil || verse < 1 {
+				verse = i + 1
+			}
+			lyrics = append(lyrics, models.PartitionLyric{
+				Voice: voice,
+				Verse: verse,
+				Text:  strings.TrimSpace(builders[key].String()),
+			})
+		}
+	}
+	return lyrics
+}
+
+// LyricsLanguages retourne les langues déclarées des paroles (attribut xml:lang), telles qu'écrites dans le fichier
+func (score MusicXMLScore) LyricsLanguages() []string {
+	var declared []string
+	for _, part := range score.Parts {
+		for _, measure := range part.Measures {
+			for _, note := range measure.Notes {
+				for _, lyric := range note.Lyrics {
+					if lyric.Language != "" && !slices.Contains(declared, lyric.Language) {
+						declared = append(declared, lyric.Language)
+					}
+				}
+			}
+		}
+	}
+	return declared
+}
+
+// FindPart retrouve une partie par son identifiant ("P2") ou son nom ("Tenor"), sans tenir compte de la casse
+func (score MusicXMLScore) FindPart(reference string) (MusicXMLScorePart, bool) {
+	reference = strings.TrimSpace(reference)
+	for _, scorePart := range score.PartList.ScoreParts {
+		if scorePart.ID == reference {
+			return scorePart, true
+		}
+	}
+	for _, scorePart := range score.PartList.ScoreParts {
+		if strings.EqualFold(strings.TrimSpace(scorePart.Name), reference) {
+			return scorePart, true
+		}
+	}
+	return MusicXMLScorePart{}, false
+}
+
+// ExtractMusicXMLPart produit un document MusicXML ne contenant que la partie demandée.
+// Le document d'origine est recopié tel quel, seules les autres parties (et les
+// groupes de parties) en sont retirées, afin de ne perdre aucune information.
+func ExtractMusicXMLPart(content []byte, partID string) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+
+	type byteRange struct{ start, end int64 }
+	var removed []byteRange
+	var path []string
+	found := false
+
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			name := element.Name.Local
+			parent := ""
+			if len(path) > 0 {
+				parent = path[len(path)-1]
+			}
+
+			remove := false
+			if (name == "score-part" && parent == "part-list") || (name == "part" && parent == "score-partwise") {
+				if xmlAttribute(element, "id") == partID {
+					found = true
+				} else {
+					remove = true
+				}
+			}
+			if name == "part-group" && parent == "part-list" {
+				remove = true
+			}
+
+			if remove {
+				if err := skipRawElement(decoder); err != nil {
+					return nil, err
+				}
+				removed = append(removed, byteRange{start: offset, end: decoder.InputOffset()})
+				continue
+			}
+			path = append(path, name)
+
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("partie '%s' introuvable", partID)
+	}
+
+	var output bytes.Buffer
+	var position int64
+	for _, r := range removed {
+		output.Write(content[position:r.start])
+		position = r.end
+	}
+	output.Write(content[position:])
+	return output.Bytes(), nil
+}
+
+// skipRawElement avance jusqu'à la fin de l'élément dont la balise ouvrante vient d'être lue
+func skipRawElement(decoder *xml.Decoder) error {
+	depth := 1
+	for depth > 0 {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return err
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return nil
+}
+
+// xmlAttribute retourne la valeur d'un attribut d'un élément XML
+func xmlAttribute(element xml.StartElement, name string) string {
+	for _, attribute := range element.Attr {
+		if attribute.Name.Local == name {
+			return attribute.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,108 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"solfa-back/models"
+	"time"
+)
+
+// Durée de validité d'une invitation à rejoindre une organisation
+const OrganizationInvitationValidity = 14 * 24 * time.Hour
+
+// Rang des rôles d'une organisation : un rôle comprend les droits des rôles de rang inférieur
+var organizationRoleRanks = map[string]int{
+	models.OrganizationRoleMember:   1,
+	models.OrganizationRoleDirector: 2,
+	models.OrganizationRoleOwner:    3,
+}
+
+// HasOrganizationRole indique si un rôle comprend les droits du rôle minimal demandé
+func HasOrganizationRole(role, minimum string) bool {
+	return organizationRoleRanks[role] > 0 && organizationRoleRanks[role] >= organizationRoleRanks[minimum]
+}
+
+// OrganizationRole retourne le rôle d'un utilisateur dans une organisation, ou une chaîne
+// vide s'il n'en est pas membre (ou s'il est anonyme)
+func OrganizationRole(organizationID, userID uint) (string, error) {
+	if userID == 0 {
+		return "", nil
+	}
+	var member models.OrganizationMember
+	err := DB.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return member.Role, err
+}
+
+// UserOrganizationIDs retourne les organisations dont un utilisateur est membre
+func UserOrganizationIDs(userID uint) ([]uint, error) {
+	ids := []uint{}
+	if userID == 0 {
+		return ids, nil
+	}
+	err := DB.Model(&models.OrganizationMember{}).Where("user_id = ?", userID).Pluck("organization_id", &ids).Error
+	return ids, err
+}
+
+// VisiblePartitions restreint une requête aux partitions du catalogue public et des
+// bibliothèques privées des organisations données
+func VisiblePartitions(organizationIDs []uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(organizationIDs) == 0 {
+			return db.Where("partitions.organization_id IS NULL")
+		}
+		return db.Where("partitions.organization_id IS NULL OR partitions.organization_id IN ?", organizationIDs)
+	}
+}
+
+// CanViewPartition indique si une partition est visible par un utilisateur (0 si anonyme) :
+// partition du catalogue public, ou de la bibliothèque d'une organisation dont il est membre
+func CanViewPartition(partition models.Partition, userID uint) (bool, error) {
+	if partition.OrganizationID == nil {
+		return true, nil
+	}
+	role, err := OrganizationRole(*partition.OrganizationID, userID)
+	return role != "", err
+}
+
+// WatermarkOrganization retourne l'organisation imprimée dans le filigrane d'une copie :
+// celle de la bibliothèque ou de la collection copiée, sinon la première organisation
+// rejointe par l'utilisateur ("" si aucune)
+func WatermarkOrganization(organizationID *uint, userID uint) string {
+	var organization models.Organization
+	query := DB.Select("organizations.name")
+	if organizationID != nil {
+		query = query.Where("id = ?", *organizationID)
+	} else if userID != 0 {
+		query = query.Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+			Where("organization_members.user_id = ?", userID).
+			Order("organization_members.created_at")
+	} else {
+		return ""
+	}
+	if err := query.First(&organization).Error; err != nil {
+		return ""
+	}
+	return organization.Name
+}
+
+// CountOrganizationOwners retourne le nombre de propriétaires d'une organisation
+func CountOrganizationOwners(organizationID uint) (int64, error) {
+	var count int64
+	err := DB.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role = ?", organizationID, models.OrganizationRoleOwner).
+		Count(&count).Error
+	return count, err
+}
+
+// SendOrganizationInvitation envoie par email le lien d'une invitation à rejoindre une organisation
+func SendOrganizationInvitation(invitation models.OrganizationInvitation, organization models.Organization, inviter string) error {
+	link := fmt.Sprintf("%s/invitations/%s", AppURL(), invitation.Token)
+	body := fmt.Sprintf("%s vous invite à rejoindre « %s » sur Solfa.\n\n"+
+		"Acceptez l'invitation en suivant ce lien (valable jusqu'au %s) :\n%s\n",
+		inviter, organization.Name, invitation.ExpiresAt.Format("02/01/2006"), link)
+	return SendMail(invitation.Email, "Invitation à rejoindre "+organization.Name, body)
+}
@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/ledongthuc/pdf"
+	"math"
+	"solfa-back/models"
+	"strings"
+)
+
+// Erreurs renvoyées lors de l'analyse d'un PDF
+var (
+	ErrPDFEncrypted = errors.New("le PDF est chiffré ou protégé par un mot de passe")
+	ErrPDFCorrupted = errors.New("le PDF est corrompu ou illisible")
+)
+
+// Formats de page courants, en points (portrait)
+var pageSizes = []struct {
+	name          string
+	width, height float64
+}{
+	{"A3", 842, 1191},
+	{"A4", 595, 842},
+	{"A5", 420, 595},
+	{"B4", 709, 1001},
+	{"B5", 499, 709},
+	{"Letter", 612, 792},
+	{"Legal", 612, 1008},
+	{"Tabloid", 792, 1224},
+}
+
+// Nombre de pages examinées pour détecter une couche texte
+const textLayerPagesToCheck = 5
+
+// AnalyzePDF lit un PDF et en extrait le nombre de pages, le format de page,
+// les métadonnées (titre, auteur) et la présence d'une couche texte
+func AnalyzePDF(content []byte) (metadata models.DocumentMetadata, err error) {
+	// La bibliothèque PDF signale certaines erreurs de structure par un panic
+	defer func() {
+		if r := recover(); r != nil {
+			metadata = models.DocumentMetadata{}
+			err = fmt.Errorf("%w: %v", ErrPDFCorrupted, r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(pdfHeaderCompat(content), int64(len(content)))
+	if err != nil {
+		if err == pdf.ErrInvalidPassword || strings.Contains(err.Error(), "encrypt") {
+			return metadata, ErrPDFEncrypted
+		}
+		return metadata, fmt.Errorf("%w: %v", ErrPDFCorrupted, err)
+	}
+	if !reader.Trailer().Key("Encrypt").IsNull() {
+		return metadata, ErrPDFEncrypted
+	}
+
+	metadata.PageCount = reader.NumPage()
+	if metadata.PageCount <= 0 {
+		return metadata, fmt.Errorf("%w: aucune page", ErrPDFCorrupted)
+	}
+
+	firstPage := reader.Page(1)
+	if firstPage.V.IsNull() {
+		return metadata, fmt.Errorf("%w: première page introuvable", ErrPDFCorrupted)
+	}
+	metadata.PageWidth, metadata.PageHeight = pdfPageDimensions(firstPage)
+	metadata.PageSize = pdfPageSizeName(metadata.PageWidth, metadata.PageHeight)
+
+	info := reader.Trailer().Key("Info")
+	metadata.DocumentTitle = strings.TrimSpace(info.Key("Title").Text())
+	metadata.DocumentAuthor = strings.TrimSpace(info.Key("Author").Text())
+
+	// Une partition scannée ne contient que des images : aucune police sur ses pages
+	for i := 1; i <= metadata.PageCount && i <= textLayerPagesToCheck; i++ {
+		page := reader.Page(i)
+		if len(page.Fonts()) > 0 && len(page.Content().Text) > 0 {
+			metadata.HasTextLayer = true
+			break
+		}
+	}
+
+	return metadata, nil
+}
+
+// PageDimension est la taille d'une page telle qu'elle est affichée, en points
+type PageDimension struct {
+	Width  float64
+	Height float64
+}
+
+// PDFPageDimensions retourne la taille de chacune des pages d'un PDF
+func PDFPageDimensions(content []byte) (dimensions []PageDimension, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			dimensions = nil
+			err = fmt.Errorf("%w: %v", ErrPDFCorrupted, r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(pdfHeaderCompat(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPDFCorrupted, err)
+	}
+	for i := 1; i <= reader.NumPage(); i++ {
+		width, height := pdfPageDimensions(reader.Page(i))
+		if width == 0 || height == 0 {
+			// Taille par défaut d'une page sans MediaBox lisible
+			width, height = 595, 842
+		}
+		dimensions = append(dimensions, PageDimension{Width: width, Height: height})
+	}
+	return dimensions, nil
+}
+
+// pdfHeaderCompat présente les PDF 2.0 comme des PDF 1.7, seule version
+// acceptée par la bibliothèque de lecture (la structure de fichier est identique)
+func pdfHeaderCompat(content []byte) *bytes.Reader {
+	if bytes.HasPrefix(content, []byte("%PDF-2.")) {
+		patched := append([]byte{}, content...)
+		copy(patched, "%PDF-1.7")
+		return bytes.NewReader(patched)
+	}
+	return bytes.NewReader(content)
+}
+
+// pdfPageDimensions retourne la largeur et la hauteur d'une page (MediaBox, éventuellement héritée)
+func pdfPageDimensions(page pdf.Page) (float64, float64) {
+	for v := page.V; !v.IsNull(); v = v.Key("Parent") {
+		box := v.Key("MediaBox")
+		if box.Len() == 4 {
+			width := math.Abs(box.Index(2).Float64() - box.Index(0).Float64())
+			height := math.Abs(box.Index(3).Float64() - box.Index(1).Float64())
+			// Une page tournée de 90° est affichée en paysage
+			if rotate := int(page.V.Key("Rotate").Int64()); rotate%180 != 0 {
+				width, height = height, width
+			}
+			return math.Round(width*100) / 100, math.Round(height*100) / 100
+		}
+	}
+	return 0, 0
+}
+
+// pdfPageSizeName reconnaît les formats courants, quelle que soit l'orientation
+func pdfPageSizeName(width, height float64) string {
+	if width == 0 || height == 0 {
+		return ""
+	}
+	short, long := math.Min(width, height), math.Max(width, height)
+	for _, size := range pageSizes {
+		if math.Abs(short-size.width) <= 3 && math.Abs(long-size.height) <= 3 {
+			if width > height {
+				return size.name + " paysage"
+			}
+			return size.name
+		}
+	}
+	// Format non standard : dimensions en millimètres
+	return fmt.Sprintf("%.0fx%.0f mm", width/72*25.4, height/72*25.4)
+}
@@ -0,0 +1,45 @@
+package lib
+
+import "solfa-back/models"
+
+// Poids des signaux dans le score de popularité d'une partition
+const (
+	popularityCollectionWeight = 3.0
+	popularityFavoriteWeight   = 2.0
+)
+
+// RefreshPartitionPopularity recalcule les compteurs et le score de popularité
+// d'une partition, en base et dans Elasticsearch
+func RefreshPartitionPopularity(partitionID uint) error {
+	var collectionCount int64
+	if err := DB.Model(&models.CollectionItem{}).
+		Where("partition_id = ?", partitionID).
+		Distinct("collection_id").
+		Count(&collectionCount).Error; err != nil {
+		return err
+	}
+
+	var favoriteCount int64
+	if err := DB.Model(&models.Favorite{}).Where("partition_id = ?", partitionID).Count(&favoriteCount).Error; err != nil {
+		return err
+	}
+
+	popularity := popularityCollectionWeight*float64(collectionCount) +
+		popularityFavoriteWeight*float64(favoriteCount)
+
+	fields := map[string]interface{}{
+		"collection_count": collectionCount,
+		"favorite_count":   favoriteCount,
+		"popularity":       popularity,
+	}
+	// UpdateColumns : la date de mise à jour de la partition n'est pas modifiée
+	if err := DB.Model(&models.Partition{ID: partitionID}).UpdateColumns(fields).Error; err != nil {
+		return err
+	}
+	return UpdatePartitionFieldsInES(partitionID, fields)
+}
+
+// EnqueuePopularityRefresh planifie le recalcul de la popularité des partitions données
+func EnqueuePopularityRefresh(partitionIDs ...uint) {
+	enqueuePartitionsJob("popularity", partitionIDs, RefreshPartitionPopularity)
+}
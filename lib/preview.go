@@ -0,0 +1,177 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"solfa-back/models"
+	"time"
+)
+
+// Largeurs en pixels des images générées
+const (
+	PreviewWidth   = 1200
+	ThumbnailWidth = 240
+)
+
+// Nombre maximal de pixels d'un scan (une page A4 à 600 dpi en compte environ 35 millions)
+const maxImagePixels = 40_000_000
+
+// Hauteur maximale de l'aperçu d'une page PDF, en multiple de sa largeur
+const maxPreviewAspect = 4
+
+// Durée maximale du rendu d'une page PDF
+const pdfRenderTimeout = time.Minute
+
+// ErrImageTooLarge est renvoyée pour une image dont le décodage occuperait trop de mémoire
+var ErrImageTooLarge = fmt.Errorf("image trop grande (%d millions de pixels au maximum)", maxImagePixels/1_000_000)
+
+// PreviewPath retourne le chemin dans Minio d'une image dérivée ("preview" ou "thumbnail")
+func PreviewPath(partitionID uint, kind string) string {
+	return fmt.Sprintf("partitions/previews/%d_%s.jpg", partitionID, kind)
+}
+
+// EnqueuePreviewGeneration programme la génération de l'aperçu et de la vignette d'une partition
+func EnqueuePreviewGeneration(partition models.Partition) {
+	if partition.Format != FormatPDF && partition.Format != FormatImage {
+		return
+	}
+	EnqueueJob(fmt.Sprintf("preview_partition_%d", partition.ID), func() error {
+		return GeneratePartitionPreviews(partition)
+	})
+}
+
+// GeneratePartitionPreviews génère l'aperçu de la première page et la vignette d'une partition,
+// les enregistre sur Minio et met à jour la partition en base et dans Elasticsearch
+func GeneratePartitionPreviews(partition models.Partition) error {
+	ctx := context.Background()
+
+	content, err := GetObjectContent(ctx, partition.Path)
+	if err != nil {
+		return fmt.Errorf("lecture sur Minio: %v", err)
+	}
+
+	var firstPage image.Image
+	switch partition.Format {
+	case FormatPDF:
+		firstPage, err = renderPDFFirstPage(content, PreviewWidth)
+	case FormatImage:
+		firstPage, err = decodeImage(content)
+	default:
+		return fmt.Errorf("format %s sans aperçu", partition.Format)
+	}
+	if err != nil {
+		return err
+	}
+
+	urls := map[string]interface{}{}
+	for kind, width := range map[string]int{"preview": PreviewWidth, "thumbnail": ThumbnailWidth} {
+		encoded, err := encodeJPEG(resizeToWidth(firstPage, width))
+		if err != nil {
+			return err
+		}
+		if err := PutObjectContent(ctx, PreviewPath(partition.ID, kind), encoded, "image/jpeg"); err != nil {
+			return fmt.Errorf("écriture sur Minio: %v", err)
+		}
+		urls[kind+"_url"] = fmt.Sprintf("/partitions/%d/%s", partition.ID, kind)
+	}
+
+	if err := DB.Model(&models.Partition{}).Where("id = ?", partition.ID).Updates(urls).Error; err != nil {
+		return fmt.Errorf("mise à jour de la partition: %v", err)
+	}
+	return UpdatePartitionFieldsInES(partition.ID, urls)
+}
+
+// renderPDFFirstPage rend la première page d'un PDF avec pdftoppm (poppler-utils)
+func renderPDFFirstPage(content []byte, width int) (image.Image, error) {
+	dir, err := os.MkdirTemp("", "solfa-preview-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "partition.pdf")
+	if err := os.WriteFile(input, content, 0600); err != nil {
+		return nil, err
+	}
+
+	// Seul le haut d'une page démesurément longue est rendu (-W et -H)
+	ctx, cancel := context.WithTimeout(context.Background(), pdfRenderTimeout)
+	defer cancel()
+	output := filepath.Join(dir, "page")
+	cmd := exec.CommandContext(ctx, "pdftoppm", "-png", "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to-x", fmt.Sprint(width), "-scale-to-y", "-1",
+		"-x", "0", "-y", "0", "-W", fmt.Sprint(width), "-H", fmt.Sprint(width*maxPreviewAspect), input, output)
+	if message, err := cmd.CombinedOutput(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("pdftoppm: rendu interrompu après %v", pdfRenderTimeout)
+		}
+		return nil, fmt.Errorf("pdftoppm: %v %s", err, message)
+	}
+
+	rendered, err := os.ReadFile(output + ".png")
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(rendered)
+}
+
+// resizeToWidth redimensionne une image à la largeur donnée en conservant ses proportions,
+// sur fond blanc (les zones transparentes des scans PNG deviennent blanches)
+func resizeToWidth(source image.Image, width int) image.Image {
+	bounds := source.Bounds()
+	if bounds.Dx() < width {
+		width = bounds.Dx()
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(resized, resized.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(resized, resized.Bounds(), source, bounds, draw.Over, nil)
+	return resized
+}
+
+// encodeJPEG encode une image en JPEG
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// ValidateImage vérifie qu'un scan envoyé est une image lisible et de dimensions raisonnables
+func ValidateImage(content []byte) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return ErrImageTooLarge
+	}
+	return nil
+}
+
+// decodeImage décode une image après avoir vérifié ses dimensions : les en-têtes
+// peuvent annoncer une image bien plus grande que le fichier
+func decodeImage(content []byte) (image.Image, error) {
+	if err := ValidateImage(content); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	return img, err
+}
@@ -0,0 +1,30 @@
+package lib
+
+import (
+	"math"
+	"solfa-back/models"
+)
+
+// RefreshPartitionRating recalcule la note moyenne et le nombre de notes d'une
+// partition, en base et dans Elasticsearch (pour le tri par note)
+func RefreshPartitionRating(partitionID uint) error {
+	var aggregate struct {
+		Average float64
+		Count   int
+	}
+	if err := DB.Model(&models.Rating{}).
+		Select("COALESCE(AVG(value), 0) AS average, COUNT(*) AS count").
+		Where("partition_id = ?", partitionID).
+		Scan(&aggregate).Error; err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{
+		"rating_average": math.Round(aggregate.Average*100) / 100,
+		"rating_count":   aggregate.Count,
+	}
+	if err := DB.Model(&models.Partition{ID: partitionID}).UpdateColumns(fields).Error; err != nil {
+		return err
+	}
+	return UpdatePartitionFieldsInES(partitionID, fields)
+}
@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"solfa-back/models"
+)
+
+// Nombre maximal de partitions parcourues dans le graphe des relations d'une œuvre
+const relationGraphLimit = 500
+
+// ErrInvalidRelation est renvoyée pour une relation inconnue, réflexive ou qui crée un cycle
+var ErrInvalidRelation = errors.New("relation invalide")
+
+// RelationKinds liste les types de relation entre partitions
+var RelationKinds = []string{
+	models.RelationArrangementOf,
+	models.RelationTranslationOf,
+	models.RelationPartOfWork,
+	models.RelationAlternateEdition,
+}
+
+// ValidateRelation vérifie qu'une partition peut être déclarée comme dérivée d'une autre :
+// type connu, partitions distinctes et pas de dérivation circulaire (A arrangement de B,
+// B arrangement de A). Les autres éditions sont symétriques et ne sont déclarées qu'une fois.
+func ValidateRelation(partitionID, relatedID uint, kind string) error {
+	if !slices.Contains(RelationKinds, kind) {
+		return fmt.Errorf("%w: type %q (arrangement_of, translation_of, part_of_work ou alternate_edition)", ErrInvalidRelation, kind)
+	}
+	if partitionID == relatedID {
+		return fmt.Errorf("%w: une partition ne peut pas être reliée à elle-même", ErrInvalidRelation)
+	}
+
+	if kind == models.RelationAlternateEdition {
+		var count int64
+		if err := DB.Model(&models.PartitionRelation{}).
+			Where("partition_id = ? AND related_id = ? AND kind = ?", relatedID, partitionID, kind).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: ces partitions sont déjà déclarées comme éditions l'une de l'autre", ErrInvalidRelation)
+		}
+		return nil
+	}
+
+	// La partition liée ne doit pas dériver, même indirectement, de la partition dérivée
+	visited := map[uint]bool{relatedID: true}
+	queue := []uint{relatedID}
+	for len(queue) > 0 && len(visited) < relationGraphLimit {
+		var sources []uint
+		if err := DB.Model(&models.PartitionRelation{}).
+			Where("partition_id IN ? AND kind <> ?", queue, models.RelationAlternateEdition).
+			Pluck("related_id", &sources).Error; err != nil {
+			return err
+		}
+		queue = nil
+		for _, source := range sources {
+			if source == partitionID {
+				return fmt.Errorf("%w: la partition liée dérive déjà de cette partition", ErrInvalidRelation)
+			}
+			if !visited[source] {
+				visited[source] = true
+				queue = append(queue, source)
+			}
+		}
+	}
+	return nil
+}
+
+// RelatedPartitionIDs retourne les partitions reliées à une partition, directement ou non,
+// quel que soit le sens des relations ; la partition elle-même est incluse
+func RelatedPartitionIDs(partitionID uint) ([]uint, error) {
+	visited := map[uint]bool{partitionID: true}
+	ids := []uint{partitionID}
+	queue := []uint{partitionID}
+	for len(queue) > 0 && len(ids) < relationGraphLimit {
+		var relations []models.PartitionRelation
+		if err := DB.Select("partition_id", "related_id").
+			Where("partition_id IN ? OR related_id IN ?", queue, queue).
+			Find(&relations).Error; err != nil {
+			return nil, err
+		}
+		queue = nil
+		for _, relation := range relations {
+			for _, id := range []uint{relation.PartitionID, relation.RelatedID} {
+				if !visited[id] {
+					visited[id] = true
+					ids = append(ids, id)
+					queue = append(queue, id)
+				}
+			}
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// RefreshPartitionWorks recalcule l'œuvre des partitions reliées à chacune des partitions
+// données : toutes reçoivent le plus petit identifiant de leur groupe, en base et dans Elasticsearch
+func RefreshPartitionWorks(partitionIDs ...uint) error {
+	done := map[uint]bool{}
+	for _, partitionID := range partitionIDs {
+		if done[partitionID] {
+			continue
+		}
+		ids, err := RelatedPartitionIDs(partitionID)
+		if err != nil {
+			return err
+		}
+		workID := ids[0]
+
+		var outdated []uint
+		if err := DB.Model(&models.Partition{}).Where("id IN ? AND work_id <> ?", ids, workID).Pluck("id", &outdated).Error; err != nil {
+			return err
+		}
+		for _, id := range outdated {
+			if err := setPartitionWork(id, workID); err != nil {
+				return err
+			}
+		}
+		for _, id := range ids {
+			done[id] = true
+		}
+	}
+	return nil
+}
+
+// EnqueueWorksRefresh planifie le recalcul de l'œuvre des partitions données
+func EnqueueWorksRefresh(partitionIDs ...uint) {
+	EnqueueJob(fmt.Sprintf("works_%v", partitionIDs), func() error {
+		return RefreshPartitionWorks(partitionIDs...)
+	})
+}
+
+// AssignPartitionWorks rattache chaque partition sans œuvre à une œuvre qui lui est propre
+// (partitions antérieures aux relations)
+func AssignPartitionWorks() error {
+	var ids []uint
+	if err := DB.Model(&models.Partition{}).Where("work_id = 0").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := setPartitionWork(id, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPartitionWork enregistre l'œuvre d'une partition, en base et dans Elasticsearch
+func setPartitionWork(partitionID, workID uint) error {
+	if err := DB.Model(&models.Partition{ID: partitionID}).UpdateColumn("work_id", workID).Error; err != nil {
+		return err
+	}
+	return UpdatePartitionFieldsInES(partitionID, map[string]interface{}{"work_id": workID})
+}
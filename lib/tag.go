@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"solfa-back/models"
+	"strings"
+)
+
+// Longueur maximale d'un tag, en caractères
+const tagMaxLength = 50
+
+// ErrTagBanned est renvoyée lorsqu'un tag a été interdit par un modérateur
+var ErrTagBanned = errors.New("tag interdit")
+
+// ErrInvalidTag est renvoyée pour un tag vide ou trop long
+var ErrInvalidTag = errors.New("tag invalide")
+
+// ResolveTag recherche le tag correspondant à un libellé, en suivant les fusions.
+// Retourne nil s'il n'existe pas.
+func ResolveTag(name string) (*models.Tag, error) {
+	slug := Slugify(name)
+	if slug == "" {
+		return nil, nil
+	}
+
+	var tag models.Tag
+	err := DB.Where("slug = ?", slug).First(&tag).Error
+	seen := map[uint]bool{}
+	for err == nil && tag.MergedIntoID != nil && !seen[tag.ID] {
+		seen[tag.ID] = true
+		err = DB.First(&tag, *tag.MergedIntoID).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// FindOrCreateTag résout un libellé de tag, et crée le tag s'il est inconnu
+func FindOrCreateTag(name string) (*models.Tag, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if Slugify(name) == "" || len([]rune(name)) > tagMaxLength {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTag, name)
+	}
+
+	tag, err := ResolveTag(name)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		tag = &models.Tag{Name: name, Slug: Slugify(name)}
+		// Un tag identique peut avoir été créé entre-temps par une autre requête
+		if err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(tag).Error; err != nil {
+			return nil, err
+		}
+		if tag.ID == 0 {
+			return ResolveTag(name)
+		}
+		return tag, nil
+	}
+	if tag.Banned {
+		return nil, fmt.Errorf("%w: %q", ErrTagBanned, tag.Name)
+	}
+	return tag, nil
+}
+
+// RefreshPartitionTags recopie les slugs des tags d'une partition dans son champ Tags,
+// en base et dans Elasticsearch
+func RefreshPartitionTags(partitionID uint) error {
+	var slugs []string
+	if err := DB.Model(&models.PartitionTag{}).
+		Joins("JOIN tags ON tags.id = partition_tags.tag_id").
+		Where("partition_tags.partition_id = ?", partitionID).
+		Order("tags.slug").
+		Pluck("tags.slug", &slugs).Error; err != nil {
+		return err
+	}
+
+	// UpdateColumns avec une structure : le champ est sérialisé en JSON et la date de mise à jour n'est pas modifiée
+	if err := DB.Model(&models.Partition{ID: partitionID}).Select("tags").UpdateColumns(&models.Partition{Tags: slugs}).Error; err != nil {
+		return err
+	}
+	if slugs == nil {
+		slugs = []string{}
+	}
+	return UpdatePartitionFieldsInES(partitionID, map[string]interface{}{"tags": slugs})
+}
+
+// EnqueueTagsRefresh planifie la mise à jour des tags des partitions données
+func EnqueueTagsRefresh(partitionIDs ...uint) {
+	enqueuePartitionsJob("tags", partitionIDs, RefreshPartitionTags)
+}
+
+// TagPartitionIDs retourne les partitions portant un tag
+func TagPartitionIDs(tagID uint) ([]uint, error) {
+	var ids []uint
+	err := DB.Model(&models.PartitionTag{}).Where("tag_id = ?", tagID).Pluck("partition_id", &ids).Error
+	return ids, err
+}
+
+// MergeTag fusionne un tag dans un autre : ses associations sont reportées sur la cible
+// (sans doublon) et il est conservé comme redirection vers la cible
+func MergeTag(source, target models.Tag) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// Les partitions qui portent déjà la cible perdent simplement le tag fusionné
+		if err := tx.Where("tag_id = ? AND partition_id IN (?)", source.ID,
+			tx.Model(&models.PartitionTag{}).Select("partition_id").Where("tag_id = ?", target.ID),
+		).Delete(&models.PartitionTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PartitionTag{}).Where("tag_id = ?", source.ID).Update("tag_id", target.ID).Error; err != nil {
+			return err
+		}
+		// Les tags déjà fusionnés dans la source sont redirigés vers la cible
+		if err := tx.Model(&models.Tag{}).Where("merged_into_id = ?", source.ID).Update("merged_into_id", target.ID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Tag{ID: source.ID}).Update("merged_into_id", target.ID).Error
+	})
+}
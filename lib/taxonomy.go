@@ -0,0 +1,280 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"slices"
+	"solfa-back/models"
+)
+
+// Taxonomies liste les taxonomies gérées par les administrateurs
+var Taxonomies = []string{
+	models.TaxonomyGenre,
+	models.TaxonomyLiturgicalSeason,
+	models.TaxonomyOccasion,
+	models.TaxonomyVoicing,
+}
+
+// ErrUnknownTaxonomyTerm est renvoyée lorsqu'une valeur ne correspond à aucun terme d'une taxonomie
+var ErrUnknownTaxonomyTerm = errors.New("terme inconnu")
+
+// IsTaxonomy indique si le nom désigne une taxonomie gérée
+func IsTaxonomy(name string) bool {
+	for _, taxonomy := range Taxonomies {
+		if taxonomy == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveTaxonomyTerm recherche le terme d'une taxonomie désigné par son slug ou par l'un
+// de ses libellés (sans tenir compte de la casse ni des accents). Retourne nil s'il n'existe pas.
+func ResolveTaxonomyTerm(taxonomy, value string) (*models.TaxonomyTerm, error) {
+	normalized := NormalizeText(value)
+	if normalized == "" {
+		return nil, nil
+	}
+
+	var terms []models.TaxonomyTerm
+	if err := DB.Where("taxonomy = ?", taxonomy).Find(&terms).Error; err != nil {
+		return nil, err
+	}
+	for i, term := range terms {
+		if term.Slug == Slugify(value) || NormalizeText(term.LabelFR) == normalized || NormalizeText(term.LabelEN) == normalized {
+			return &terms[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// TaxonomyPath retourne les slugs d'un terme et de ses parents, de la racine au terme
+func TaxonomyPath(term models.TaxonomyTerm) ([]string, error) {
+	path := []string{term.Slug}
+	seen := map[uint]bool{term.ID: true}
+	for term.ParentID != nil && !seen[*term.ParentID] {
+		seen[*term.ParentID] = true
+		var parent models.TaxonomyTerm
+		if err := DB.First(&parent, *term.ParentID).Error; err != nil {
+			return nil, err
+		}
+		path = append([]string{parent.Slug}, path...)
+		term = parent
+	}
+	return path, nil
+}
+
+// resolveTaxonomyValue remplace une valeur saisie par le slug du terme correspondant
+func resolveTaxonomyValue(taxonomy, value string) (*models.TaxonomyTerm, error) {
+	term, err := ResolveTaxonomyTerm(taxonomy, value)
+	if err != nil {
+		return nil, err
+	}
+	if term == nil {
+		return nil, fmt.Errorf("%w (%s): %s", ErrUnknownTaxonomyTerm, taxonomy, value)
+	}
+	return term, nil
+}
+
+// ClassifyPartition vérifie que le genre, la catégorie, les temps liturgiques et les
+// occasions d'une partition appartiennent aux taxonomies, et les remplace par leurs slugs
+func ClassifyPartition(partition *models.Partition) error {
+	partition.GenrePath = nil
+	if partition.Genre != "" {
+		term, err := resolveTaxonomyValue(models.TaxonomyGenre, partition.Genre)
+		if err != nil {
+			return err
+		}
+		partition.Genre = term.Slug
+		if partition.GenrePath, err = TaxonomyPath(*term); err != nil {
+			return err
+		}
+	}
+
+	if partition.Category != "" {
+		term, err := resolveTaxonomyValue(models.TaxonomyVoicing, partition.Category)
+		if err != nil {
+			return err
+		}
+		partition.Category = term.Slug
+	}
+
+	for _, field := range []struct {
+		taxonomy string
+		values   *[]string
+	}{
+		{models.TaxonomyLiturgicalSeason, &partition.LiturgicalSeasons},
+		{models.TaxonomyOccasion, &partition.Occasions},
+	} {
+		var slugs []string
+		for _, value := range *field.values {
+			term, err := resolveTaxonomyValue(field.taxonomy, value)
+			if err != nil {
+				return err
+			}
+			slugs = append(slugs, term.Slug)
+		}
+		*field.values = slugs
+	}
+	return nil
+}
+
+// TaxonomyTermsBySlug retourne les termes de toutes les taxonomies, indexés par taxonomie puis par slug
+func TaxonomyTermsBySlug() (map[string]map[string]models.TaxonomyTerm, error) {
+	var terms []models.TaxonomyTerm
+	if err := DB.Find(&terms).Error; err != nil {
+		return nil, err
+	}
+	bySlug := map[string]map[string]models.TaxonomyTerm{}
+	for _, term := range terms {
+		if bySlug[term.Taxonomy] == nil {
+			bySlug[term.Taxonomy] = map[string]models.TaxonomyTerm{}
+		}
+		bySlug[term.Taxonomy][term.Slug] = term
+	}
+	return bySlug, nil
+}
+
+// defaultTaxonomyTerm est un terme créé à l'initialisation d'une base vide
+type defaultTaxonomyTerm struct {
+	labelFR  string
+	labelEN  string
+	children []defaultTaxonomyTerm
+}
+
+// Termes proposés par défaut ; les administrateurs peuvent ensuite les modifier
+var defaultTaxonomyTerms = map[string][]defaultTaxonomyTerm{
+	models.TaxonomyGenre: {
+		{labelFR: "Musique sacrée", labelEN: "Sacred music", children: []defaultTaxonomyTerm{
+			{labelFR: "Chant grégorien", labelEN: "Gregorian chant"},
+			{labelFR: "Gospel", labelEN: "Gospel"},
+			{labelFR: "Négro-spiritual", labelEN: "Spiritual"},
+			{labelFR: "Cantique", labelEN: "Hymn"},
+		}},
+		{labelFR: "Musique profane", labelEN: "Secular music", children: []defaultTaxonomyTerm{
+			{labelFR: "Chanson traditionnelle", labelEN: "Folk song"},
+			{labelFR: "Variété", labelEN: "Popular music"},
+			{labelFR: "Jazz", labelEN: "Jazz"},
+		}},
+	},
+	models.TaxonomyLiturgicalSeason: {
+		{labelFR: "Avent", labelEN: "Advent"},
+		{labelFR: "Noël", labelEN: "Christmas"},
+		{labelFR: "Carême", labelEN: "Lent"},
+		{labelFR: "Pâques", labelEN: "Easter"},
+		{labelFR: "Pentecôte", labelEN: "Pentecost"},
+		{labelFR: "Temps ordinaire", labelEN: "Ordinary Time"},
+	},
+	models.TaxonomyOccasion: {
+		{labelFR: "Mariage", labelEN: "Wedding"},
+		{labelFR: "Funérailles", labelEN: "Funeral"},
+		{labelFR: "Baptême", labelEN: "Baptism"},
+		{labelFR: "Concert", labelEN: "Concert"},
+	},
+	models.TaxonomyVoicing: {
+		{labelFR: "Chœur mixte", labelEN: "Mixed choir"},
+		{labelFR: "Voix égales", labelEN: "Equal voices"},
+		{labelFR: "Chœur d'enfants", labelEN: "Children's choir"},
+		{labelFR: "Soliste", labelEN: "Soloist"},
+	},
+}
+
+// SeedTaxonomies crée les termes par défaut lorsque la table des taxonomies est vide
+func SeedTaxonomies(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.TaxonomyTerm{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+
+	var create func(taxonomy string, terms []defaultTaxonomyTerm, parentID *uint) error
+	create = func(taxonomy string, terms []defaultTaxonomyTerm, parentID *uint) error {
+		for i, term := range terms {
+			record := models.TaxonomyTerm{
+				Taxonomy: taxonomy,
+				Slug:     Slugify(term.labelFR),
+				ParentID: parentID,
+				LabelFR:  term.labelFR,
+				LabelEN:  term.labelEN,
+				Position: i + 1,
+			}
+			if err := db.Create(&record).Error; err != nil {
+				return err
+			}
+			if err := create(taxonomy, term.children, &record.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for taxonomy, terms := range defaultTaxonomyTerms {
+		if err := create(taxonomy, terms, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TaxonomyTermUsage compte les partitions qui utilisent un terme
+func TaxonomyTermUsage(term models.TaxonomyTerm) (int, error) {
+	var partitions []models.Partition
+	if err := DB.Select("id", "genre_path", "category", "liturgical_seasons", "occasions").Find(&partitions).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, partition := range partitions {
+		var values []string
+		switch term.Taxonomy {
+		case models.TaxonomyGenre:
+			values = partition.GenrePath
+		case models.TaxonomyVoicing:
+			values = []string{partition.Category}
+		case models.TaxonomyLiturgicalSeason:
+			values = partition.LiturgicalSeasons
+		case models.TaxonomyOccasion:
+			values = partition.Occasions
+		}
+		if slices.Contains(values, term.Slug) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// RefreshGenrePaths recalcule le chemin des partitions relevant d'un genre (ou de ses
+// sous-genres) après son déplacement dans l'arbre
+func RefreshGenrePaths(slug string) error {
+	var partitions []models.Partition
+	if err := DB.Select("id", "genre", "genre_path").Where("genre <> ''").Find(&partitions).Error; err != nil {
+		return err
+	}
+
+	paths := map[string][]string{}
+	for _, partition := range partitions {
+		if !slices.Contains(partition.GenrePath, slug) {
+			continue
+		}
+		path, ok := paths[partition.Genre]
+		if !ok {
+			var term models.TaxonomyTerm
+			if err := DB.Where("taxonomy = ? AND slug = ?", models.TaxonomyGenre, partition.Genre).First(&term).Error; err != nil {
+				return err
+			}
+			var err error
+			if path, err = TaxonomyPath(term); err != nil {
+				return err
+			}
+			paths[partition.Genre] = path
+		}
+
+		if err := DB.Model(&models.Partition{ID: partition.ID}).Select("genre_path").Updates(&models.Partition{GenrePath: path}).Error; err != nil {
+			return err
+		}
+		if err := UpdatePartitionFieldsInES(partition.ID, map[string]interface{}{"genre_path": path}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package lib
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// NormalizeText réduit un texte à une forme comparable : minuscules, sans accents,
+// ligatures développées (« œ » devient « oe »), la ponctuation étant remplacée par des espaces
+func NormalizeText(text string) string {
+	var normalized strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Accent détaché par la décomposition NFD
+		case r == 'œ' || r == 'Œ':
+			normalized.WriteString("oe")
+		case r == 'æ' || r == 'Æ':
+			normalized.WriteString("ae")
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			normalized.WriteRune(unicode.ToLower(r))
+		default:
+			normalized.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(normalized.String()), " ")
+}
+
+// Slugify construit un identifiant lisible à partir d'un libellé ("Négro-spiritual" devient "negro-spiritual")
+func Slugify(label string) string {
+	return strings.ReplaceAll(NormalizeText(label), " ", "-")
+}
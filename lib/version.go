@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"encoding/json"
+	"reflect"
+	"solfa-back/models"
+)
+
+// Champs qui ne font pas partie d'une version : identifiants, modération,
+// statistiques et images générées
+var unversionedFields = map[string]bool{
+	"id":               true,
+	"version":          true,
+	"status":           true,
+	"validated_by":     true,
+	"uploaded_by":      true,
+	"collection_count": true,
+	"favorite_count":   true,
+	"popularity":       true,
+	"rating_average":   true,
+	"rating_count":     true,
+	"tags":             true,
+	"public_domain_in": true,
+	"free":             true,
+	"work_id":          true,
+	"organization_id":  true,
+	"thumbnail_url":    true,
+	"preview_url":      true,
+	"created_at":       true,
+	"updated_at":       true,
+}
+
+// MetadataChange décrit la modification d'un champ entre deux versions
+type MetadataChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// DiffPartitionMetadata compare les champs versionnés de deux états d'une partition,
+// avec les noms des champs JSON de l'API
+func DiffPartitionMetadata(from, to models.Partition) (map[string]MetadataChange, error) {
+	fromFields, err := partitionFields(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := partitionFields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]MetadataChange{}
+	for name := range fromFields {
+		if _, ok := toFields[name]; !ok {
+			toFields[name] = nil
+		}
+	}
+	for name, value := range toFields {
+		if unversionedFields[name] {
+			continue
+		}
+		if !reflect.DeepEqual(fromFields[name], value) {
+			changes[name] = MetadataChange{From: fromFields[name], To: value}
+		}
+	}
+	return changes, nil
+}
+
+// partitionFields retourne les champs JSON d'une partition
+func partitionFields(partition models.Partition) (map[string]interface{}, error) {
+	data, err := json.Marshal(partition)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// RestorePartitionSnapshot retourne la partition courante avec les champs versionnés
+// d'une version précédente (fichier, métadonnées, paroles)
+func RestorePartitionSnapshot(current, snapshot models.Partition) models.Partition {
+	restored := snapshot
+	restored.ID = current.ID
+	restored.Version = current.Version
+	restored.Status = current.Status
+	restored.ValidatedBy = current.ValidatedBy
+	restored.UploadedBy = current.UploadedBy
+	restored.CollectionCount = current.CollectionCount
+	restored.FavoriteCount = current.FavoriteCount
+	restored.Popularity = current.Popularity
+	restored.RatingAverage = current.RatingAverage
+	restored.RatingCount = current.RatingCount
+	restored.ThumbnailURL = current.ThumbnailURL
+	restored.PreviewURL = current.PreviewURL
+	restored.Tags = current.Tags
+	restored.WorkID = current.WorkID
+	restored.OrganizationID = current.OrganizationID
+	restored.CreatedAt = current.CreatedAt
+	restored.UpdatedAt = current.UpdatedAt
+	return restored
+}
@@ -0,0 +1,162 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Texte du filigrane par défaut ; modifiable avec la variable d'environnement WATERMARK_TEMPLATE
+const defaultWatermarkTemplate = "Copie personnelle de {email} - {date} - {organization} - {licence}"
+
+// WatermarkInfo regroupe les informations imprimées sur chaque page d'un PDF téléchargé
+type WatermarkInfo struct {
+	Email        string
+	Organization string
+	Licence      string
+	Date         time.Time
+}
+
+// NewWatermarkInfo prépare le filigrane d'un utilisateur et de son organisation, avec la mention
+// de licence configurée (WATERMARK_LICENCE) ; sans organisation, celle configurée par
+// WATERMARK_ORGANIZATION est imprimée
+func NewWatermarkInfo(email, organization string) WatermarkInfo {
+	licence := os.Getenv("WATERMARK_LICENCE")
+	if licence == "" {
+		licence = "Reproduction et diffusion interdites"
+	}
+	if organization == "" {
+		organization = os.Getenv("WATERMARK_ORGANIZATION")
+	}
+	return WatermarkInfo{
+		Email:        email,
+		Organization: organization,
+		Licence:      licence,
+		Date:         time.Now(),
+	}
+}
+
+// Text construit le texte du filigrane à partir du modèle configuré
+func (info WatermarkInfo) Text() string {
+	template := os.Getenv("WATERMARK_TEMPLATE")
+	if template == "" {
+		template = defaultWatermarkTemplate
+	}
+	text := strings.NewReplacer(
+		"{email}", info.Email,
+		"{date}", info.Date.Format("02/01/2006"),
+		"{organization}", info.Organization,
+		"{licence}", info.Licence,
+	).Replace(template)
+
+	// Supprimer les séparateurs laissés par les valeurs vides
+	var parts []string
+	for _, part := range strings.Split(text, " - ") {
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, strings.TrimSpace(part))
+		}
+	}
+	return strings.Join(parts, " - ")
+}
+
+// PageStamp décrit les textes imprimés en pied d'une page
+type PageStamp struct {
+	PageNumber string // Numéro de page, au centre du pied de page
+	Watermark  string // Filigrane, en petits caractères sous le numéro
+}
+
+// WatermarkPDF imprime le texte donné en pied de chaque page d'un PDF
+func WatermarkPDF(content []byte, text string) ([]byte, error) {
+	return StampPDF(content, func(int) PageStamp { return PageStamp{Watermark: text} })
+}
+
+// StampPDF imprime en pied de chaque page (numérotée à partir de 1) les textes
+// fournis par stamp
+func StampPDF(content []byte, stamp func(page int) PageStamp) ([]byte, error) {
+	return OverlayPDF(content, func(overlay *gofpdf.Fpdf, page int, dimension PageDimension) {
+		drawPageStamp(overlay, dimension, stamp(page))
+	})
+}
+
+// OverlayPDF superpose à chaque page d'un PDF (numérotée à partir de 1) le contenu dessiné
+// par draw. Une page de surimpression de mêmes dimensions est générée pour chaque page du
+// document, puis superposée à l'original avec qpdf.
+func OverlayPDF(content []byte, draw func(overlay *gofpdf.Fpdf, page int, dimension PageDimension)) ([]byte, error) {
+	dimensions, err := PDFPageDimensions(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(dimensions) == 0 {
+		return nil, errors.New("le PDF ne contient aucune page")
+	}
+
+	overlay := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "pt",
+		Size:    gofpdf.SizeType{Wd: dimensions[0].Width, Ht: dimensions[0].Height},
+	})
+	overlay.SetAutoPageBreak(false, 0)
+	for i, page := range dimensions {
+		overlay.AddPageFormat("P", gofpdf.SizeType{Wd: page.Width, Ht: page.Height})
+		draw(overlay, i+1, page)
+	}
+
+	var overlayContent bytes.Buffer
+	if err := overlay.Output(&overlayContent); err != nil {
+		return nil, fmt.Errorf("génération de la surimpression: %v", err)
+	}
+
+	return RunQPDF(map[string][]byte{"original.pdf": content, "overlay.pdf": overlayContent.Bytes()},
+		"original.pdf", "--overlay", "overlay.pdf", "--")
+}
+
+// drawPageStamp imprime le numéro de page et le filigrane en pied d'une page de surimpression
+func drawPageStamp(overlay *gofpdf.Fpdf, page PageDimension, pageStamp PageStamp) {
+	translate := overlay.UnicodeTranslatorFromDescriptor("")
+	if pageStamp.PageNumber != "" {
+		overlay.SetFont("Helvetica", "", 10)
+		overlay.SetTextColor(0, 0, 0)
+		overlay.SetXY(18, page.Height-30)
+		overlay.CellFormat(page.Width-36, 10, translate(pageStamp.PageNumber), "", 0, "C", false, 0, "")
+	}
+	if pageStamp.Watermark != "" {
+		overlay.SetFont("Helvetica", "", 7)
+		overlay.SetTextColor(110, 110, 110)
+		overlay.SetXY(18, page.Height-16)
+		overlay.CellFormat(page.Width-36, 8, translate(pageStamp.Watermark), "", 0, "C", false, 0, "")
+	}
+}
+
+// RunQPDF exécute qpdf dans un répertoire temporaire contenant les fichiers donnés
+// et renvoie le PDF produit (ajouté automatiquement en dernier argument)
+func RunQPDF(files map[string][]byte, args ...string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "solfa-qpdf-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	output := filepath.Join(dir, "output.pdf")
+	cmd := exec.Command("qpdf", append(args, output)...)
+	cmd.Dir = dir
+	if message, err := cmd.CombinedOutput(); err != nil {
+		// Code 3 : avertissements, le fichier est tout de même produit
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+			return nil, fmt.Errorf("qpdf: %v %s", err, message)
+		}
+	}
+
+	return os.ReadFile(output)
+}
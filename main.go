@@ -12,6 +12,12 @@ func main() {
 	lib.InitDB()
 	lib.InitES()
 	lib.InitMC()
+	lib.StartJobWorkers(2)
+	lib.StartBookletCleanup()
+	lib.StartEventReminders()
+	lib.EnqueueJob("link_partition_composers", lib.LinkPartitionComposers)
+	lib.EnqueueJob("refresh_partitions_licence", lib.RefreshAllPartitionsLicence)
+	lib.EnqueueJob("assign_partition_works", lib.AssignPartitionWorks)
 
 	r := gin.Default()
 
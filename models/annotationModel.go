@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Types d'annotation
+const (
+	AnnotationNote      = "note"      // Texte libre
+	AnnotationBreath    = "breath"    // Respiration
+	AnnotationHighlight = "highlight" // Zone surlignée
+)
+
+// AnnotationMark est une annotation placée sur une page ou une mesure d'une partition.
+// Les coordonnées sont relatives à la page (de 0 à 1, depuis le coin supérieur gauche),
+// pour ne pas dépendre de la taille d'affichage.
+type AnnotationMark struct {
+	Kind    string  `json:"kind"`              // "note", "breath" ou "highlight"
+	Page    int     `json:"page"`              // Page du PDF, à partir de 1 (0 : partition sans pages)
+	Measure int     `json:"measure,omitempty"` // Mesure concernée
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Width   float64 `json:"width,omitempty"`  // Largeur d'un surlignage
+	Height  float64 `json:"height,omitempty"` // Hauteur d'un surlignage
+	Text    string  `json:"text,omitempty"`   // Texte d'une note
+	Color   string  `json:"color,omitempty"`  // Couleur "#rrggbb"
+}
+
+// Annotation regroupe les annotations personnelles d'un utilisateur sur une partition,
+// éventuellement partagées avec les membres d'une de ses organisations
+type Annotation struct {
+	ID             uint             `json:"id" gorm:"primaryKey"`
+	PartitionID    uint             `json:"partition_id" gorm:"uniqueIndex:idx_annotation_owner"`
+	UserID         uint             `json:"user_id" gorm:"uniqueIndex:idx_annotation_owner"`
+	OrganizationID *uint            `json:"organization_id,omitempty" gorm:"index"` // Organisation avec laquelle les annotations sont partagées
+	Marks          []AnnotationMark `json:"marks" gorm:"serializer:json"`
+	User           *User            `json:"user,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Visibilités possibles d'une collection
+const (
+	CollectionPrivate      = "private"      // Visible uniquement par son propriétaire
+	CollectionLink         = "link"         // Visible par toute personne disposant du lien de partage
+	CollectionPublic       = "public"       // Visible par tous et duplicable
+	CollectionOrganization = "organization" // Visible par les membres de l'organisation de la collection
+)
+
+// Collection est une liste ordonnée de partitions constituée par un utilisateur
+// (programme de concert, répertoire d'une saison...)
+type Collection struct {
+	ID             uint             `json:"id" gorm:"primaryKey"`
+	OwnerID        uint             `json:"owner_id" gorm:"index"`
+	OrganizationID *uint            `json:"organization_id,omitempty" gorm:"index"` // Organisation dont les membres partagent la collection
+	Title          string           `json:"title"`
+	Description    string           `json:"description"`
+	Visibility     string           `json:"visibility" gorm:"default:private"`
+	ShareToken     string           `json:"share_token,omitempty" gorm:"index"` // Jeton du lien de partage, communiqué au seul propriétaire
+	DuplicatedFrom *uint            `json:"duplicated_from,omitempty"`          // Collection publique d'origine
+	Items          []CollectionItem `json:"items" gorm:"foreignKey:CollectionID"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// CollectionItem est une partition d'une collection, avec sa position et les
+// indications du chef (ex: "tonalité : ré, sauter le couplet 2")
+type CollectionItem struct {
+	ID           uint       `json:"-" gorm:"primaryKey"`
+	CollectionID uint       `json:"-" gorm:"index"`
+	PartitionID  uint       `json:"partition_id" gorm:"index"`
+	Position     int        `json:"position"`
+	Notes        string     `json:"notes"`
+	Partition    *Partition `json:"partition,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Comment est un commentaire sur une partition (ex: "faute de frappe mesure 32") ;
+// ParentID désigne le commentaire auquel il répond
+type Comment struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	PartitionID uint      `json:"partition_id" gorm:"index"`
+	UserID      uint      `json:"user_id" gorm:"index"`
+	User        *User     `json:"-"`
+	Author      string    `json:"author" gorm:"-"` // Nom d'utilisateur de l'auteur, renseigné à la lecture
+	ParentID    *uint     `json:"parent_id,omitempty" gorm:"index"`
+	Body        string    `json:"body"`
+	Hidden      bool      `json:"hidden" gorm:"default:false"` // Masqué par l'uploader ou un modérateur
+	HiddenByID  *uint     `json:"-"`                           // Utilisateur ayant masqué le commentaire, jamais communiqué
+	Replies     []Comment `json:"replies,omitempty" gorm:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Composer est un compositeur identifié par son nom canonique ; les autres graphies
+// rencontrées ("J.S. Bach", "Bach, Johann Sebastian"...) sont enregistrées comme alias
+type Composer struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"uniqueIndex"` // Nom canonique
+	Aliases     []string  `json:"aliases" gorm:"serializer:json"`
+	SearchNames string    `json:"-"` // Nom et alias normalisés, sous la forme "|nom|alias|" (voir lib.SetComposerSearchNames)
+	BirthYear   int       `json:"birth_year,omitempty"`
+	DeathYear   int       `json:"death_year,omitempty"`
+	Nationality string    `json:"nationality,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
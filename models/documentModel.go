@@ -0,0 +1,13 @@
+package models
+
+// DocumentMetadata regroupe les informations extraites des documents PDF.
+// Ses champs sont stockés directement sur la partition.
+type DocumentMetadata struct {
+	PageCount      int     `json:"page_count"`
+	PageWidth      float64 `json:"page_width"`  // Largeur de la première page en points (1/72 de pouce)
+	PageHeight     float64 `json:"page_height"` // Hauteur de la première page en points
+	PageSize       string  `json:"page_size"`   // Format reconnu ("A4", "Letter"...) ou dimensions en mm
+	HasTextLayer   bool    `json:"has_text_layer"`
+	DocumentTitle  string  `json:"document_title"`  // Titre présent dans les métadonnées du PDF
+	DocumentAuthor string  `json:"document_author"` // Auteur présent dans les métadonnées du PDF
+}
@@ -0,0 +1,19 @@
+package models
+
+// Types d'accompagnement d'une partition
+const (
+	AccompanimentACappella   = "a_cappella"
+	AccompanimentPiano       = "piano"
+	AccompanimentOrgan       = "organ"
+	AccompanimentInstruments = "instruments" // Ensemble instrumental ou autre instrument
+)
+
+// EnsembleMetadata décrit la formation pour laquelle la partition est écrite.
+// Ses champs sont stockés directement sur la partition.
+type EnsembleMetadata struct {
+	Voicing         string   `json:"voicing"`                                // Pupitres, du plus aigu au plus grave ("SATB", "SSA", "TTBB") ou "unison"
+	Divisi          bool     `json:"divisi"`                                 // Au moins un pupitre se divise
+	Accompaniment   string   `json:"accompaniment"`                          // "a_cappella", "piano", "organ" ou "instruments"
+	Instrumentation []string `json:"instrumentation" gorm:"serializer:json"` // Instruments, accompagnement compris
+	Soloists        []string `json:"soloists" gorm:"serializer:json"`        // Voix solistes ("soprano", "baritone"...)
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Types d'événement d'une organisation
+const (
+	EventRehearsal = "rehearsal"
+	EventConcert   = "concert"
+	EventService   = "service" // Office, messe ou célébration
+)
+
+// Event est une répétition, un concert ou un office d'une organisation, avec son programme
+type Event struct {
+	ID             uint        `json:"id" gorm:"primaryKey"`
+	OrganizationID uint        `json:"organization_id" gorm:"index"`
+	Kind           string      `json:"kind"` // "rehearsal", "concert" ou "service"
+	Title          string      `json:"title"`
+	StartsAt       time.Time   `json:"starts_at" gorm:"index"`
+	EndsAt         *time.Time  `json:"ends_at,omitempty"`
+	Location       string      `json:"location"`
+	Notes          string      `json:"notes"`
+	ReminderHours  int         `json:"reminder_hours"`        // Délai du rappel envoyé aux membres avant l'événement (0 : aucun rappel)
+	RemindedAt     *time.Time  `json:"reminded_at,omitempty"` // Date d'envoi du rappel
+	CreatedBy      uint        `json:"created_by"`
+	Items          []EventItem `json:"items" gorm:"foreignKey:EventID"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// EventItem est une partition du programme d'un événement, avec sa position et les
+// indications du chef (ex: "entrée", "reprendre la coda")
+type EventItem struct {
+	ID          uint       `json:"-" gorm:"primaryKey"`
+	EventID     uint       `json:"-" gorm:"index"`
+	PartitionID uint       `json:"partition_id" gorm:"index"`
+	Position    int        `json:"position"`
+	Notes       string     `json:"notes"`
+	Partition   *Partition `json:"partition,omitempty"`
+}
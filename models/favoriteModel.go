@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Favorite est une partition mise en favori par un utilisateur
+type Favorite struct {
+	ID          uint       `json:"-" gorm:"primaryKey"`
+	UserID      uint       `json:"-" gorm:"uniqueIndex:idx_favorite_user_partition"`
+	User        *User      `json:"-"`
+	PartitionID uint       `json:"partition_id" gorm:"uniqueIndex:idx_favorite_user_partition;index"`
+	Partition   *Partition `json:"partition,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Types d'historique d'un utilisateur
+const (
+	HistoryViewed     = "viewed"
+	HistoryDownloaded = "downloaded"
+)
+
+// PartitionHistory enregistre la dernière consultation ou le dernier téléchargement
+// d'une partition par un utilisateur (une seule ligne par partition et par type)
+type PartitionHistory struct {
+	ID          uint       `json:"-" gorm:"primaryKey"`
+	UserID      uint       `json:"-" gorm:"uniqueIndex:idx_history_user_partition_kind"`
+	User        *User      `json:"-"`
+	PartitionID uint       `json:"partition_id" gorm:"uniqueIndex:idx_history_user_partition_kind"`
+	Partition   *Partition `json:"partition,omitempty"`
+	Kind        string     `json:"kind" gorm:"uniqueIndex:idx_history_user_partition_kind"` // "viewed" ou "downloaded"
+	Count       int        `json:"count"`
+	LastAt      time.Time  `json:"last_at" gorm:"index"`
+}
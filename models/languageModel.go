@@ -0,0 +1,17 @@
+package models
+
+// TitleTranslation est le titre (et la description) d'une partition dans une autre langue
+type TitleTranslation struct {
+	Language    string `json:"language"` // Code ISO 639-1 ("en", "de"...)
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// LanguageMetadata regroupe les langues d'une partition et ses titres traduits.
+// Ses champs sont stockés directement sur la partition.
+type LanguageMetadata struct {
+	Language        string             `json:"language"` // Langue du titre original et de la description (ISO 639-1)
+	Description     string             `json:"description"`
+	Translations    []TitleTranslation `json:"translations" gorm:"serializer:json"`     // Titres traduits ("Silent Night" pour "Stille Nacht")
+	LyricsLanguages []string           `json:"lyrics_languages" gorm:"serializer:json"` // Langues des paroles, saisies ou déduites du fichier
+}
@@ -0,0 +1,24 @@
+package models
+
+// Licences d'une partition
+const (
+	LicencePublicDomain      = "public_domain"
+	LicenceCCBY              = "cc_by"
+	LicenceCCBYSA            = "cc_by_sa"
+	LicenceAllRightsReserved = "all_rights_reserved" // Œuvre protégée, diffusée avec autorisation
+	LicencePublisher         = "publisher"           // Œuvre protégée, sous licence d'un éditeur
+)
+
+// LicenceMetadata regroupe le statut juridique et la provenance d'une partition.
+// Ses champs sont stockés directement sur la partition.
+type LicenceMetadata struct {
+	Licence           string   `json:"licence"`                                 // Vide si inconnue : le statut est alors déduit des dates de décès
+	Publisher         string   `json:"publisher"`                               // Éditeur de la partition
+	Permission        string   `json:"permission"`                              // Autorisation obtenue pour une œuvre protégée
+	Source            string   `json:"source"`                                  // Provenance (bibliothèque, manuscrit, site...)
+	Edition           string   `json:"edition"`                                 // Édition ou éditeur scientifique
+	Arranger          string   `json:"arranger"`                                // Arrangeur, dont les droits s'ajoutent à ceux du compositeur
+	ArrangerDeathYear int      `json:"arranger_death_year"`                     // 0 si l'arrangeur est vivant ou la date inconnue
+	PublicDomainIn    []string `json:"public_domain_in" gorm:"serializer:json"` // Juridictions où l'œuvre est probablement dans le domaine public
+	Free              bool     `json:"free"`                                    // Téléchargeable par tous (licence libre ou domaine public probable)
+}
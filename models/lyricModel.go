@@ -0,0 +1,10 @@
+package models
+
+// PartitionLyric représente un couplet des paroles d'une partition, pour une voix donnée
+type PartitionLyric struct {
+	ID          uint   `json:"-" gorm:"primaryKey"`
+	PartitionID uint   `json:"-" gorm:"index"`
+	Voice       string `json:"voice"` // Nom de la partie (MusicXML) ou de la voix (ABC)
+	Verse       int    `json:"verse"` // Numéro du couplet, à partir de 1
+	Text        string `json:"text"`
+}
@@ -0,0 +1,44 @@
+package models
+
+// TempoChange représente un changement de tempo à un instant donné de la pièce
+type TempoChange struct {
+	Seconds float64 `json:"seconds"` // Position du changement en secondes
+	BPM     float64 `json:"bpm"`
+}
+
+// TimeSignatureChange représente un changement de mesure à un instant donné
+type TimeSignatureChange struct {
+	Seconds   float64 `json:"seconds"`
+	Signature string  `json:"signature"` // Ex: "3/4"
+}
+
+// KeySignatureChange représente un changement d'armure à un instant donné
+type KeySignatureChange struct {
+	Seconds float64 `json:"seconds"`
+	Key     string  `json:"key"` // Ex: "D major", "B minor"
+}
+
+// Track décrit une piste (ou une partie) d'un fichier symbolique
+type Track struct {
+	Name       string `json:"name"`
+	Instrument string `json:"instrument"`
+}
+
+// MusicMetadata regroupe les informations musicales extraites des fichiers
+// symboliques (MIDI, MusicXML...). Ses champs sont stockés directement sur la partition.
+type MusicMetadata struct {
+	Duration            float64               `json:"duration"`       // Durée en secondes
+	TimeSignature       string                `json:"time_signature"` // Mesure principale (la première rencontrée)
+	KeySignature        string                `json:"key_signature"`  // Tonalité principale (la première rencontrée)
+	Tempo               float64               `json:"tempo"`          // Tempo initial en BPM
+	LowestPitch         int                   `json:"lowest_pitch"`   // Numéro MIDI de la note la plus grave
+	HighestPitch        int                   `json:"highest_pitch"`  // Numéro MIDI de la note la plus aiguë
+	TempoMap            []TempoChange         `json:"tempo_map" gorm:"serializer:json"`
+	TimeSignatures      []TimeSignatureChange `json:"time_signatures" gorm:"serializer:json"`
+	KeySignatures       []KeySignatureChange  `json:"key_signatures" gorm:"serializer:json"`
+	Tracks              []Track               `json:"tracks" gorm:"serializer:json"`
+	Incipit             []int                 `json:"incipit" gorm:"serializer:json"` // Premières notes de la mélodie (numéros MIDI)
+	MelodyIntervals     string                `json:"melody_intervals"`               // Intervalles de l'incipit, ex: "s u2 u2 d4"
+	MelodyContour       string                `json:"melody_contour"`                 // Code de Parsons de l'incipit, ex: "s u u d"
+	EstimatedDifficulty float64               `json:"estimated_difficulty"`           // Difficulté estimée de 1 à 5 (voir lib.EstimateDifficulty), 0 si inconnue
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Types d'organisation
+const (
+	OrganizationChoir  = "choir"
+	OrganizationParish = "parish"
+	OrganizationSchool = "school"
+)
+
+// Rôles des membres d'une organisation, du plus au moins étendu
+const (
+	OrganizationRoleOwner    = "owner"    // Gère l'organisation, ses membres et leurs rôles
+	OrganizationRoleDirector = "director" // Gère la bibliothèque privée et invite des membres
+	OrganizationRoleMember   = "member"   // Consulte la bibliothèque privée
+)
+
+// Organization est un chœur, une paroisse ou une école, dont les membres partagent
+// une bibliothèque privée de partitions et de collections
+type Organization struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug" gorm:"uniqueIndex"`
+	Kind        string    `json:"kind"` // "choir", "parish" ou "school"
+	Description string    `json:"description"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// OrganizationMember associe un utilisateur à une organisation avec son rôle
+type OrganizationMember struct {
+	ID             uint      `json:"-" gorm:"primaryKey"`
+	OrganizationID uint      `json:"organization_id" gorm:"uniqueIndex:idx_organization_member"`
+	UserID         uint      `json:"user_id" gorm:"uniqueIndex:idx_organization_member;index"`
+	Role           string    `json:"role"`
+	User           *User     `json:"user,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// OrganizationInvitation est une invitation envoyée par email à rejoindre une organisation
+type OrganizationInvitation struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	OrganizationID uint       `json:"organization_id" gorm:"index"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	Token          string     `json:"-" gorm:"uniqueIndex"` // Jeton du lien d'invitation, communiqué au seul invité
+	InvitedBy      uint       `json:"invited_by"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
@@ -2,16 +2,49 @@ package models
 
 import "time"
 
+// Origine du niveau de difficulté d'une partition
+const (
+	DifficultyByUploader  = "uploader"
+	DifficultyByModerator = "moderator"
+	DifficultyEstimated   = "estimated" // Estimé à partir du fichier (voir lib.EstimateDifficulty)
+)
+
 type Partition struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
 	Title       string    `json:"title"`
-	Composer    string    `json:"composer"`
-	Genre       string    `json:"genre"`
-	Category    string    `json:"category"`
+	Composer    string    `json:"composer"` // Nom canonique du compositeur lorsqu'il est identifié
+	ComposerID  *uint     `json:"composer_id,omitempty" gorm:"index"`
+	Genre       string    `json:"genre"`    // Slug d'un terme de la taxonomie "genre"
+	GenrePath   []string  `json:"genre_path" gorm:"serializer:json"` // Slugs du genre et de ses parents, pour les filtres et facettes
+	Category    string    `json:"category"` // Slug d'un terme de la taxonomie "voicing"
+	LiturgicalSeasons []string `json:"liturgical_seasons" gorm:"serializer:json"`
+	Occasions   []string  `json:"occasions" gorm:"serializer:json"`
+	Tags        []string  `json:"tags" gorm:"serializer:json"` // Slugs des tags libres (voir PartitionTag), recopiés pour la recherche
 	ReleaseDate time.Time `json:"release_date"`
 	Path        string    `json:"path"`
+	Format      string    `json:"format"`  // "pdf", "midi", "abc"... déduit de l'extension du fichier
+	MusicXMLPath string   `json:"musicxml_path"` // Version MusicXML (originale ou convertie) dans Minio
+	ThumbnailURL string   `json:"thumbnail_url"` // Vignette générée en tâche de fond (PDF et scans)
+	PreviewURL  string    `json:"preview_url"`   // Aperçu de la première page
 	Status      string    `json:"status"`  // "staging" ou "validated"
 	ValidatedBy string	  `json:"validated_by"`
+	UploadedBy  uint      `json:"uploaded_by" gorm:"index"` // Identifiant de l'utilisateur ayant uploadé la partition
+	OrganizationID *uint  `json:"organization_id,omitempty" gorm:"index"` // Bibliothèque privée d'une organisation, visible de ses seuls membres ; nil pour le catalogue public
+	Version     int       `json:"version" gorm:"default:1"` // Numéro de la version courante (voir PartitionVersion)
+	Difficulty  int       `json:"difficulty,omitempty" gorm:"index"` // Niveau de 1 (débutant) à 5, 0 (absent) si inconnu : les partitions sans niveau sont classées en dernier
+	DifficultySource string `json:"difficulty_source"` // "uploader", "moderator" ou "estimated"
+	WorkID      uint      `json:"work_id" gorm:"index"` // Plus petit identifiant des partitions reliées (voir PartitionRelation), pour regrouper les arrangements d'une même œuvre
+	MusicMetadata `gorm:"embedded"` // Informations extraites des fichiers symboliques
+	DocumentMetadata `gorm:"embedded"` // Informations extraites des PDF
+	LicenceMetadata `gorm:"embedded"` // Licence, provenance et statut de domaine public
+	EnsembleMetadata `gorm:"embedded"` // Formation (pupitres, accompagnement), saisie ou déduite des parties MusicXML
+	LanguageMetadata `gorm:"embedded"` // Langues, description et titres traduits
+	Lyrics      []PartitionLyric `json:"lyrics,omitempty" gorm:"foreignKey:PartitionID"`
+	CollectionCount int   `json:"collection_count"` // Nombre de collections contenant la partition
+	FavoriteCount int     `json:"favorite_count"`   // Nombre d'utilisateurs l'ayant mise en favori
+	Popularity  float64   `json:"popularity"`    // Score de popularité, recalculé par lib.RefreshPartitionPopularity
+	RatingAverage float64 `json:"rating_average"` // Moyenne des notes (1 à 5), recalculée par lib.RefreshPartitionRating
+	RatingCount int       `json:"rating_count"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
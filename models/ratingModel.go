@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Rating est la note (de 1 à 5) donnée par un utilisateur à une partition ;
+// un utilisateur ne note qu'une fois chaque partition mais peut modifier sa note
+type Rating struct {
+	ID          uint      `json:"-" gorm:"primaryKey"`
+	UserID      uint      `json:"-" gorm:"uniqueIndex:idx_rating_user_partition"`
+	User        *User     `json:"-"`
+	PartitionID uint      `json:"partition_id" gorm:"uniqueIndex:idx_rating_user_partition;index"`
+	Value       int       `json:"value"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
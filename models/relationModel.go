@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Types de relation entre deux partitions
+const (
+	RelationArrangementOf    = "arrangement_of"    // Arrangement (harmonisation, réduction...) de la partition liée
+	RelationTranslationOf    = "translation_of"    // Traduction des paroles de la partition liée
+	RelationPartOfWork       = "part_of_work"      // Extrait ou mouvement de l'œuvre liée
+	RelationAlternateEdition = "alternate_edition" // Autre édition de la même œuvre
+)
+
+// PartitionRelation relie une partition dérivée (PartitionID) à la partition dont elle dérive (RelatedID).
+// Les partitions reliées, directement ou non, forment une même œuvre (voir Partition.WorkID).
+type PartitionRelation struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	PartitionID uint      `json:"partition_id" gorm:"uniqueIndex:idx_partition_relation"`
+	RelatedID   uint      `json:"related_id" gorm:"uniqueIndex:idx_partition_relation;index"`
+	Kind        string    `json:"kind" gorm:"uniqueIndex:idx_partition_relation"`
+	CreatedBy   uint      `json:"created_by"` // Utilisateur ayant déclaré la relation
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Catégories de signalement
+const (
+	ReportWrongNotes  = "wrong_notes"  // Fausses notes
+	ReportBadMetadata = "bad_metadata" // Titre, compositeur ou métadonnées erronés
+	ReportCopyright   = "copyright"    // Atteinte au droit d'auteur
+	ReportSpam        = "spam"
+	ReportOther       = "other"
+)
+
+// Statuts d'un signalement
+const (
+	ReportOpen      = "open"      // En attente de traitement
+	ReportInReview  = "in_review" // Pris en charge par un modérateur
+	ReportResolved  = "resolved"  // Corrigé
+	ReportDismissed = "dismissed" // Rejeté
+)
+
+// Report est un signalement d'un problème sur une partition
+type Report struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	PartitionID uint       `json:"partition_id" gorm:"index"`
+	Partition   *Partition `json:"partition,omitempty"`
+	ReporterID  uint       `json:"reporter_id" gorm:"index"`
+	Category    string     `json:"category"`
+	Description string     `json:"description"`
+	Status      string     `json:"status" gorm:"index;default:open"`
+	Resolution  string     `json:"resolution"` // Commentaire du modérateur
+	HandledBy   string     `json:"handled_by"` // Email du modérateur ayant traité le signalement
+	ResolvedAt  *time.Time `json:"resolved_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
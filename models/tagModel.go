@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Tag est un mot-clé libre ajouté aux partitions par les utilisateurs
+type Tag struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Name         string    `json:"name"`                        // Libellé affiché, tel que saisi la première fois
+	Slug         string    `json:"slug" gorm:"uniqueIndex"`     // Forme normalisée ("Noël" devient "noel")
+	Banned       bool      `json:"banned" gorm:"default:false"` // Tag interdit par un modérateur
+	MergedIntoID *uint     `json:"merged_into_id,omitempty"`    // Tag fusionné : ses nouvelles utilisations sont reportées sur la cible
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// PartitionTag associe un tag à une partition, avec l'utilisateur qui l'a ajouté
+type PartitionTag struct {
+	ID          uint      `json:"-" gorm:"primaryKey"`
+	PartitionID uint      `json:"partition_id" gorm:"uniqueIndex:idx_partition_tag"`
+	TagID       uint      `json:"tag_id" gorm:"uniqueIndex:idx_partition_tag;index"`
+	Tag         *Tag      `json:"tag,omitempty"`
+	UserID      uint      `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Taxonomies gérées par les administrateurs
+const (
+	TaxonomyGenre            = "genre"             // Genres musicaux, hiérarchiques (ex: musique sacrée > gospel)
+	TaxonomyLiturgicalSeason = "liturgical_season" // Temps liturgiques (Avent, Carême...)
+	TaxonomyOccasion         = "occasion"          // Occasions (mariage, funérailles...)
+	TaxonomyVoicing          = "voicing"           // Catégories de formation (chœur mixte, voix égales...)
+)
+
+// TaxonomyTerm est un terme d'une taxonomie, identifié par son slug et traduit en français et en anglais
+type TaxonomyTerm struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Taxonomy  string    `json:"taxonomy" gorm:"uniqueIndex:idx_taxonomy_slug"`
+	Slug      string    `json:"slug" gorm:"uniqueIndex:idx_taxonomy_slug"`
+	ParentID  *uint     `json:"parent_id,omitempty" gorm:"index"`
+	LabelFR   string    `json:"label_fr"`
+	LabelEN   string    `json:"label_en"`
+	Position  int       `json:"position"` // Ordre d'affichage parmi les termes de même parent
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Label retourne le libellé du terme dans la langue demandée ("fr" par défaut)
+func (t TaxonomyTerm) Label(lang string) string {
+	if lang == "en" && t.LabelEN != "" {
+		return t.LabelEN
+	}
+	return t.LabelFR
+}
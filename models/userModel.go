@@ -7,10 +7,24 @@ import (
 // User représente un utilisateur de l'application
 type User struct {
 	gorm.Model
-	Username          string `json:"username"`
-	Email             string `json:"email" gorm:"unique"`
-	Password          string `json:"-"`
-	IsVerified        bool   `json:"is_verified" gorm:"default:false"`
-	VerificationToken string `json:"-"`
+	Username          string  `json:"username"`
+	Email             string  `json:"email" gorm:"unique"`
+	Password          string  `json:"-"`
+	IsVerified        bool    `json:"is_verified" gorm:"default:false"`
+	VerificationToken string  `json:"-"`
+	CalendarToken     *string `json:"-" gorm:"uniqueIndex:idx_users_calendar_token_unique"` // Jeton des flux iCalendar de l'utilisateur (NULL tant qu'il n'a pas été créé)
+	Role              string  `json:"role" gorm:"default:user"`                             // "user", "moderator" ou "admin"
+}
+
+// Rôles des utilisateurs
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// IsModerator indique si l'utilisateur peut modérer le contenu (modérateurs et administrateurs)
+func (u User) IsModerator() bool {
+	return u.Role == RoleModerator || u.Role == RoleAdmin
 }
 
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Raisons de la création d'une version
+const (
+	VersionMetadata = "metadata" // Modification des métadonnées
+	VersionFile     = "file"     // Remplacement du fichier
+	VersionRollback = "rollback" // Retour à une version précédente
+)
+
+// PartitionVersion conserve l'état d'une partition avant une modification : les
+// métadonnées sont copiées dans Snapshot, et le fichier précédent reste dans Minio
+// à l'emplacement Snapshot.Path (un nouveau fichier est toujours enregistré ailleurs)
+type PartitionVersion struct {
+	ID          uint      `json:"-" gorm:"primaryKey"`
+	PartitionID uint      `json:"partition_id" gorm:"uniqueIndex:idx_version_partition_number"`
+	Version     int       `json:"version" gorm:"uniqueIndex:idx_version_partition_number"`
+	Snapshot    Partition `json:"snapshot" gorm:"serializer:json"`
+	Reason      string    `json:"reason"` // Modification ayant remplacé cette version
+	ReplacedBy  string    `json:"-"`      // Email de l'auteur de la modification, communiqué aux seuls éditeurs
+	CreatedAt   time.Time `json:"created_at"`
+}
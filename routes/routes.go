@@ -18,4 +18,90 @@ func SetupRoutes(r *gin.Engine) {
 	r.POST("/upload", middleware.AuthMiddleware(), handlers.UploadPartitionHandler)
 	r.POST("/validate", middleware.AuthMiddleware(), handlers.ValidatePartitionHandler)
 	r.GET("/search", handlers.SearchPartitionsHandler)
+	r.GET("/search/melody", handlers.SearchMelodyHandler)
+	r.GET("/partitions/:id", handlers.GetPartitionHandler)
+	r.PUT("/partitions/:id", middleware.AuthMiddleware(), handlers.UpdatePartitionHandler)
+	r.PUT("/partitions/:id/file", middleware.AuthMiddleware(), handlers.ReplacePartitionFileHandler)
+	r.GET("/partitions/:id/versions", handlers.GetPartitionVersionsHandler)
+	r.GET("/partitions/:id/versions/diff", handlers.DiffPartitionVersionsHandler)
+	r.POST("/partitions/:id/versions/:version/rollback", middleware.AuthMiddleware(), handlers.RollbackPartitionHandler)
+	r.GET("/partitions/:id/thumbnail", handlers.GetPartitionThumbnailHandler)
+	r.GET("/partitions/:id/preview", handlers.GetPartitionPreviewHandler)
+	r.GET("/partitions/:id/download", handlers.DownloadPartitionHandler)
+	r.GET("/partitions/:id/musicxml", handlers.GetPartitionMusicXMLHandler)
+	r.GET("/partitions/:id/lyrics", handlers.GetPartitionLyricsHandler)
+	r.GET("/partitions/:id/parts", handlers.GetPartitionPartsHandler)
+	r.GET("/partitions/:id/parts/:part", handlers.GetPartitionPartHandler)
+	r.POST("/partitions/:id/favorite", middleware.AuthMiddleware(), handlers.AddFavoriteHandler)
+	r.DELETE("/partitions/:id/favorite", middleware.AuthMiddleware(), handlers.RemoveFavoriteHandler)
+	r.GET("/partitions/:id/rating", handlers.GetPartitionRatingHandler)
+	r.PUT("/partitions/:id/rating", middleware.AuthMiddleware(), handlers.RatePartitionHandler)
+	r.DELETE("/partitions/:id/rating", middleware.AuthMiddleware(), handlers.DeleteRatingHandler)
+	r.GET("/partitions/:id/comments", handlers.GetPartitionCommentsHandler)
+	r.POST("/partitions/:id/comments", middleware.AuthMiddleware(), handlers.CreateCommentHandler)
+	r.POST("/comments/:id/hide", middleware.AuthMiddleware(), handlers.HideCommentHandler)
+	r.DELETE("/comments/:id/hide", middleware.AuthMiddleware(), handlers.UnhideCommentHandler)
+	r.DELETE("/comments/:id", middleware.AuthMiddleware(), handlers.DeleteCommentHandler)
+	r.POST("/partitions/:id/reports", middleware.AuthMiddleware(), handlers.CreateReportHandler)
+	r.GET("/reports", middleware.AuthMiddleware(), handlers.ListReportsHandler)
+	r.PUT("/reports/:id", middleware.AuthMiddleware(), handlers.TriageReportHandler)
+	r.GET("/composers", handlers.GetComposersHandler)
+	r.POST("/composers", middleware.AuthMiddleware(), handlers.CreateComposerHandler)
+	r.PUT("/composers/:id", middleware.AuthMiddleware(), handlers.UpdateComposerHandler)
+	r.GET("/composers/:id/partitions", handlers.GetComposerPartitionsHandler)
+	r.POST("/composers/:id/merge", middleware.AuthMiddleware(), handlers.MergeComposerHandler)
+	r.GET("/taxonomies", handlers.GetTaxonomiesHandler)
+	r.POST("/taxonomies/:taxonomy/terms", middleware.AuthMiddleware(), handlers.CreateTaxonomyTermHandler)
+	r.PUT("/taxonomies/terms/:id", middleware.AuthMiddleware(), handlers.UpdateTaxonomyTermHandler)
+	r.DELETE("/taxonomies/terms/:id", middleware.AuthMiddleware(), handlers.DeleteTaxonomyTermHandler)
+	r.GET("/tags", handlers.GetTagsHandler)
+	r.GET("/tags/suggest", handlers.SuggestTagsHandler)
+	r.PUT("/tags/:id", middleware.AuthMiddleware(), handlers.RenameTagHandler)
+	r.POST("/tags/:id/merge", middleware.AuthMiddleware(), handlers.MergeTagHandler)
+	r.POST("/tags/:id/ban", middleware.AuthMiddleware(), handlers.BanTagHandler)
+	r.DELETE("/tags/:id/ban", middleware.AuthMiddleware(), handlers.UnbanTagHandler)
+	r.POST("/partitions/:id/tags", middleware.AuthMiddleware(), handlers.AddPartitionTagsHandler)
+	r.DELETE("/partitions/:id/tags/:tag", middleware.AuthMiddleware(), handlers.RemovePartitionTagHandler)
+	r.GET("/partitions/:id/related", handlers.GetRelatedPartitionsHandler)
+	r.POST("/partitions/:id/relations", middleware.AuthMiddleware(), handlers.CreatePartitionRelationHandler)
+	r.DELETE("/partitions/:id/relations/:relation", middleware.AuthMiddleware(), handlers.DeletePartitionRelationHandler)
+	r.GET("/partitions/:id/annotations", middleware.AuthMiddleware(), handlers.GetAnnotationsHandler)
+	r.PUT("/partitions/:id/annotations", middleware.AuthMiddleware(), handlers.UpdateAnnotationsHandler)
+	r.DELETE("/partitions/:id/annotations", middleware.AuthMiddleware(), handlers.DeleteAnnotationsHandler)
+	r.GET("/partitions/:id/annotations/export", middleware.AuthMiddleware(), handlers.ExportAnnotationsHandler)
+	r.GET("/me/favorites", middleware.AuthMiddleware(), handlers.GetMyFavoritesHandler)
+	r.GET("/me/history/viewed", middleware.AuthMiddleware(), handlers.GetRecentlyViewedHandler)
+	r.GET("/me/history/downloaded", middleware.AuthMiddleware(), handlers.GetRecentlyDownloadedHandler)
+	r.GET("/me/collections", middleware.AuthMiddleware(), handlers.GetMyCollectionsHandler)
+	r.GET("/collections", handlers.GetPublicCollectionsHandler)
+	r.POST("/collections", middleware.AuthMiddleware(), handlers.CreateCollectionHandler)
+	r.GET("/collections/:id", handlers.GetCollectionHandler)
+	r.PUT("/collections/:id", middleware.AuthMiddleware(), handlers.UpdateCollectionHandler)
+	r.DELETE("/collections/:id", middleware.AuthMiddleware(), handlers.DeleteCollectionHandler)
+	r.POST("/collections/:id/duplicate", middleware.AuthMiddleware(), handlers.DuplicateCollectionHandler)
+	r.POST("/booklets", middleware.AuthMiddleware(), handlers.CreateBookletHandler)
+	r.PUT("/partitions/:id/organization", middleware.AuthMiddleware(), handlers.SetPartitionOrganizationHandler)
+	r.GET("/me/organizations", middleware.AuthMiddleware(), handlers.GetMyOrganizationsHandler)
+	r.POST("/organizations", middleware.AuthMiddleware(), handlers.CreateOrganizationHandler)
+	r.GET("/organizations/:id", middleware.AuthMiddleware(), handlers.GetOrganizationHandler)
+	r.PUT("/organizations/:id", middleware.AuthMiddleware(), handlers.UpdateOrganizationHandler)
+	r.DELETE("/organizations/:id", middleware.AuthMiddleware(), handlers.DeleteOrganizationHandler)
+	r.GET("/organizations/:id/collections", middleware.AuthMiddleware(), handlers.GetOrganizationCollectionsHandler)
+	r.GET("/organizations/:id/invitations", middleware.AuthMiddleware(), handlers.GetInvitationsHandler)
+	r.POST("/organizations/:id/invitations", middleware.AuthMiddleware(), handlers.CreateInvitationHandler)
+	r.DELETE("/organizations/:id/invitations/:invitation", middleware.AuthMiddleware(), handlers.DeleteInvitationHandler)
+	r.PUT("/organizations/:id/members/:user", middleware.AuthMiddleware(), handlers.UpdateMemberHandler)
+	r.DELETE("/organizations/:id/members/:user", middleware.AuthMiddleware(), handlers.RemoveMemberHandler)
+	r.GET("/organizations/:id/events", middleware.AuthMiddleware(), handlers.GetOrganizationEventsHandler)
+	r.POST("/organizations/:id/events", middleware.AuthMiddleware(), handlers.CreateEventHandler)
+	r.GET("/events/:id", middleware.AuthMiddleware(), handlers.GetEventHandler)
+	r.PUT("/events/:id", middleware.AuthMiddleware(), handlers.UpdateEventHandler)
+	r.DELETE("/events/:id", middleware.AuthMiddleware(), handlers.DeleteEventHandler)
+	r.GET("/me/events", middleware.AuthMiddleware(), handlers.GetMyEventsHandler)
+	r.GET("/me/calendar", middleware.AuthMiddleware(), handlers.GetCalendarFeedsHandler)
+	r.POST("/me/calendar/token", middleware.AuthMiddleware(), handlers.ResetCalendarTokenHandler)
+	r.GET("/calendars/:token/events.ics", handlers.CalendarFeedHandler)
+	r.GET("/calendars/:token/organizations/:id/events.ics", handlers.OrganizationCalendarFeedHandler)
+	r.GET("/invitations/:token", handlers.GetInvitationHandler)
+	r.POST("/invitations/:token/accept", middleware.AuthMiddleware(), handlers.AcceptInvitationHandler)
 }